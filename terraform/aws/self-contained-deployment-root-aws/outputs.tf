@@ -21,4 +21,19 @@ output "zdm_jumphost_public_ip" {
 output "zdm_vpc_id" {
   description = "ID of the VPC provisioned for the ZDM deployment"
   value = module.zdm_proxy_networking.zdm_vpc_id
+}
+
+output "zdm_keypair_name" {
+  description = "Name of the key pair of the ZDM instances, whose private key is in zdm_public_key_local_path"
+  value = var.zdm_keypair_name
+}
+
+output "zdm_public_key_local_path" {
+  description = "Path where the key pair of the ZDM instances is stored"
+  value = var.zdm_public_key_local_path
+}
+
+output "zdm_linux_user" {
+  description = "User that Ansible connects as on the ZDM instances, which is the default user of their Linux distribution"
+  value = module.zdm_instances.zdm_linux_user
 }
\ No newline at end of file
@@ -25,4 +25,9 @@ output "zdm_monitoring_instance_private_ip" {
 
 output "public_key" {
   value = aws_key_pair.zdm_key_pair.public_key
+}
+
+output "zdm_linux_user" {
+  description = "Default user of the Linux distribution of the EC2 ZDM instances"
+  value = local.allowed_linux_distros[var.zdm_linux_distro].linux_user
 }
\ No newline at end of file
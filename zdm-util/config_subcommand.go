@@ -0,0 +1,247 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+	"zdm-proxy-automation/zdm-util/pkg/output"
+	"zdm-proxy-automation/zdm-util/pkg/userinteraction"
+)
+
+const (
+	ConfigSubcommand = "config"
+
+	configShowAction     = "show"
+	configValidateAction = "validate"
+	configSetAction      = "set"
+	configUnsetAction    = "unset"
+	configMigrateAction  = "migrate"
+)
+
+// configResult is emitted in machine-readable output once the config subcommand has completed
+type configResult struct {
+	Action           string                  `json:"action"`
+	ConfigFile       string                  `json:"configFile"`
+	Profile          string                  `json:"profile,omitempty"`
+	Properties       map[string]string       `json:"properties,omitempty"`
+	MigrationChanges []string                `json:"migrationChanges,omitempty"`
+	ValidationErrors config.ValidationErrors `json:"validationErrors,omitempty"`
+	Error            string                  `json:"error,omitempty"`
+}
+
+// launchConfig shows, validates or modifies the properties of a profile of the configuration file, without creating the container.
+// Usage: config show|validate|set <key> <value>|unset <key>|migrate
+func launchConfig(args []string) int {
+	usage := fmt.Sprintf("Usage: zdm-util %v %v|%v|%v <key> <value>|%v <key>|%v [flags] \n",
+		ConfigSubcommand, configShowAction, configValidateAction, configSetAction, configUnsetAction, configMigrateAction)
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		logger.Errorf("%v", usage)
+		return 2
+	}
+	action := args[0]
+
+	configFlags := flag.NewFlagSet(ConfigSubcommand+" "+action, flag.ExitOnError)
+	customConfigFilePath := configFlags.String("utilConfigFile", "", "Configuration file to use instead of the one in the current directory")
+	profile := configFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	outputFormat := configFlags.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := configFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	strictConfig := configFlags.Bool(config.FlagNameForProperty(StrictConfigSettingName), false, StrictConfigFlagUsage)
+	configFlags.Usage = func() {
+		logger.Infof("%v", usage)
+		configFlags.PrintDefaults()
+	}
+
+	// the key and value can be specified before or after the flags
+	positionalArgs := make([]string, 0)
+	args = args[1:]
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positionalArgs = append(positionalArgs, args[0])
+		args = args[1:]
+	}
+	_ = configFlags.Parse(args)
+	positionalArgs = append(positionalArgs, configFlags.Args()...)
+
+	expectedNumberOfArgs := map[string]int{configShowAction: 0, configValidateAction: 0, configSetAction: 2, configUnsetAction: 1, configMigrateAction: 0}
+	numberOfArgs, isValidAction := expectedNumberOfArgs[action]
+	if !isValidAction || len(positionalArgs) != numberOfArgs {
+		configFlags.Usage()
+		return 2
+	}
+
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	result := &configResult{
+		Action:     action,
+		ConfigFile: *customConfigFilePath,
+		Profile:    resolvedProfile,
+	}
+	if result.ConfigFile == "" {
+		result.ConfigFile = userinteraction.DefaultConfigurationFilePath
+	}
+
+	var exitCode int
+	switch action {
+	case configShowAction:
+		exitCode, err = showConfig(result)
+	case configValidateAction:
+		exitCode, err = validateConfig(result, resolveBoolSetting(*strictConfig, StrictConfigSettingName))
+	case configSetAction:
+		exitCode, err = setConfigProperty(result, positionalArgs[0], positionalArgs[1])
+	case configUnsetAction:
+		exitCode, err = unsetConfigProperty(result, positionalArgs[0])
+	case configMigrateAction:
+		exitCode, err = migrateConfig(result)
+	}
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		result.Error = err.Error()
+	}
+	reporter.Result(result)
+	return exitCode
+}
+
+// readProfileConfig reads the configuration of the profile from the configuration file, which must exist and hold the profile
+func readProfileConfig(result *configResult) (*config.ContainerInitConfig, error) {
+	containerConfig, err := config.NewContainerInitConfigFromFile(result.ConfigFile, result.Profile)
+	if err != nil {
+		return nil, err
+	}
+	result.Properties = containerConfig.Properties()
+	return containerConfig, nil
+}
+
+func showConfig(result *configResult) (int, error) {
+	containerConfig, err := readProfileConfig(result)
+	if err != nil {
+		return 1, err
+	}
+	containerConfig.PrintProperties()
+	return 0, nil
+}
+
+// validateConfig reports all the problems found with the properties of the profile, including its unknown properties in strict mode,
+// which are otherwise only warned about.
+// It returns a non-zero exit code if there are any
+func validateConfig(result *configResult, strictConfig bool) (int, error) {
+	containerConfig, err := readProfileConfig(result)
+	if err != nil {
+		return 1, err
+	}
+	result.ValidationErrors = containerConfig.Validate()
+	if strictConfig {
+		result.ValidationErrors = append(result.ValidationErrors, containerConfig.CheckUnknownProperties()...)
+	} else {
+		containerConfig.WarnAboutUnknownProperties()
+	}
+	if len(result.ValidationErrors) > 0 {
+		logger.Errorf("The configuration file %v is not valid: \n", result.ConfigFile)
+		for _, fieldError := range result.ValidationErrors {
+			logger.Errorf(" - %v \n", fieldError)
+		}
+		return 1, nil
+	}
+	logger.Infof("The configuration file %v is valid \n", result.ConfigFile)
+	return 0, nil
+}
+
+// setConfigProperty validates the value and writes it to the profile, creating the configuration file or the profile if needed.
+// The file is left unchanged if the value is not valid
+func setConfigProperty(result *configResult, propertyName string, value string) (int, error) {
+	if config.FormatString(value) == "" {
+		return 2, fmt.Errorf("the value of property %v cannot be empty. Use %v %v to remove it", propertyName, ConfigSubcommand, configUnsetAction)
+	}
+	configFile, err := config.ReadConfigFileIfExists(result.ConfigFile)
+	if err != nil {
+		return 1, err
+	}
+
+	// the value is validated along with the other properties of the profile, e.g. additional SSH keys must differ from the main key
+	containerConfig := configFile.ContainerInitConfig(result.Profile)
+	if err = containerConfig.SetProperty(propertyName, value); err != nil {
+		return 2, fmt.Errorf("%v. Valid properties are: %v", err, strings.Join(config.PropertyNames(), ", "))
+	}
+	if result.ValidationErrors = containerConfig.ValidateProperty(propertyName); len(result.ValidationErrors) > 0 {
+		return 1, fmt.Errorf("the configuration file was not modified because the value is not valid: %v", result.ValidationErrors)
+	}
+
+	// paths are written as absolute paths, as they would otherwise be relative to the directory this utility is run from
+	normalizedValue, _ := containerConfig.Property(propertyName)
+	configFile.SetProperty(result.Profile, propertyName, normalizedValue)
+	if err = writeConfigFile(configFile, result); err != nil {
+		return 1, err
+	}
+	logger.Infof("Property %v set to %v in the configuration file %v \n", propertyName, normalizedValue, result.ConfigFile)
+	return 0, nil
+}
+
+// unsetConfigProperty removes the property from the profile. Properties that are not known can be removed too, e.g. if they are misspelt
+func unsetConfigProperty(result *configResult, propertyName string) (int, error) {
+	configFile, err := config.ReadConfigFile(result.ConfigFile)
+	if err != nil {
+		return 1, err
+	}
+	if !configFile.HasProfile(result.Profile) {
+		return 1, fmt.Errorf("profile %v not found in the configuration file %v. %v", result.Profile, result.ConfigFile, config.DescribeProfiles(configFile.ProfileNames()))
+	}
+
+	if !configFile.UnsetProperty(result.Profile, propertyName) {
+		result.Properties = configFile.Profiles[result.Profile]
+		logger.Infof("Property %v is not set in the configuration file %v, so it was not modified \n", propertyName, result.ConfigFile)
+		return 0, nil
+	}
+	if err = writeConfigFile(configFile, result); err != nil {
+		return 1, err
+	}
+	logger.Infof("Property %v removed from the configuration file %v \n", propertyName, result.ConfigFile)
+	return 0, nil
+}
+
+// migrateConfig rewrites the configuration file with the current version of the configuration schema, see config.CurrentConfigVersion.
+// Files are otherwise migrated when they are read, and only updated the next time they are written
+func migrateConfig(result *configResult) (int, error) {
+	configFile, err := config.ReadConfigFile(result.ConfigFile)
+	if err != nil {
+		return 1, err
+	}
+	result.MigrationChanges = configFile.MigrationChanges
+	if configFile.Version == config.CurrentConfigVersion {
+		result.Properties = configFile.Profiles[result.Profile]
+		logger.Infof("The configuration file %v is already at version %v of the configuration schema \n", result.ConfigFile, config.CurrentConfigVersion)
+		return 0, nil
+	}
+	// the version is only written to a file that is explicitly migrated, see config.ConfigFile.Marshal
+	fromVersion := configFile.Version
+	configFile.Version = config.CurrentConfigVersion
+	if err = writeConfigFile(configFile, result); err != nil {
+		return 1, err
+	}
+	logger.Infof("The configuration file %v has been migrated from version %v to version %v of the configuration schema \n",
+		result.ConfigFile, fromVersion, config.CurrentConfigVersion)
+	return 0, nil
+}
+
+// writeConfigFile writes the configuration file in its existing format, keeping its comments and the other profiles
+func writeConfigFile(configFile *config.ConfigFile, result *configResult) error {
+	content, err := configFile.Marshal(configFile.Format)
+	if err != nil {
+		return err
+	}
+	if err = config.WriteConfigFile(result.ConfigFile, content); err != nil {
+		return fmt.Errorf("the configuration file %v could not be written: %v", result.ConfigFile, err)
+	}
+	result.Properties = configFile.Profiles[result.Profile]
+	return nil
+}
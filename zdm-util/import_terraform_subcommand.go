@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/docker"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+	"zdm-proxy-automation/zdm-util/pkg/output"
+	"zdm-proxy-automation/zdm-util/pkg/terraform"
+	"zdm-proxy-automation/zdm-util/pkg/userinteraction"
+)
+
+const (
+	ImportTerraformSubcommand = "import-terraform"
+
+	// stdinFilePath reads the Terraform output from stdin, e.g. terraform output -json | zdm-util import-terraform -
+	stdinFilePath = "-"
+)
+
+// launchImportTerraform creates and initializes the container from the output of the Terraform automation, without prompting.
+// The inventory is generated from the addresses of the proxies and of the monitoring instance, and the SSH configuration from the jumphost, if any.
+// Usage: import-terraform [flags] <terraform output file>, where the file holds the output of terraform output -json, or - to read it from stdin
+func launchImportTerraform(args []string) int {
+	usage := fmt.Sprintf("Usage: zdm-util %v [flags] <file with the output of terraform output -json, or %v to read it from stdin> \n"+
+		"Flags, then environment variables, take precedence over the Terraform output, which takes precedence over the configuration file \n",
+		ImportTerraformSubcommand, stdinFilePath)
+	importFlags := flag.NewFlagSet(ImportTerraformSubcommand, flag.ExitOnError)
+	customConfigFilePath := importFlags.String("utilConfigFile", "", "Configuration file to update instead of the one in the current directory")
+	configFileFormat := importFlags.String("utilConfigFileFormat", "", "Format in which the configuration file is written: legacy, yaml or json")
+	sshKeyPathOnHost := importFlags.String(config.FlagNameForProperty(config.SshKeyPathOnHostPropertyName), "",
+		fmt.Sprintf("Path of the SSH private key to access the hosts, if the Terraform output has no %v", terraform.KeypairNameOutputName))
+	proxyIpAddressPrefix := importFlags.String(config.FlagNameForProperty(config.ProxyIpAddressPrefixPropertyName), "",
+		"Common prefix of the private IP addresses of the proxy hosts. By default, the longest prefix common to the addresses in the Terraform output")
+	remoteUser := importFlags.String(config.FlagNameForProperty(config.RemoteUserPropertyName), "",
+		fmt.Sprintf("User that Ansible connects as on the hosts, if the Terraform output has no %v. Default: %v", terraform.LinuxUserOutputName, config.DefaultRemoteUser))
+	hostOverrides := importFlags.String(config.FlagNameForProperty(config.HostOverridesPropertyName), "",
+		"Comma-separated groups or hosts with a different user or SSH port, each in the form <group or host>=<user>[:<port>], e.g. monitoring=centos")
+	localTestingDeployment := importFlags.Bool(config.FlagNameForProperty(userinteraction.LocalTestingDeploymentSettingName), false,
+		"Allow a single proxy host, for local testing and evaluation deployments")
+	recreateContainer := importFlags.Bool(config.FlagNameForProperty(userinteraction.RecreateContainerSettingName), false,
+		"Destroy and recreate an existing container, instead of using it as it is")
+	dryRun := importFlags.Bool(config.FlagNameForProperty(DryRunSettingName), false,
+		"Print the planned actions without performing them. The configuration file and inventory are not written either")
+	outputFormat := importFlags.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := importFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := importFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	strictConfig := importFlags.Bool(config.FlagNameForProperty(StrictConfigSettingName), false, StrictConfigFlagUsage)
+	importFlags.Usage = func() {
+		logger.Infof("%v", usage)
+		importFlags.PrintDefaults()
+	}
+	_ = importFlags.Parse(args)
+	if importFlags.NArg() != 1 {
+		importFlags.Usage()
+		return 2
+	}
+
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+	startTranscript(*customConfigFilePath)
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+	var fileFormat config.FileFormat
+	if *configFileFormat != "" {
+		if fileFormat, err = config.ParseFileFormat(*configFileFormat); err != nil {
+			logger.Errorf("%v \n", err)
+			return 2
+		}
+	}
+
+	outputs, err := readTerraformOutputs(importFlags.Arg(0))
+	if err != nil {
+		return reportError(reporter, nil, err)
+	}
+	logger.Infof("Imported from the Terraform output: %v proxies (%v), monitoring instance %v, jumphost %v \n",
+		len(outputs.ProxyPrivateIpAddresses), strings.Join(outputs.ProxyPrivateIpAddresses, ", "),
+		describeTerraformOutput(outputs.MonitoringPrivateIpAddress), describeTerraformOutput(outputs.JumphostPublicIpAddress))
+
+	sources := configurationSources{
+		customConfigFilePath: *customConfigFilePath,
+		fileFormat:           fileFormat,
+		profile:              resolvedProfile,
+		strictConfig:         resolveBoolSetting(*strictConfig, StrictConfigSettingName),
+		propertyLayers: importTerraformPropertyLayers(map[string]string{
+			config.SshKeyPathOnHostPropertyName:     *sshKeyPathOnHost,
+			config.ProxyIpAddressPrefixPropertyName: *proxyIpAddressPrefix,
+			config.RemoteUserPropertyName:           *remoteUser,
+			config.HostOverridesPropertyName:        *hostOverrides,
+		}, outputs),
+	}
+	settings := &userinteraction.NonInteractiveSettings{
+		ProxyIpAddresses:       outputs.ProxyPrivateIpAddresses,
+		MonitoringIpAddress:    outputs.MonitoringPrivateIpAddress,
+		LocalTestingDeployment: resolveBoolSetting(*localTestingDeployment, userinteraction.LocalTestingDeploymentSettingName),
+		GenerateInventory:      true,
+	}
+	creationOptions := docker.ContainerCreationOptions{
+		NonInteractive:            true,
+		RecreateExistingContainer: resolveBoolSetting(*recreateContainer, userinteraction.RecreateContainerSettingName),
+		DryRun:                    resolveBoolSetting(*dryRun, DryRunSettingName),
+		Reporter:                  reporter,
+	}
+	return launchUtil(sources, os.Stdin, settings, creationOptions)
+}
+
+// importTerraformPropertyLayers returns the layers of the properties in order of precedence: flags, then environment variables,
+// then the Terraform output, which takes precedence over the configuration file that it updates
+func importTerraformPropertyLayers(flagValues map[string]string, outputs *terraform.Outputs) []config.PropertyLayer {
+	return []config.PropertyLayer{
+		config.NewFlagLayer(flagValues),
+		config.NewEnvVarLayer(),
+		outputs.PropertyLayer(),
+	}
+}
+
+// readTerraformOutputs reads the output of terraform output -json from the file, or from stdin if the path is -
+func readTerraformOutputs(filePath string) (*terraform.Outputs, error) {
+	if filePath != stdinFilePath {
+		return terraform.ReadOutputsFile(filePath)
+	}
+	content, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Terraform output from stdin: %v", err)
+	}
+	return terraform.ParseOutputs(content)
+}
+
+func describeTerraformOutput(value string) string {
+	if value == "" {
+		return "not provisioned"
+	}
+	return value
+}
@@ -2,54 +2,677 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"zdm-proxy-automation/zdm-util/pkg/config"
 	"zdm-proxy-automation/zdm-util/pkg/docker"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+	"zdm-proxy-automation/zdm-util/pkg/output"
 	"zdm-proxy-automation/zdm-util/pkg/userinteraction"
 )
 
 const (
 	UtilityExitingMessage  = "This utility will now exit. Please rectify the problem and re-run. "
+
+	StatusSubcommand      = "status"
+	ShellSubcommand       = "shell"
+	RunPlaybookSubcommand = "run-playbook"
+	DestroySubcommand     = "destroy"
+
+	DryRunSettingName  = "dry_run"
+	VerboseSettingName = "verbose"
+	VerboseFlagUsage   = "Print debug messages, including the calls made to the Docker API"
+
+	OutputFlagName  = "output"
+	OutputFlagUsage = "Output format: text or json. With json, machine-readable events are written to stdout and all other messages to stderr"
+
+	StrictConfigSettingName = "strict_config"
+	StrictConfigFlagUsage   = "Report unknown properties of the configuration file as errors, instead of ignoring them"
+
+	ProfileSettingName = "profile"
+	ProfileFlagUsage   = "Profile of the configuration file to use, each with its own container. By default, the properties at the top level of the configuration file are used"
 )
 
 func main() {
 
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		exit(launchSubcommand(os.Args[1], os.Args[2:]))
+	}
+
 	customConfigFilePath := flag.String("utilConfigFile", "", "This option can be used to specify a custom configuration file for this utility")
+	configFileFormat := flag.String("utilConfigFileFormat", "",
+		"Format in which the configuration file is written: legacy, yaml or json. By default, the format of the existing configuration file is kept, and new files use the legacy format")
+	nonInteractive := flag.Bool(config.FlagNameForProperty(userinteraction.NonInteractiveSettingName), false,
+		"Run without prompting. All required values must be provided by flags, environment variables or the configuration file")
+	sshKeyPathOnHost := flag.String(config.FlagNameForProperty(config.SshKeyPathOnHostPropertyName), "", "Path of the SSH private key to access the proxy hosts")
+	proxyIpAddressPrefix := flag.String(config.FlagNameForProperty(config.ProxyIpAddressPrefixPropertyName), "", "Common prefix of the private IP addresses of the proxy hosts, e.g. 172.18.* or fd00:10:*, or their CIDR range, e.g. 10.0.16.0/20 or fd00:10::/32")
+	ansibleInventoryPathOnHost := flag.String(config.FlagNameForProperty(config.AnsibleInventoryPathOnHostPropertyName), "", "Path of an existing Ansible inventory file")
+	additionalSshKeys := flag.String(config.FlagNameForProperty(config.AdditionalSshKeysPropertyName), "",
+		"Comma-separated SSH private keys for hosts that cannot be accessed with the key of the proxy hosts, each in the form <key path>=<host pattern> [<host pattern> ...]")
+	jumphostPublicIpAddress := flag.String(config.FlagNameForProperty(config.JumphostPublicIpAddressPropertyName), "",
+		"Public IP address of the jumphost through which the proxy hosts are reached, if they are in a private network. The SSH configuration of the container is then generated from the Ansible inventory")
+	jumphostPrivateIpAddress := flag.String(config.FlagNameForProperty(config.JumphostPrivateIpAddressPropertyName), "",
+		"Private IP address of the jumphost in the network of the proxy hosts, if any")
+	remoteUser := flag.String(config.FlagNameForProperty(config.RemoteUserPropertyName), "",
+		"User that Ansible connects as on the hosts of a generated Ansible inventory, e.g. ubuntu, centos, ec2-user or rocky. Default: "+config.DefaultRemoteUser)
+	hostOverrides := flag.String(config.FlagNameForProperty(config.HostOverridesPropertyName), "",
+		"Comma-separated groups or hosts of a generated Ansible inventory with a different user or SSH port, each in the form <group or host>=<user>[:<port>], e.g. monitoring=centos,172.18.10.5=rocky:2222")
+	proxyIpAddresses := flag.String(config.FlagNameForProperty(userinteraction.ProxyIpAddressesSettingName), "",
+		"Comma-separated private IP addresses or hostnames of the proxy hosts, used to generate the Ansible inventory in non-interactive mode")
+	monitoringIpAddress := flag.String(config.FlagNameForProperty(userinteraction.MonitoringIpAddressSettingName), "",
+		"Private IP address or hostname of the monitoring host, used to generate the Ansible inventory in non-interactive mode")
+	resolveHostnames := flag.Bool(config.FlagNameForProperty(userinteraction.ResolveHostnamesSettingName), false,
+		"Resolve the hostnames of the proxy and monitoring hosts before generating the Ansible inventory in non-interactive mode, to check that they exist")
+	localTestingDeployment := flag.Bool(config.FlagNameForProperty(userinteraction.LocalTestingDeploymentSettingName), false,
+		"Allow a single proxy host in the Ansible inventory, whether generated or existing, in non-interactive mode")
+	recreateContainer := flag.Bool(config.FlagNameForProperty(userinteraction.RecreateContainerSettingName), false,
+		"Destroy and recreate an existing container in non-interactive mode, instead of using it as it is")
+	dryRun := flag.Bool(config.FlagNameForProperty(DryRunSettingName), false,
+		"Validate the configuration and print the planned actions without performing them. The configuration file and inventory are not written either")
+	outputFormat := flag.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := flag.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := flag.String(ProfileSettingName, "", ProfileFlagUsage)
+	strictConfig := flag.Bool(config.FlagNameForProperty(StrictConfigSettingName), false, StrictConfigFlagUsage)
 	flag.Parse()
 
-	launchUtil(*customConfigFilePath, os.Stdin)
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		exit(2)
+	}
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+	startTranscript(*customConfigFilePath)
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		exit(2)
+	}
+
+	var fileFormat config.FileFormat
+	if *configFileFormat != "" {
+		if fileFormat, err = config.ParseFileFormat(*configFileFormat); err != nil {
+			logger.Errorf("%v \n", err)
+			exit(2)
+		}
+	}
+
+	// flags take precedence over environment variables, which take precedence over the configuration file
+	sources := configurationSources{
+		customConfigFilePath: *customConfigFilePath,
+		fileFormat:           fileFormat,
+		profile:              resolvedProfile,
+		strictConfig:         resolveBoolSetting(*strictConfig, StrictConfigSettingName),
+		propertyLayers: []config.PropertyLayer{
+			config.NewFlagLayer(map[string]string{
+				config.SshKeyPathOnHostPropertyName:           *sshKeyPathOnHost,
+				config.ProxyIpAddressPrefixPropertyName:       *proxyIpAddressPrefix,
+				config.AnsibleInventoryPathOnHostPropertyName: *ansibleInventoryPathOnHost,
+				config.AdditionalSshKeysPropertyName:          *additionalSshKeys,
+				config.JumphostPublicIpAddressPropertyName:    *jumphostPublicIpAddress,
+				config.JumphostPrivateIpAddressPropertyName:   *jumphostPrivateIpAddress,
+				config.RemoteUserPropertyName:                 *remoteUser,
+				config.HostOverridesPropertyName:              *hostOverrides,
+			}),
+			config.NewEnvVarLayer(),
+		},
+	}
+
+	if !resolveBoolSetting(*nonInteractive, userinteraction.NonInteractiveSettingName) {
+		creationOptions := docker.ContainerCreationOptions{
+			RecreateExistingContainer: resolveBoolSetting(*recreateContainer, userinteraction.RecreateContainerSettingName),
+			DryRun:                    resolveBoolSetting(*dryRun, DryRunSettingName),
+			Reporter:                  reporter,
+		}
+		exit(launchUtil(sources, os.Stdin, nil, creationOptions))
+	}
+
+	settings := &userinteraction.NonInteractiveSettings{
+		ProxyIpAddresses:       splitCommaSeparatedValues(resolveStringSetting(*proxyIpAddresses, userinteraction.ProxyIpAddressesSettingName)),
+		MonitoringIpAddress:    resolveStringSetting(*monitoringIpAddress, userinteraction.MonitoringIpAddressSettingName),
+		LocalTestingDeployment: resolveBoolSetting(*localTestingDeployment, userinteraction.LocalTestingDeploymentSettingName),
+		ResolveHostnames:       resolveBoolSetting(*resolveHostnames, userinteraction.ResolveHostnamesSettingName),
+	}
+	creationOptions := docker.ContainerCreationOptions{
+		NonInteractive:            true,
+		RecreateExistingContainer: resolveBoolSetting(*recreateContainer, userinteraction.RecreateContainerSettingName),
+		DryRun:                    resolveBoolSetting(*dryRun, DryRunSettingName),
+		Reporter:                  reporter,
+	}
+	exit(launchUtil(sources, os.Stdin, settings, creationOptions))
+}
+
+// exit closes the transcript, which would otherwise not be flushed as deferred calls do not run on os.Exit, and exits with the specified code
+func exit(exitCode int) {
+	logger.Debugf("Exiting with code %v \n", exitCode)
+	logger.CloseTranscript()
+	os.Exit(exitCode)
+}
+
+// startTranscript records this session in a timestamped transcript file, in the same directory as the configuration file of this utility.
+// The transcript is only an aid to troubleshooting, so this utility carries on if it cannot be created
+func startTranscript(customConfigFilePath string) {
+	transcriptDirPath := "."
+	if customConfigFilePath != "" {
+		if absoluteConfigFilePath, ok := config.ConvertToAbsolutePath(customConfigFilePath); ok {
+			transcriptDirPath = filepath.Dir(absoluteConfigFilePath)
+		}
+	}
+
+	transcriptFilePath, err := logger.StartTranscript(transcriptDirPath)
+	if err != nil {
+		logger.Warnf("%v. This session will not be recorded. \n", err)
+		return
+	}
+	logger.Infof("This session is being recorded in the transcript file %v \n", transcriptFilePath)
+	logger.Debugf("Command line: %v \n", strings.Join(os.Args, " "))
+}
+
+// resolveStringSetting returns the flag value if it was specified, otherwise the value of the corresponding environment variable
+func resolveStringSetting(flagValue string, settingName string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return config.FormatString(os.Getenv(config.EnvVarNameForProperty(settingName)))
+}
+
+// resolveProfile returns the profile selected by the flag or the corresponding environment variable, or the default profile if none is selected
+func resolveProfile(flagValue string) (string, error) {
+	profile := resolveStringSetting(flagValue, ProfileSettingName)
+	if profile == config.DefaultProfile {
+		return profile, nil
+	}
+	if fieldError := config.CheckProfileName(profile); fieldError != nil {
+		return "", fmt.Errorf("%v", fieldError.Message())
+	}
+	return profile, nil
+}
+
+// resolveBoolSetting returns true if either the flag or the corresponding environment variable are set to true
+func resolveBoolSetting(flagValue bool, settingName string) bool {
+	if flagValue {
+		return true
+	}
+	envValue, err := strconv.ParseBool(config.FormatString(os.Getenv(config.EnvVarNameForProperty(settingName))))
+	return err == nil && envValue
+}
+
+func splitCommaSeparatedValues(s string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(s, ",") {
+		if trimmedValue := config.FormatString(value); trimmedValue != "" {
+			values = append(values, trimmedValue)
+		}
+	}
+	return values
+}
+
+func launchSubcommand(subcommand string, args []string) int {
+	switch subcommand {
+	case StatusSubcommand:
+		return launchStatus(args)
+	case ShellSubcommand:
+		return launchShell(args)
+	case RunPlaybookSubcommand:
+		return launchRunPlaybook(args)
+	case DestroySubcommand:
+		return launchDestroy(args)
+	case ConfigSubcommand:
+		return launchConfig(args)
+	case ImportTerraformSubcommand:
+		return launchImportTerraform(args)
+	default:
+		logger.Errorf("unknown subcommand %v. Valid subcommands are: %v \n", subcommand,
+			strings.Join([]string{StatusSubcommand, ShellSubcommand, RunPlaybookSubcommand, DestroySubcommand, ConfigSubcommand, ImportTerraformSubcommand}, ", "))
+		return 2
+	}
+}
+
+// configurationSources describes where the configuration properties are read from, in addition to the prompts
+type configurationSources struct {
+	customConfigFilePath string
+	// fileFormat is the format in which the configuration file is written, empty to keep the existing one
+	fileFormat config.FileFormat
+	// profile is the profile of the configuration file that is read and written
+	profile string
+	// strictConfig makes unknown properties of the configuration file an error
+	strictConfig bool
+	// propertyLayers take precedence over the configuration file, in order of precedence
+	propertyLayers []config.PropertyLayer
 }
 
-func launchUtil(customConfigFilePath string, userInputFile *os.File) {
+// launchUtil creates and initializes the container. The non-interactive settings are nil when running in interactive mode
+func launchUtil(sources configurationSources, userInputFile *os.File, settings *userinteraction.NonInteractiveSettings, creationOptions docker.ContainerCreationOptions) int {
 
 	reader := bufio.NewReader(userInputFile)
 
-	interactionOrchestrator := userinteraction.NewInteractionOrchestrator(reader)
+	var interactionOrchestrator *userinteraction.InteractionOrchestrator
+	if settings != nil {
+		interactionOrchestrator = userinteraction.NewNonInteractiveOrchestrator(settings)
+	} else {
+		interactionOrchestrator = userinteraction.NewInteractionOrchestrator(reader)
+	}
+	interactionOrchestrator.SetConfigurationFileFormat(sources.fileFormat)
+	interactionOrchestrator.SetProfile(sources.profile)
+	interactionOrchestrator.SetStrictConfig(sources.strictConfig)
+	interactionOrchestrator.SetPropertyLayers(sources.propertyLayers...)
+	interactionOrchestrator.SetDryRun(creationOptions.DryRun)
+
+	reporter := creationOptions.Reporter
 
-	err := docker.ValidateDockerPrerequisites()
+	// an interrupt cancels the Docker operations in progress. The prompts cannot be cancelled, so an interrupt while prompting
+	// takes effect once the answer has been entered, unless a second interrupt terminates this utility
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	err := docker.ValidateDockerPrerequisites(ctx, reporter)
 	if err != nil {
-		fmt.Printf("ERROR: %v. %v \n", err, UtilityExitingMessage)
-		return
+		return reportError(reporter, nil, interruptedError(ctx, err))
 	}
 
-	containerConfig, err := interactionOrchestrator.CreateContainerConfiguration(customConfigFilePath)
+	containerConfig, err := interactionOrchestrator.CreateContainerConfiguration(sources.customConfigFilePath)
+	if err == nil {
+		err = ctx.Err()
+	}
 	if err != nil {
-		fmt.Printf("ERROR: %v. %v \n", err, UtilityExitingMessage)
-		return
+		return reportError(reporter, nil, interruptedError(ctx, err))
+	}
+	creationOptions.SshKeyPassphrases = interactionOrchestrator.SshKeyPassphrases()
+
+	if creationOptions.DryRun {
+		for _, plannedFileWrite := range interactionOrchestrator.PlannedFileWrites() {
+			creationOptions.HostFileWrites = append(creationOptions.HostFileWrites,
+				docker.HostFileWrite{Path: plannedFileWrite.Path, Description: plannedFileWrite.Description})
+		}
+		return launchDryRun(ctx, containerConfig, creationOptions)
 	}
 
 	ynAcceptAndProceed, err := interactionOrchestrator.DisplayConfigurationAndPromptForConfirmation()
+	if err == nil {
+		err = ctx.Err()
+	}
 	if err != nil {
-		fmt.Printf("ERROR: %v. %v \n", err, UtilityExitingMessage)
-		return
+		return reportError(reporter, nil, interruptedError(ctx, err))
 	}
 
-	if ynAcceptAndProceed {
-		err = docker.CreateAndInitializeContainer(containerConfig, reader)
-		if err != nil {
-			fmt.Printf("ERROR: %v. %v \n", err, UtilityExitingMessage)
+	if !ynAcceptAndProceed {
+		reporter.Result(&docker.ContainerCreationResult{Configuration: containerConfig.Properties()})
+		return 0
+	}
+
+	result, err := docker.CreateAndInitializeContainer(ctx, containerConfig, reader, creationOptions)
+	if err != nil {
+		return reportError(reporter, result, interruptedError(ctx, err))
+	}
+	reporter.Result(result)
+	return 0
+}
+
+// interruptedError marks the error as caused by an interrupt if the context was cancelled
+func interruptedError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("container setup interrupted: %v", err)
+	}
+	return err
+}
+
+// launchDryRun prints the actions that would be performed to create and initialize the container.
+// It returns a non-zero exit code if the plan could not succeed
+func launchDryRun(ctx context.Context, containerConfig *config.ContainerInitConfig, creationOptions docker.ContainerCreationOptions) int {
+	containerConfig.PrintProperties()
+	logger.Infoln()
+
+	plan, err := docker.PlanContainerCreation(ctx, containerConfig, creationOptions)
+	if err != nil {
+		err = interruptedError(ctx, err)
+		logger.Errorf("%v \n", err)
+		creationOptions.Reporter.Result(&docker.ContainerCreationPlan{Problems: []string{err.Error()}})
+		return 1
+	}
+
+	if creationOptions.Reporter.IsJson() {
+		creationOptions.Reporter.Result(plan)
+	} else {
+		plan.PrintPlan()
+	}
+	if !plan.CanSucceed() {
+		return 1
+	}
+	return 0
+}
+
+// reportError prints the error and emits it as part of the result in machine-readable output. It returns the exit code for the error
+func reportError(reporter *output.Reporter, result *docker.ContainerCreationResult, err error) int {
+	logger.Errorf("%v. %v \n", err, UtilityExitingMessage)
+	if result == nil {
+		result = &docker.ContainerCreationResult{CopiedFiles: make([]docker.CopiedFile, 0)}
+	}
+	result.Error = err.Error()
+	reporter.Result(result)
+	return exitCodeForError(err)
+}
+
+// setUpOutput creates the reporter for the specified output format. In json format, stdout is reserved for the machine-readable events,
+// so the human-readable messages, which are all printed through the logger, go to stderr instead
+func setUpOutput(outputFormat string) (*output.Reporter, error) {
+	format, err := output.ParseFormat(outputFormat)
+	if err != nil {
+		return nil, err
+	}
+	reporter := output.NewReporter(format, os.Stdout)
+	if reporter.IsJson() {
+		logger.SetConsole(os.Stderr)
+	}
+	return reporter, nil
+}
+
+// newInterruptibleContext returns a context that is cancelled on the first interrupt or termination signal, so that the operation in progress
+// can stop and clean up after itself. Signal handling is then restored to the default, so that a second interrupt terminates this utility immediately
+func newInterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			logger.Infof("\nInterrupt received, stopping and cleaning up. Interrupt again to exit immediately. \n")
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(signals)
 		}
+	}()
+	return ctx, cancel
+}
+
+// exitCodeForError returns the exit code of the container initialization script if that is what failed, so that it can be propagated
+func exitCodeForError(err error) int {
+	var initErr *docker.ContainerInitializationError
+	if errors.As(err, &initErr) && initErr.ExitCode > 0 {
+		return initErr.ExitCode
+	}
+	return 1
+}
+
+// launchStatus reports the state of the Ansible Control Host container. It returns a non-zero exit code if the container does not exist
+func launchStatus(args []string) int {
+	statusFlags := flag.NewFlagSet(StatusSubcommand, flag.ExitOnError)
+	customConfigFilePath := statusFlags.String("utilConfigFile", "", "Configuration file used to create the container, to determine the name of the Ansible inventory")
+	outputFormat := statusFlags.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := statusFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := statusFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	_ = statusFlags.Parse(args)
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	inventoryName, err := resolveAnsibleInventoryName(*customConfigFilePath, resolvedProfile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		reporter.Result(&docker.ContainerStatus{
+			ContainerName: docker.ContainerNameForProfile(resolvedProfile),
+			Profile:       resolvedProfile,
+			Error:         err.Error(),
+		})
+		return 1
+	}
+
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	containerStatus, err := docker.RetrieveContainerStatus(ctx, resolvedProfile, inventoryName)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		containerStatus.Error = err.Error()
+		reporter.Result(containerStatus)
+		return 1
+	}
+	if reporter.IsJson() {
+		reporter.Result(containerStatus)
+	} else {
+		containerStatus.PrintStatus()
+	}
+
+	if !containerStatus.Exists {
+		return 1
+	}
+	return 0
+}
+
+// launchShell opens an interactive shell in the container and returns the exit code of the shell
+func launchShell(args []string) int {
+	shellFlags := flag.NewFlagSet(ShellSubcommand, flag.ExitOnError)
+	verbose := shellFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := shellFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	_ = shellFlags.Parse(args)
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	// the terminal is in raw mode while the shell runs, so an interrupt typed by the user goes to the shell rather than to this utility
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	exitCode, err := docker.OpenShell(ctx, resolvedProfile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 1
+	}
+	return exitCode
+}
+
+// launchRunPlaybook runs a playbook in the container and returns the exit code of ansible-playbook.
+// Usage: run-playbook <name> [-e key=value] [--limit host]
+func launchRunPlaybook(args []string) int {
+	runPlaybookFlags := flag.NewFlagSet(RunPlaybookSubcommand, flag.ExitOnError)
+	customConfigFilePath := runPlaybookFlags.String("utilConfigFile", "", "Configuration file used to create the container, to determine the name of the Ansible inventory")
+	extraVars := make(stringListFlag, 0)
+	runPlaybookFlags.Var(&extraVars, "e", "Extra variable in the form key=value, passed to ansible-playbook. Can be repeated")
+	limit := runPlaybookFlags.String("limit", "", "Limit the execution of the playbook to the specified hosts or groups")
+	outputFormat := runPlaybookFlags.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := runPlaybookFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := runPlaybookFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	runPlaybookFlags.Usage = func() {
+		logger.Infof("Usage: zdm-util %v <name> [-e key=value] [--limit host] \n", RunPlaybookSubcommand)
+		runPlaybookFlags.PrintDefaults()
+	}
+
+	playbookName, err := parsePlaybookArgs(runPlaybookFlags, args)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		runPlaybookFlags.Usage()
+		return 2
+	}
+	if playbookName == "" {
+		runPlaybookFlags.Usage()
+		return 2
+	}
+
+	for _, extraVar := range extraVars {
+		if separatorIdx := strings.Index(extraVar, "="); separatorIdx <= 0 {
+			logger.Errorf("invalid extra variable %v, it must have the form key=value \n", extraVar)
+			return 2
+		}
+	}
+
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+	startTranscript(*customConfigFilePath)
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	result := &playbookResult{
+		Profile:   resolvedProfile,
+		Playbook:  playbookName,
+		ExtraVars: extraVars,
+		Limit:     *limit,
 	}
 
+	inventoryName, err := resolveAnsibleInventoryName(*customConfigFilePath, resolvedProfile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		result.Error = err.Error()
+		reporter.Result(result)
+		return 1
+	}
+	result.Inventory = inventoryName
+
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	result.ExitCode, err = docker.RunPlaybook(ctx, resolvedProfile, playbookName, inventoryName, extraVars, *limit)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		result.ExitCode = 1
+		result.Error = err.Error()
+	}
+	reporter.Result(result)
+	return result.ExitCode
+}
+
+// parsePlaybookArgs parses the flags of the run-playbook subcommand, which can be specified before or after the playbook name, and returns the name.
+// The flag package stops parsing at the first positional argument, so parsing resumes after the name. Any other positional argument is an error
+func parsePlaybookArgs(flags *flag.FlagSet, args []string) (string, error) {
+	playbookName := ""
+	for {
+		if err := flags.Parse(args); err != nil {
+			return "", err
+		}
+		if flags.NArg() == 0 {
+			return playbookName, nil
+		}
+		if playbookName != "" {
+			return "", fmt.Errorf("unexpected argument %v, only one playbook can be run at a time", flags.Arg(0))
+		}
+		playbookName = flags.Arg(0)
+		args = flags.Args()[1:]
+	}
+}
+
+// playbookResult is emitted in machine-readable output once the playbook has completed
+type playbookResult struct {
+	Playbook  string   `json:"playbook"`
+	Profile   string   `json:"profile,omitempty"`
+	Inventory string   `json:"inventory,omitempty"`
+	ExtraVars []string `json:"extraVars,omitempty"`
+	Limit     string   `json:"limit,omitempty"`
+	ExitCode  int      `json:"exitCode"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// stringListFlag collects the values of a flag that can be specified multiple times
+type stringListFlag []string
+
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// launchDestroy removes the container, optionally backing up its state first. Usage: destroy [-backupFile path] [-yes]
+func launchDestroy(args []string) int {
+	destroyFlags := flag.NewFlagSet(DestroySubcommand, flag.ExitOnError)
+	customConfigFilePath := destroyFlags.String("utilConfigFile", "", "Configuration file used to create the container, to determine the name of the Ansible inventory")
+	backupFilePath := destroyFlags.String("backupFile", "", "Path of a new tarball to which the Ansible configuration, inventory, SSH configuration and collected logs are exported before removing the container")
+	assumeYes := destroyFlags.Bool("yes", false, "Do not prompt for confirmation. No backup is made unless -backupFile is specified")
+	outputFormat := destroyFlags.String(OutputFlagName, string(output.TextFormat), OutputFlagUsage)
+	verbose := destroyFlags.Bool(config.FlagNameForProperty(VerboseSettingName), false, VerboseFlagUsage)
+	profile := destroyFlags.String(ProfileSettingName, "", ProfileFlagUsage)
+	_ = destroyFlags.Parse(args)
+
+	reporter, err := setUpOutput(*outputFormat)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+	logger.SetVerbose(resolveBoolSetting(*verbose, VerboseSettingName))
+	startTranscript(*customConfigFilePath)
+
+	resolvedProfile, err := resolveProfile(*profile)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		return 2
+	}
+
+	inventoryName, err := resolveAnsibleInventoryName(*customConfigFilePath, resolvedProfile)
+	if err != nil {
+		logger.Warnf("%v. The Ansible inventory will not be backed up. \n", err)
+	}
+
+	options := docker.ContainerDestructionOptions{
+		Profile:               resolvedProfile,
+		AssumeYes:             *assumeYes,
+		BackupFilePath:        *backupFilePath,
+		DefaultBackupFilePath: fmt.Sprintf("zdm_util_container_backup_%v.tar.gz", time.Now().Format("20060102_150405")),
+		InventoryName:         inventoryName,
+		Reporter:              reporter,
+	}
+
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	result, err := docker.DestroyContainer(ctx, bufio.NewReader(os.Stdin), options)
+	if err != nil {
+		logger.Errorf("%v \n", err)
+		result.Error = err.Error()
+		reporter.Result(result)
+		return 1
+	}
+	reporter.Result(result)
+	return 0
+}
+
+// resolveAnsibleInventoryName reads the inventory name of the profile from the specified configuration file, or from the default one if present.
+// If no configuration file is available, the inventory name generated for the profile is assumed
+func resolveAnsibleInventoryName(customConfigFilePath string, profile string) (string, error) {
+	configFilePath := customConfigFilePath
+	if configFilePath == "" && config.ValidatePathOfWritableFileSilently(userinteraction.DefaultConfigurationFilePath) {
+		configFilePath = userinteraction.DefaultConfigurationFilePath
+	}
+	if configFilePath == "" {
+		return userinteraction.InventoryFileNameForProfile(profile), nil
+	}
+
+	containerConfig, err := config.NewContainerInitConfigFromFile(configFilePath, profile)
+	if err != nil {
+		return "", err
+	}
+	if containerConfig.AnsibleInventoryPathOnHost != "" {
+		return filepath.Base(containerConfig.AnsibleInventoryPathOnHost), nil
+	}
+	return userinteraction.InventoryFileNameForProfile(profile), nil
 }
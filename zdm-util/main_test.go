@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/terraform"
+)
+
+func TestNewInterruptibleContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts cannot be sent to a process on Windows")
+	}
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+	require.Nil(t, ctx.Err())
+
+	process, err := os.FindProcess(os.Getpid())
+	require.Nil(t, err)
+	require.Nil(t, process.Signal(os.Interrupt))
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the context was not cancelled by the interrupt")
+	}
+}
+
+func TestInterruptedError(t *testing.T) {
+	err := errors.New("unable to create a Docker client")
+	require.Equal(t, err, interruptedError(context.Background(), err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Equal(t, "container setup interrupted: unable to create a Docker client", interruptedError(ctx, err).Error())
+}
+
+func TestParsePlaybookArgs(t *testing.T) {
+	tests := []struct {
+		name                 string
+		args                 []string
+		expectedPlaybookName string
+		expectedExtraVars    []string
+		expectedLimit        string
+		isErrorExpected      bool
+	}{
+		{
+			name:                 "flags after the name",
+			args:                 []string{"deploy_zdm_proxy.yml", "-e", "a=b", "--limit", "proxy-0"},
+			expectedPlaybookName: "deploy_zdm_proxy.yml",
+			expectedExtraVars:    []string{"a=b"},
+			expectedLimit:        "proxy-0",
+		},
+		{
+			name:                 "flags before and after the name",
+			args:                 []string{"-e", "a=b", "deploy_zdm_proxy.yml", "--limit", "proxy-0", "-e", "c=d"},
+			expectedPlaybookName: "deploy_zdm_proxy.yml",
+			expectedExtraVars:    []string{"a=b", "c=d"},
+			expectedLimit:        "proxy-0",
+		},
+		{
+			name:              "no name",
+			args:              []string{"-e", "a=b"},
+			expectedExtraVars: []string{"a=b"},
+		},
+		{
+			name:            "several names",
+			args:            []string{"deploy_zdm_proxy.yml", "--limit", "proxy-0", "rolling_restart_zdm_proxy.yml"},
+			isErrorExpected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet(RunPlaybookSubcommand, flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			extraVars := make(stringListFlag, 0)
+			flags.Var(&extraVars, "e", "")
+			limit := flags.String("limit", "", "")
+
+			playbookName, err := parsePlaybookArgs(flags, tt.args)
+			if tt.isErrorExpected {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedPlaybookName, playbookName)
+			require.Equal(t, tt.expectedExtraVars, []string(extraVars))
+			require.Equal(t, tt.expectedLimit, *limit)
+		})
+	}
+}
+
+func TestImportTerraformPropertyLayers(t *testing.T) {
+	t.Setenv(config.EnvVarNameForProperty(config.SshKeyPathOnHostPropertyName), "/home/ubuntu/env_key")
+	t.Setenv(config.EnvVarNameForProperty(config.RemoteUserPropertyName), "rocky")
+	outputs := &terraform.Outputs{SshKeyPath: "/home/ubuntu/terraform_key", RemoteUser: "ubuntu"}
+
+	containerConfig := &config.ContainerInitConfig{ProxyIpAddressPrefix: "172.18.*"}
+	containerConfig.ApplyLayers(importTerraformPropertyLayers(map[string]string{config.RemoteUserPropertyName: "centos"}, outputs)...)
+	require.Equal(t, "/home/ubuntu/env_key", containerConfig.SshKeyPathOnHost)
+	require.Equal(t, "centos", containerConfig.RemoteUser)
+
+	// the Terraform output takes precedence over the configuration file
+	t.Setenv(config.EnvVarNameForProperty(config.SshKeyPathOnHostPropertyName), "")
+	containerConfig = &config.ContainerInitConfig{SshKeyPathOnHost: "/home/ubuntu/file_key"}
+	containerConfig.ApplyLayers(importTerraformPropertyLayers(map[string]string{}, outputs)...)
+	require.Equal(t, "/home/ubuntu/terraform_key", containerConfig.SshKeyPathOnHost)
+	require.Equal(t, "rocky", containerConfig.RemoteUser)
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const cidrExampleHint = "Example: 10.0.16.0/20 or fd00:10::/32"
+
+// IsCidr returns whether the value uses the CIDR notation, e.g. 10.0.16.0/20, rather than the wildcard syntax of the SSH configuration
+func IsCidr(value string) bool {
+	return strings.Contains(value, "/")
+}
+
+// CheckCidr returns an error if the value is not a valid CIDR range, which for IPv6 must have a prefix length multiple of 16.
+// The error has no field set
+func CheckCidr(cidr string) *FieldError {
+	trimmedCidr := FormatString(cidr)
+	ip, network, err := net.ParseCIDR(trimmedCidr)
+	if err != nil {
+		return &FieldError{Value: trimmedCidr, Reason: fmt.Sprintf("Malformed CIDR range %v", trimmedCidr), Hint: cidrExampleHint}
+	}
+	if prefixLength, _ := network.Mask.Size(); ip.To4() == nil && prefixLength%16 != 0 {
+		return &FieldError{
+			Value:  trimmedCidr,
+			Reason: fmt.Sprintf("IPv6 CIDR range %v must have a prefix length multiple of 16, so that it can be matched on whole groups of the addresses", trimmedCidr),
+			Hint:   cidrExampleHint,
+		}
+	}
+	return nil
+}
+
+// CanonicalIPAddress returns the canonical form of an IP address, which for IPv6 is the compressed lowercase form of RFC 5952,
+// or the value as it is if it is not a valid address
+func CanonicalIPAddress(ipAddress string) string {
+	ip := net.ParseIP(FormatString(ipAddress))
+	if ip == nil {
+		return ipAddress
+	}
+	return ip.String()
+}
+
+// CanonicalHostAddress is like CanonicalIPAddress, and also returns hostnames in lowercase, as they are case-insensitive
+func CanonicalHostAddress(address string) string {
+	if net.ParseIP(FormatString(address)) == nil {
+		return strings.ToLower(FormatString(address))
+	}
+	return CanonicalIPAddress(address)
+}
+
+// MatchesIpAddressPrefix returns whether the IP address is matched by the prefix, e.g. 172.18.* or fd00:10:*, or is in the CIDR range,
+// as the SSH configuration of the container only gives access to the hosts it matches with the SSH key of the proxies
+func MatchesIpAddressPrefix(ipPrefix string, ipAddress string) bool {
+	ip := net.ParseIP(FormatString(ipAddress))
+	if ip == nil {
+		return false
+	}
+	if IsCidr(ipPrefix) {
+		_, network, err := net.ParseCIDR(FormatString(ipPrefix))
+		return err == nil && network.Contains(ip)
+	}
+	return strings.HasPrefix(ip.String(), strings.TrimSuffix(FormatString(ipPrefix), "*"))
+}
+
+// HostPatterns translates a CIDR range, e.g. 10.0.16.0/20, into the minimal set of patterns of the Host keyword of the SSH configuration
+// that match exactly the addresses of the range, e.g. 10.0.16.* 10.0.17.* 10.0.18.* 10.0.19.* 10.0.2?.* 10.0.30.* 10.0.31.*.
+// Any value that is not a CIDR range is returned as it is, as it is already a pattern
+func HostPatterns(value string) ([]string, error) {
+	value = FormatString(value)
+	if !IsCidr(value) {
+		return []string{value}, nil
+	}
+	if fieldError := CheckCidr(value); fieldError != nil {
+		return nil, fmt.Errorf("%v", fieldError.Message())
+	}
+
+	// the host bits of the address are ignored, as ParseCIDR masks them out of the network
+	_, network, _ := net.ParseCIDR(value)
+	prefixLength, _ := network.Mask.Size()
+	if prefixLength == 0 {
+		return []string{"*"}, nil
+	}
+	networkIp := network.IP.To4()
+	if networkIp == nil {
+		return ipv6HostPatterns(network.IP, prefixLength), nil
+	}
+
+	// all octets up to the one containing the last bit of the prefix are matched explicitly, and any following octet with a wildcard
+	lastOctetIdx := (prefixLength - 1) / 8
+	fixedOctets := ""
+	for i := 0; i < lastOctetIdx; i++ {
+		fixedOctets += strconv.Itoa(int(networkIp[i])) + "."
+	}
+	firstValue := int(networkIp[lastOctetIdx])
+	lastValue := firstValue | (0xFF >> (prefixLength - lastOctetIdx*8))
+	suffix := ""
+	if lastOctetIdx < 3 {
+		suffix = ".*"
+	}
+
+	patterns := make([]string, 0)
+	for _, octetPattern := range octetRangePatterns(firstValue, lastValue) {
+		patterns = append(patterns, fixedOctets+octetPattern+suffix)
+	}
+	return patterns, nil
+}
+
+// octetRangePatterns returns the patterns that match exactly the decimal values of an octet in the range, using the ? wildcard,
+// which matches exactly one character, for each complete group of values that only differ in their last digits, e.g. 20-29 as 2?
+func octetRangePatterns(firstValue int, lastValue int) []string {
+	patterns := make([]string, 0)
+	for value := firstValue; value <= lastValue; {
+		switch {
+		case value == 0 && lastValue >= 9:
+			patterns = append(patterns, "?")
+			value += 10
+		case value == 10 && lastValue >= 99:
+			patterns = append(patterns, "??")
+			value += 90
+		case value == 100 && lastValue >= 199:
+			patterns = append(patterns, "1??")
+			value += 100
+		case value >= 10 && value%10 == 0 && value+9 <= lastValue:
+			patterns = append(patterns, strconv.Itoa(value/10)+"?")
+			value += 10
+		default:
+			patterns = append(patterns, strconv.Itoa(value))
+			value++
+		}
+	}
+	return patterns
+}
+
+// ipv6HostPatterns returns the patterns that match the canonical form of the addresses of an IPv6 range whose prefix length is
+// a multiple of 16. Besides the pattern made of the groups of the prefix, there is a pattern for each run of zero groups of the prefix
+// that can be compressed to "::" in the canonical form. When such a run reaches the end of the prefix, the number of groups it covers
+// in an address cannot be expressed with wildcards, so the pattern may also match a few addresses outside of the range
+func ipv6HostPatterns(networkIp net.IP, prefixLength int) []string {
+	groupCount := prefixLength / 16
+	groups := make([]string, 0, groupCount)
+	for i := 0; i < groupCount; i++ {
+		groups = append(groups, strconv.FormatUint(uint64(networkIp[2*i])<<8|uint64(networkIp[2*i+1]), 16))
+	}
+	if groupCount == 8 {
+		return []string{networkIp.String()}
+	}
+
+	patterns := []string{strings.Join(groups, ":") + ":*"}
+	for runStart := 0; runStart < groupCount; runStart++ {
+		if groups[runStart] != "0" || (runStart > 0 && groups[runStart-1] == "0") {
+			continue
+		}
+		runEnd := runStart
+		for runEnd+1 < groupCount && groups[runEnd+1] == "0" {
+			runEnd++
+		}
+		switch {
+		case runEnd == groupCount-1:
+			patterns = append(patterns, strings.Join(groups[:runStart], ":")+"::*")
+		case runEnd > runStart:
+			patterns = append(patterns, strings.Join(groups[:runStart], ":")+"::"+strings.Join(groups[runEnd+1:], ":")+":*")
+		}
+	}
+	return patterns
+}
@@ -1,117 +1,235 @@
 package config
 
 import (
-	"bufio"
 	"fmt"
-	"github.com/phayes/permbits"
-	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
 )
 
 const (
 	SshKeyPathOnHostPropertyName           = "ssh_key_path_on_host"
 	ProxyIpAddressPrefixPropertyName       = "proxy_ip_address_prefix"
 	AnsibleInventoryPathOnHostPropertyName = "ansible_inventory_path_on_host"
+	// AdditionalSshKeysPropertyName is optional, and lists the keys to access hosts that cannot be accessed with the key at ssh_key_path_on_host
+	AdditionalSshKeysPropertyName = "additional_ssh_keys"
+	// JumphostPublicIpAddressPropertyName is optional, and is set when the proxies can only be reached through a jumphost, see JumphostPrivateIpAddressPropertyName.
+	// Like the other addresses of hosts, it can also be a hostname
+	JumphostPublicIpAddressPropertyName = "jumphost_public_ip_address"
+	// JumphostPrivateIpAddressPropertyName is optional, and is the address of the jumphost in the network of the proxies
+	JumphostPrivateIpAddressPropertyName = "jumphost_private_ip_address"
+	// RemoteUserPropertyName is optional, and is the user that Ansible connects as on the hosts of a generated inventory, see DefaultRemoteUser
+	RemoteUserPropertyName = "remote_user"
+	// HostOverridesPropertyName is optional, and lists the groups or hosts of a generated inventory that have a different user or SSH port
+	HostOverridesPropertyName = "host_overrides"
+
+	EnvVarPrefix = "ZDM_UTIL_"
 )
 
+// ContainerInitConfig is the configuration of the container. Values are stored as provided, after converting paths to absolute paths,
+// and are only checked by Validate, so that all problems can be reported at once
 type ContainerInitConfig struct {
-	Properties map[string]string
+	SshKeyPathOnHost           string
+	ProxyIpAddressPrefix       string
+	AnsibleInventoryPathOnHost string
+	AdditionalSshKeys          []SshKey
+	JumphostPublicIpAddress    string
+	JumphostPrivateIpAddress   string
+	RemoteUser                 string
+	HostOverrides              []HostOverride
+	// Sources records where the value of each property comes from
+	Sources map[string]PropertySource
+	// FileFormat is the format of the configuration file this configuration was read from, if any
+	FileFormat FileFormat
+	// Profile is the name of the profile of the configuration file this configuration belongs to, see DefaultProfile
+	Profile string
+	// UnknownProperties holds the properties of the configuration file that are not known and are ignored, see CheckUnknownProperties
+	UnknownProperties map[string]string
 }
 
 func NewEmptyContainerInitConfig() *ContainerInitConfig {
 	return &ContainerInitConfig{
-		Properties: make(map[string]string, 0),
+		Sources:           make(map[string]PropertySource, 0),
+		UnknownProperties: make(map[string]string),
 	}
 }
 
-func NewContainerInitConfigFromFile(filePath string) (*ContainerInitConfig, error) {
+// NewContainerInitConfigFromFile reads the configuration of a profile from a file in any of the supported formats, see DetectFileFormat.
+// It returns an error if the file does not hold the profile. The values are not validated, see Validate
+func NewContainerInitConfigFromFile(filePath string, profile string) (*ContainerInitConfig, error) {
 
-	file, err := os.Open(filePath)
+	configFile, err := ReadConfigFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error opening the specified configuration file: %v ", err)
+		return nil, err
 	}
-	defer file.Close()
-
-	containerConfig := &ContainerInitConfig{
-		Properties: make(map[string]string, 0),
-	}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if separatorIdx := separatorIndex(line); separatorIdx >= 0 {
-			if propertyName := FormatString(line[:separatorIdx]); len(propertyName) > 0 {
-				propertyValue := ""
-				if len(line) > separatorIdx {
-					propertyValue = FormatString(line[separatorIdx+1:])
-				}
-				containerConfig.ValidateAndAddProperty(propertyName, propertyValue)
-			}
-		}
+	if !configFile.HasProfile(profile) {
+		return nil, fmt.Errorf("profile %v not found in the configuration file %v. %v", profile, filePath, DescribeProfiles(configFile.ProfileNames()))
 	}
+	return configFile.ContainerInitConfig(profile), nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading the specified configuration file: %v ", err)
+// ContainerInitConfig returns the configuration of a profile of the file, which is empty if the file does not hold the profile.
+// The values are not validated, see Validate, and the unknown properties are only recorded, see CheckUnknownProperties and WarnAboutUnknownProperties
+func (f *ConfigFile) ContainerInitConfig(profile string) *ContainerInitConfig {
+	properties := f.Profiles[profile]
+
+	containerConfig := NewEmptyContainerInitConfig()
+	containerConfig.FileFormat = f.Format
+	containerConfig.Profile = profile
+	// properties are added in a fixed order, so that any message about them is always printed in the same order
+	for _, propertyName := range orderedNames(properties) {
+		if err := containerConfig.SetPropertyFromSource(propertyName, properties[propertyName], FileSource); err != nil {
+			containerConfig.UnknownProperties[propertyName] = properties[propertyName]
+		}
 	}
-
-	return containerConfig, nil
+	return containerConfig
 }
 
-func (c *ContainerInitConfig) ValidateAndAddProperty(propertyName string, propertyValue string) {
-	c.addPropertyWithOptionalValidation(propertyName, propertyValue, false)
+// DescribeProfiles lists the named profiles for a message, e.g. to suggest which profiles can be selected
+func DescribeProfiles(profileNames []string) string {
+	if len(profileNames) == 0 {
+		return "No named profiles are defined"
+	}
+	return "Available profiles: " + strings.Join(profileNames, ", ")
 }
 
-func (c *ContainerInitConfig) AddProperty(propertyName string, propertyValue string) {
-	c.addPropertyWithOptionalValidation(propertyName, propertyValue, true)
+// Property returns the value of the property with the specified name, and whether it is set
+func (c *ContainerInitConfig) Property(propertyName string) (string, bool) {
+	var value string
+	switch propertyName {
+	case SshKeyPathOnHostPropertyName:
+		value = c.SshKeyPathOnHost
+	case ProxyIpAddressPrefixPropertyName:
+		value = c.ProxyIpAddressPrefix
+	case AnsibleInventoryPathOnHostPropertyName:
+		value = c.AnsibleInventoryPathOnHost
+	case AdditionalSshKeysPropertyName:
+		value = FormatSshKeys(c.AdditionalSshKeys)
+	case JumphostPublicIpAddressPropertyName:
+		value = c.JumphostPublicIpAddress
+	case JumphostPrivateIpAddressPropertyName:
+		value = c.JumphostPrivateIpAddress
+	case RemoteUserPropertyName:
+		value = c.RemoteUser
+	case HostOverridesPropertyName:
+		value = FormatHostOverrides(c.HostOverrides)
+	}
+	return value, value != ""
 }
 
-// AddProperty sets the value of a property on the container configuration struct, validating the value provided
-// It returns whether the property was successfully added (i.e. the value provided was valid and the property existed)
-func (c *ContainerInitConfig) addPropertyWithOptionalValidation(name string, value string, skipValidation bool) bool {
-	switch name {
+// SetProperty sets the value of the property with the specified name, converting paths to absolute paths. The value is not validated.
+// It returns an error if the property is unknown
+func (c *ContainerInitConfig) SetProperty(propertyName string, propertyValue string) error {
+	value := FormatString(propertyValue)
+	switch propertyName {
 	case SshKeyPathOnHostPropertyName:
-		if skipValidation || (!skipValidation && ValidateFilePath(value)) {
-			absPath, ok := ConvertToAbsolutePath(value)
-			if !ok {
-				return false
-			}
-			c.Properties[SshKeyPathOnHostPropertyName] = absPath
-			return true
-		}
+		c.SshKeyPathOnHost = absolutePathIfSet(value)
 	case ProxyIpAddressPrefixPropertyName:
-		if skipValidation || (!skipValidation && ValidateIpAddressPrefix(value)) {
-			c.Properties[ProxyIpAddressPrefixPropertyName] = value
-			return true
-		}
+		c.ProxyIpAddressPrefix = value
 	case AnsibleInventoryPathOnHostPropertyName:
-		if skipValidation || (!skipValidation && ValidateFilePath(value)) {
-			absPath, ok := ConvertToAbsolutePath(value)
-			if !ok {
-				return false
-			}
-			c.Properties[AnsibleInventoryPathOnHostPropertyName] = absPath
-			return true
+		c.AnsibleInventoryPathOnHost = absolutePathIfSet(value)
+	case AdditionalSshKeysPropertyName:
+		sshKeys := ParseSshKeys(value)
+		for i := range sshKeys {
+			sshKeys[i].PathOnHost = absolutePathIfSet(sshKeys[i].PathOnHost)
 		}
+		c.AdditionalSshKeys = sshKeys
+	case JumphostPublicIpAddressPropertyName:
+		c.JumphostPublicIpAddress = value
+	case JumphostPrivateIpAddressPropertyName:
+		c.JumphostPrivateIpAddress = value
+	case RemoteUserPropertyName:
+		c.RemoteUser = value
+	case HostOverridesPropertyName:
+		c.HostOverrides = ParseHostOverrides(value)
 	default:
-		fmt.Printf("Unknown property [name: %v, value: %v] found in property file. This property is being ignored. \n", name, value)
+		return fmt.Errorf("unknown property %v", propertyName)
 	}
-	return false
+	return nil
+}
+
+// absolutePathIfSet converts the path to an absolute path, leaving it as it is if it is empty or cannot be converted
+func absolutePathIfSet(path string) string {
+	if path == "" {
+		return path
+	}
+	if absPath, ok := ConvertToAbsolutePath(path); ok {
+		return absPath
+	}
+	return path
+}
+
+// SetPropertyFromSource is like SetProperty, and also records the source of the value
+func (c *ContainerInitConfig) SetPropertyFromSource(propertyName string, propertyValue string, source PropertySource) error {
+	if err := c.SetProperty(propertyName, propertyValue); err != nil {
+		return err
+	}
+	if c.Sources == nil {
+		c.Sources = make(map[string]PropertySource)
+	}
+	c.Sources[propertyName] = source
+	return nil
+}
+
+// UnsetProperty removes the value of the property with the specified name, and its source
+func (c *ContainerInitConfig) UnsetProperty(propertyName string) {
+	// setting an empty value cannot fail for a known property, and an unknown property has no value to remove
+	_ = c.SetProperty(propertyName, "")
+	delete(c.Sources, propertyName)
+}
+
+// Properties returns the values of the properties that are set, keyed by property name
+func (c *ContainerInitConfig) Properties() map[string]string {
+	properties := make(map[string]string, len(propertyNames))
+	for _, propertyName := range propertyNames {
+		if value, found := c.Property(propertyName); found {
+			properties[propertyName] = value
+		}
+	}
+	return properties
 }
 
 func (c *ContainerInitConfig) IsEmpty() bool {
-	return len(c.Properties) == 0
+	return len(c.Properties()) == 0
 }
 
+// IsFullyPopulated returns whether all the required properties are set, regardless of whether their values are valid
 func (c *ContainerInitConfig) IsFullyPopulated() bool {
-	return len(c.Properties) == 3
+	for _, propertyName := range requiredPropertyNames {
+		if _, found := c.Property(propertyName); !found {
+			return false
+		}
+	}
+	return true
 }
 
 func (c *ContainerInitConfig) PrintProperties() {
-	fmt.Printf("Configuration properties: \n")
-	for k, v := range c.Properties {
-		fmt.Printf(" - %s: %s \n", k, v)
+	if c.Profile == DefaultProfile {
+		logger.Infof("Configuration properties: \n")
+	} else {
+		logger.Infof("Configuration properties of profile %v: \n", c.Profile)
 	}
+	properties := c.Properties()
+	for _, name := range orderedNames(properties) {
+		logger.Infof(" - %s: %s (from %s) \n", name, properties[name], DescribePropertySource(name, c.Sources[name]))
+	}
+}
+
+// EnvVarNameForProperty returns the name of the environment variable that can be used to specify the property, e.g. ZDM_UTIL_SSH_KEY_PATH_ON_HOST
+func EnvVarNameForProperty(name string) string {
+	return EnvVarPrefix + strings.ToUpper(name)
+}
+
+// FlagNameForProperty returns the name of the command-line flag that can be used to specify the property, e.g. sshKeyPathOnHost
+func FlagNameForProperty(name string) string {
+	words := strings.Split(name, "_")
+	for i := 1; i < len(words); i++ {
+		if len(words[i]) > 0 {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
 }
 
 // FormatString removes enclosing quotes if present, and any leading / trailing white spaces
@@ -129,141 +247,58 @@ func FormatString(s string) string {
 	return s
 }
 
-// separatorIndex locates the separator in the string. Valid separators are colon or equal
+// separatorIndex locates the separator in the string. Valid separators are colon or equal, and the first one found is used,
+// so that values can contain the other separator, e.g. ssh_key_path_on_host=C:\keys\id_rsa
 func separatorIndex(s string) int {
-	if index := strings.Index(s, ":"); index > 0 {
-		return index
+	colonIndex := strings.Index(s, ":")
+	equalIndex := strings.Index(s, "=")
+	if colonIndex > 0 && (equalIndex < 0 || colonIndex < equalIndex) {
+		return colonIndex
 	}
-	return strings.Index(s, "=")
+	return equalIndex
 }
 
+// ValidateFilePath checks that the path refers to a readable file, printing the reason if it does not. It can be used as a prompt validator
 func ValidateFilePath(path string) bool {
-	return checkFilePathIsValid(path, true, false)
+	return printFieldError(CheckFilePath(path))
 }
 
 // ValidateFilePathSilently is currently unused but leaving it in for completeness and next step of ZDM util implementation
 func ValidateFilePathSilently(path string) bool {
-	return checkFilePathIsValid(path, false, false)
+	return CheckFilePath(path) == nil
 }
 
 // ValidatePathOfWritableFile is currently unused but leaving it in for completeness and next step of ZDM util implementation
 func ValidatePathOfWritableFile(path string) bool {
-	return checkFilePathIsValid(path, true, true)
+	return printFieldError(CheckPathOfWritableFile(path))
 }
 
 func ValidatePathOfWritableFileSilently(path string) bool {
-	return checkFilePathIsValid(path, false, true)
-}
-
-func checkFilePathIsValid(path string, displayOutput bool, needsWritePermission bool) bool {
-
-	absPath, ok := ConvertToAbsolutePath(path)
-	if !ok {
-		return false
-	}
-	fileInfo, err := os.Stat(absPath)
-	if err != nil {
-		if displayOutput {
-			fmt.Printf("File %v is invalid. Error: %v \n", path, err)
-		}
-		return false
-	}
-	if fileInfo.IsDir() {
-		if displayOutput {
-			fmt.Printf("File %v is actually a directory, not a file \n", path)
-		}
-		return false
-	}
-
-	if !fileInfo.Mode().IsRegular() {
-		if displayOutput {
-			fmt.Printf("File %v is not a regular file \n", path)
-		}
-		return false
-	}
-
-	permissions, err := permbits.Stat(absPath)
-	if err != nil {
-		if displayOutput {
-			fmt.Printf("Permissions for file %v could not be checked. Error: %v \n", path, err)
-		}
-		// TODO should we return false or just show a warning message but still proceed?
-		return false
-	}
-
-	// TODO at the moment we are checking the user permissions - should this be different?
-	// TODO should we also validate that the user running the utility is indeed the user owning the file?
-	// at least read permission is always needed
-	if !permissions.UserRead() {
-		if displayOutput {
-			fmt.Printf("Read permission needed for file %v, but this file is not readable by the user. Permissions: %v \n", path, permissions)
-		}
-		return false
-	}
-
-	if needsWritePermission && ! permissions.UserWrite() {
-		if displayOutput {
-			fmt.Printf("Write permission needed for file %v, but this file is not writable by the user. Permissions: %v \n", path, permissions)
-		}
-		return false
-	}
-
-	return true
+	return CheckPathOfWritableFile(path) == nil
 }
 
+// ValidateIpAddressPrefix checks the prefix, printing the reason if it is not valid. It can be used as a prompt validator
 func ValidateIpAddressPrefix(ipPrefix string) bool {
+	return printFieldError(CheckIpAddressPrefix(ipPrefix))
+}
 
-	trimmedIpPrefix := FormatString(ipPrefix)
-
-	prefixComponents := strings.Split(trimmedIpPrefix, ".")
-
-	if len(prefixComponents) == 1 && prefixComponents[0] == trimmedIpPrefix {
-		fmt.Printf("Malformed IP Address prefix %v. At least one octet must be specified. Example: 172.* or 172.18.* or 172.18.10.* \n", trimmedIpPrefix)
-		return false
-	}
-
-	if len(prefixComponents) > 4 {
-		fmt.Printf("Malformed IP Address prefix %v. Too many octets were specified. Example: 172.* or 172.18.* or 172.18.10.* \n", trimmedIpPrefix)
-		return false
-	}
-
-	if prefixComponents[len(prefixComponents)-1] != "*" {
-		fmt.Printf("Malformed IP Address prefix %v. The least significant byte must be an asterisk. Example: 172.* or 172.18.* or 172.18.10.*  \n", trimmedIpPrefix)
-		return false
-	}
-
-	numberOfAsterisks := strings.Count(trimmedIpPrefix, "*")
-	if numberOfAsterisks == 0 || numberOfAsterisks > 1 {
-		fmt.Printf("Malformed IP Address prefix %v. Exactly one asterisk must be present. Example: 172.* or 172.18.* or 172.18.10.*  \n", trimmedIpPrefix)
-		return false
-	}
-
-	expandedPrefix := ""
-	for i := 0; i < 4; i++ {
-		if i < len(prefixComponents) && prefixComponents[i] != "*" {
-			if expandedPrefix == "" {
-				expandedPrefix = prefixComponents[i]
-			} else {
-				expandedPrefix = expandedPrefix + "." + prefixComponents[i]
-			}
-		} else {
-			expandedPrefix = expandedPrefix + ".0"
-		}
-	}
+// ValidateIPAddress checks the address, printing the reason if it is not valid. It can be used as a prompt validator
+func ValidateIPAddress(ipAddress string) bool {
+	return printFieldError(CheckIPAddress(ipAddress))
+}
 
-	if !ValidateIPAddress(expandedPrefix) {
-		fmt.Printf("Malformed IP Address prefix %v. One or more octets may be out of range. Example: 172.* or 172.18.* or 172.18.10.* \n", trimmedIpPrefix)
-		return false
-	}
-	return true
+// ValidateHostAddress checks that the value is an IP address or a hostname, printing the reason if it is not. It can be used as a prompt validator
+func ValidateHostAddress(address string) bool {
+	return printFieldError(CheckHostAddress(address))
 }
 
-func ValidateIPAddress(ipAddress string) bool {
-	if net.ParseIP(ipAddress) == nil {
-		fmt.Printf("Invalid IP Address %v \n", ipAddress)
-		return false
+// printFieldError prints the rendered message of the error, if any, and returns whether there was no error
+func printFieldError(fieldError *FieldError) bool {
+	if fieldError == nil {
+		return true
 	}
-	return true
+	logger.Infof("%v \n", fieldError.Message())
+	return false
 }
 
 func ConvertToAbsolutePath(path string) (string, bool) {
@@ -272,7 +307,7 @@ func ConvertToAbsolutePath(path string) (string, bool) {
 
 	absPath, err := filepath.Abs(pathWithoutTilde)
 	if err != nil {
-		fmt.Printf("File path %v could not be converted to an absolute path. Error: %v \n", path, err)
+		logger.Infof("File path %v could not be converted to an absolute path. Error: %v \n", path, err)
 		return "", false
 	}
 	return absPath, true
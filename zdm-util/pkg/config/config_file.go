@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileFormat is the format of the configuration file of this utility
+type FileFormat string
+
+const (
+	// LegacyFileFormat is the original format, with one "name: value" or "name=value" property per line
+	LegacyFileFormat FileFormat = "legacy"
+	YamlFileFormat   FileFormat = "yaml"
+	JsonFileFormat   FileFormat = "json"
+
+	// listValueSeparator joins the elements of a list value into a single property value
+	listValueSeparator = ","
+)
+
+// propertyNames lists the known properties in the order in which they are written to the configuration file
+var propertyNames = []string{
+	SshKeyPathOnHostPropertyName,
+	ProxyIpAddressPrefixPropertyName,
+	AnsibleInventoryPathOnHostPropertyName,
+	AdditionalSshKeysPropertyName,
+	JumphostPublicIpAddressPropertyName,
+	JumphostPrivateIpAddressPropertyName,
+	RemoteUserPropertyName,
+	HostOverridesPropertyName,
+}
+
+// requiredPropertyNames lists the properties that must be set for the container to be initialized
+var requiredPropertyNames = []string{
+	SshKeyPathOnHostPropertyName,
+	ProxyIpAddressPrefixPropertyName,
+	AnsibleInventoryPathOnHostPropertyName,
+}
+
+// PropertyNames returns the names of the known properties, in the order in which they are written to the configuration file
+func PropertyNames() []string {
+	return append([]string{}, propertyNames...)
+}
+
+// IsRequiredProperty returns whether the property must be set for the container to be initialized
+func IsRequiredProperty(propertyName string) bool {
+	return containsString(requiredPropertyNames, propertyName)
+}
+
+func ParseFileFormat(s string) (FileFormat, error) {
+	switch FileFormat(strings.ToLower(FormatString(s))) {
+	case LegacyFileFormat:
+		return LegacyFileFormat, nil
+	case YamlFileFormat, "yml":
+		return YamlFileFormat, nil
+	case JsonFileFormat:
+		return JsonFileFormat, nil
+	default:
+		return "", fmt.Errorf("invalid configuration file format %v, valid formats are %v, %v and %v", s, LegacyFileFormat, YamlFileFormat, JsonFileFormat)
+	}
+}
+
+// DetectFileFormat determines the format of a configuration file from its extension or, if the extension is not conclusive, from its content.
+// Content made only of flat "name: value" or "name=value" lines, profile headings and comments is considered legacy even though it is
+// usually also valid YAML, so that legacy files keep being read as such. Any other content that is a YAML mapping is considered YAML
+func DetectFileFormat(filePath string, content []byte) FileFormat {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yml", ".yaml":
+		return YamlFileFormat
+	case ".json":
+		return JsonFileFormat
+	}
+
+	trimmedContent := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmedContent, []byte("{")) {
+		return JsonFileFormat
+	}
+	if isLegacyContent(trimmedContent) {
+		return LegacyFileFormat
+	}
+	if _, err := parseStructuredContent(trimmedContent, YamlFileFormat); err == nil {
+		return YamlFileFormat
+	}
+	return LegacyFileFormat
+}
+
+// isLegacyContent returns whether every line of the content is blank, a comment, a profile heading or a property line that is not indented,
+// which is all a legacy configuration file can contain
+func isLegacyContent(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || isLegacyComment(line) {
+			continue
+		}
+		if _, isHeading := parseLegacyProfileHeading(line); isHeading {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '-' {
+			return false
+		}
+		if separatorIdx := separatorIndex(line); separatorIdx <= 0 || len(FormatString(line[:separatorIdx])) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseConfigFileContent returns the property values of each profile found in the content of a configuration file of the specified format,
+// keyed by profile name. The default profile is always present, even if it has no properties
+func parseConfigFileContent(content []byte, format FileFormat) (map[string]map[string]string, error) {
+	if format == LegacyFileFormat {
+		return parseLegacyContent(content)
+	}
+
+	structuredContent, err := parseStructuredContent(content, format)
+	if err != nil {
+		return nil, err
+	}
+	profiles := map[string]map[string]string{DefaultProfile: make(map[string]string)}
+	if profilesContent, found := structuredContent[profilesKey]; found {
+		if err = parseStructuredProfiles(profiles, profilesContent); err != nil {
+			return nil, err
+		}
+		delete(structuredContent, profilesKey)
+	}
+	if profiles[DefaultProfile], err = structuredPropertiesToStrings(structuredContent); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func structuredPropertiesToStrings(structuredProperties map[string]any) (map[string]string, error) {
+	properties := make(map[string]string, len(structuredProperties))
+	for name, value := range structuredProperties {
+		stringValue, err := propertyValueToString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for property %v: %v", name, err)
+		}
+		properties[name] = stringValue
+	}
+	return properties, nil
+}
+
+// parseStructuredContent parses YAML or JSON content, which must be a mapping of property names to values
+func parseStructuredContent(content []byte, format FileFormat) (map[string]any, error) {
+	structuredContent := make(map[string]any)
+	if len(bytes.TrimSpace(content)) == 0 {
+		return structuredContent, nil
+	}
+
+	var err error
+	switch format {
+	case YamlFileFormat:
+		err = yaml.Unmarshal(content, &structuredContent)
+	case JsonFileFormat:
+		err = json.Unmarshal(content, &structuredContent)
+	default:
+		err = fmt.Errorf("unsupported configuration file format %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return structuredContent, nil
+}
+
+// propertyValueToString converts a scalar value to its string representation, and a list of scalar values to a comma-separated string
+func propertyValueToString(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return FormatString(v), nil
+	case []any:
+		elements := make([]string, 0, len(v))
+		for _, element := range v {
+			if _, isList := element.([]any); isList {
+				return "", fmt.Errorf("nested lists are not supported")
+			}
+			stringElement, err := propertyValueToString(element)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, stringElement)
+		}
+		return strings.Join(elements, listValueSeparator), nil
+	case map[string]any:
+		return "", fmt.Errorf("nested mappings are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// Marshal returns the content of a configuration file of the specified format containing the properties of this configuration,
+// in the section of its profile
+func (c *ContainerInitConfig) Marshal(format FileFormat) ([]byte, error) {
+	configFile := NewEmptyConfigFile()
+	configFile.SetProfileProperties(c.Profile, c.Properties())
+	return configFile.Marshal(format)
+}
+
+// orderedNames returns the names of the known properties found in the map, in their canonical order, followed by any other name sorted alphabetically.
+// The version of the configuration schema comes first
+func orderedNames(properties map[string]string) []string {
+	names := make([]string, 0, len(properties))
+	if _, found := properties[ConfigVersionKey]; found {
+		names = append(names, ConfigVersionKey)
+	}
+	for _, name := range propertyNames {
+		if _, found := properties[name]; found {
+			names = append(names, name)
+		}
+	}
+	otherNames := make([]string, 0)
+	for name := range properties {
+		if !containsString(propertyNames, name) && name != ConfigVersionKey {
+			otherNames = append(otherNames, name)
+		}
+	}
+	sort.Strings(otherNames)
+	return append(names, otherNames...)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isLegacyComment returns whether the line of a legacy configuration file is a comment, which starts with # or ;
+func isLegacyComment(line string) bool {
+	trimmedLine := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmedLine, "#") || strings.HasPrefix(trimmedLine, ";")
+}
+
+// legacySection holds the lines of the default profile or of a named profile of a legacy configuration file
+type legacySection struct {
+	profile string
+	lines   []string
+	// propertyLines maps the index of each property line to the name of the property
+	propertyLines map[int]string
+	written       map[string]bool
+}
+
+// updateLegacyContent updates the properties of a legacy configuration file in place, keeping its comments, blank lines and the order of
+// its properties. Missing profiles are added at the end of the file
+func updateLegacyContent(content []byte, profiles map[string]map[string]string) []byte {
+	newSection := func(profile string) *legacySection {
+		return &legacySection{profile: profile, propertyLines: make(map[int]string), written: make(map[string]bool)}
+	}
+	sections := []*legacySection{newSection(DefaultProfile)}
+	section := sections[0]
+
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if isLegacyComment(line) {
+			section.lines = append(section.lines, line)
+			continue
+		}
+		if profile, isHeading := parseLegacyProfileHeading(line); isHeading {
+			section = newSection(profile)
+			section.lines = append(section.lines, line)
+			sections = append(sections, section)
+			continue
+		}
+		if separatorIdx := separatorIndex(line); separatorIdx >= 0 {
+			if propertyName := FormatString(line[:separatorIdx]); len(propertyName) > 0 {
+				value, found := profiles[section.profile][propertyName]
+				if !found || section.written[propertyName] {
+					// the property was unset, or is a duplicate whose value has already been written
+					continue
+				}
+				if FormatString(line[separatorIdx+1:]) != value {
+					line = fmt.Sprintf("%s: %s", propertyName, value)
+				}
+				section.written[propertyName] = true
+				section.propertyLines[len(section.lines)] = propertyName
+				section.lines = append(section.lines, line)
+				continue
+			}
+		}
+		section.lines = append(section.lines, line)
+	}
+
+	lines := make([]string, 0)
+	writtenProfiles := make(map[string]bool)
+	for _, section := range sections {
+		properties, found := profiles[section.profile]
+		if !found && section.profile != DefaultProfile {
+			continue
+		}
+		writtenProfiles[section.profile] = true
+		lines = append(lines, section.linesWithMissingProperties(properties)...)
+	}
+	for _, profile := range namedProfiles(profiles) {
+		if writtenProfiles[profile] {
+			continue
+		}
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, fmt.Sprintf("[%s]", profile))
+		for _, name := range orderedNames(profiles[profile]) {
+			lines = append(lines, fmt.Sprintf("%s: %s", name, profiles[profile][name]))
+		}
+	}
+
+	if len(lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// linesWithMissingProperties returns the lines of the section with the properties that it does not hold yet. Each of them is added before
+// the first property that comes after it in the canonical order, see propertyRank, or after the last property.
+// If the section has no properties, they are added before its trailing blank lines
+func (s *legacySection) linesWithMissingProperties(properties map[string]string) []string {
+	missingProperties := make(map[string]string)
+	for name, value := range properties {
+		if !s.written[name] {
+			missingProperties[name] = value
+		}
+	}
+	if len(missingProperties) == 0 {
+		return s.lines
+	}
+
+	missingNames := orderedNames(missingProperties)
+	lines := make([]string, 0, len(s.lines)+len(missingNames))
+	addMissingProperties := func(isBefore func(name string) bool) {
+		for len(missingNames) > 0 && isBefore(missingNames[0]) {
+			lines = append(lines, fmt.Sprintf("%s: %s", missingNames[0], missingProperties[missingNames[0]]))
+			missingNames = missingNames[1:]
+		}
+	}
+
+	lastPropertyIndex := -1
+	for i := range s.lines {
+		if _, isProperty := s.propertyLines[i]; isProperty {
+			lastPropertyIndex = i
+		}
+	}
+	insertionIndex := lastPropertyIndex + 1
+	if lastPropertyIndex < 0 {
+		insertionIndex = len(s.lines)
+		for insertionIndex > 0 && strings.TrimSpace(s.lines[insertionIndex-1]) == "" {
+			insertionIndex--
+		}
+	}
+
+	for i, line := range s.lines {
+		if i == insertionIndex {
+			addMissingProperties(func(string) bool { return true })
+		}
+		if propertyName, isProperty := s.propertyLines[i]; isProperty {
+			addMissingProperties(func(name string) bool { return propertyRank(name) < propertyRank(propertyName) })
+		}
+		lines = append(lines, line)
+	}
+	addMissingProperties(func(string) bool { return true })
+	return lines
+}
+
+// updateYamlContent updates the properties of a YAML configuration file in place, keeping its comments and the order of its properties.
+// Missing properties are added in their canonical order, see propertyRank
+func updateYamlContent(content []byte, profiles map[string]map[string]string) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return (&ConfigFile{Format: YamlFileFormat, Profiles: profiles}).Marshal(YamlFileFormat)
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the content of the configuration file is not a mapping")
+	}
+
+	if err := updateYamlMapping(root, profiles[DefaultProfile], profilesKey); err != nil {
+		return nil, err
+	}
+
+	profileNames := namedProfiles(profiles)
+	profilesMapping := yamlMappingValue(root, profilesKey)
+	switch {
+	case profilesMapping == nil && len(profileNames) > 0:
+		profilesMapping = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: profilesKey}, profilesMapping)
+	case profilesMapping != nil && profilesMapping.Kind != yaml.MappingNode:
+		// e.g. an empty profiles key
+		*profilesMapping = yaml.Node{Kind: yaml.MappingNode, HeadComment: profilesMapping.HeadComment, LineComment: profilesMapping.LineComment}
+	}
+
+	if profilesMapping != nil {
+		writtenProfiles := make(map[string]bool)
+		content := make([]*yaml.Node, 0, len(profilesMapping.Content))
+		for i := 0; i+1 < len(profilesMapping.Content); i += 2 {
+			profileKey, profileValue := profilesMapping.Content[i], profilesMapping.Content[i+1]
+			properties, found := profiles[profileKey.Value]
+			if !found || profileKey.Value == DefaultProfile {
+				continue
+			}
+			if profileValue.Kind != yaml.MappingNode {
+				*profileValue = yaml.Node{Kind: yaml.MappingNode, LineComment: profileValue.LineComment}
+			}
+			if err := updateYamlMapping(profileValue, properties, ""); err != nil {
+				return nil, err
+			}
+			writtenProfiles[profileKey.Value] = true
+			content = append(content, profileKey, profileValue)
+		}
+		for _, profile := range profileNames {
+			if !writtenProfiles[profile] {
+				content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: profile}, propertiesNode(profiles[profile]))
+			}
+		}
+		profilesMapping.Content = content
+	}
+
+	return yaml.Marshal(&document)
+}
+
+// updateYamlMapping updates the properties held by the mapping, leaving the reserved key, if any, as it is
+func updateYamlMapping(mapping *yaml.Node, properties map[string]string, reservedKey string) error {
+	written := make(map[string]bool)
+	content := make([]*yaml.Node, 0, len(mapping.Content))
+	// the comment above a removed property is kept above the next one, as it often describes the whole mapping
+	orphanedHeadComment := ""
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		newValue, found := properties[key.Value]
+		isReserved := reservedKey != "" && key.Value == reservedKey
+		if !isReserved && (!found || written[key.Value]) {
+			orphanedHeadComment = joinComments(orphanedHeadComment, key.HeadComment)
+			continue
+		}
+		key.HeadComment = joinComments(orphanedHeadComment, key.HeadComment)
+		orphanedHeadComment = ""
+		if isReserved {
+			content = append(content, key, value)
+			continue
+		}
+		var currentValue any
+		if err := value.Decode(&currentValue); err != nil {
+			return fmt.Errorf("invalid value for property %v: %v", key.Value, err)
+		}
+		if stringValue, err := propertyValueToString(currentValue); err != nil || stringValue != newValue {
+			lineComment := value.LineComment
+			*value = *propertyValueNode(key.Value, newValue)
+			value.LineComment = lineComment
+		}
+		written[key.Value] = true
+		content = append(content, key, value)
+	}
+
+	missingProperties := make(map[string]string)
+	for name, value := range properties {
+		if !written[name] {
+			missingProperties[name] = value
+		}
+	}
+	for _, name := range orderedNames(missingProperties) {
+		insertionIndex := len(content)
+		for i := 0; i < len(content); i += 2 {
+			if propertyRank(content[i].Value) > propertyRank(name) {
+				insertionIndex = i
+				break
+			}
+		}
+		pair := []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: name},
+			propertyValueNode(name, missingProperties[name]),
+		}
+		if insertionIndex == 0 && len(content) > 0 {
+			// the comment at the top of the mapping stays at the top
+			pair[0].HeadComment, content[0].HeadComment = content[0].HeadComment, ""
+		}
+		content = append(content[:insertionIndex], append(pair, content[insertionIndex:]...)...)
+	}
+	mapping.Content = content
+	return nil
+}
+
+func joinComments(first string, second string) string {
+	if first == "" || second == "" {
+		return first + second
+	}
+	return first + "\n" + second
+}
+
+// yamlMappingValue returns the value of the key in the mapping, or nil if the mapping does not hold the key
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// propertyRank orders the properties as in orderedNames, followed by the named profiles
+func propertyRank(name string) int {
+	if name == ConfigVersionKey {
+		return -1
+	}
+	for i, propertyName := range propertyNames {
+		if propertyName == name {
+			return i
+		}
+	}
+	if name == profilesKey {
+		return len(propertyNames) + 1
+	}
+	return len(propertyNames)
+}
+
+// namedProfiles returns the names of the named profiles, sorted alphabetically
+func namedProfiles(profiles map[string]map[string]string) []string {
+	return (&ConfigFile{Profiles: profiles}).ProfileNames()
+}
+
+// WriteConfigFile replaces the content of the configuration file atomically, by writing a temporary file in the same directory and renaming it,
+// so that the configuration file is never left partially written. The file is only accessible by its owner, as it refers to the SSH keys
+func WriteConfigFile(filePath string, content []byte) error {
+	// a symbolic link is kept, and the file it refers to is replaced instead
+	if resolvedFilePath, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = resolvedFilePath
+	}
+
+	// the temporary file is created with permissions 0600
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempFilePath := tempFile.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			_ = tempFile.Close()
+			_ = os.Remove(tempFilePath)
+		}
+	}()
+
+	if _, err = tempFile.Write(content); err != nil {
+		return err
+	}
+	if err = tempFile.Sync(); err != nil {
+		return err
+	}
+	if err = tempFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tempFilePath, filePath); err != nil {
+		return err
+	}
+	renamed = true
+	return nil
+}
@@ -1,10 +1,20 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
 	"zdm-proxy-automation/zdm-util/pkg/testutils"
 )
 
@@ -20,11 +30,9 @@ func TestConfig_WithExistingFile(t *testing.T) {
 			name:           "valid path to valid config file with : separator",
 			configFilePath: "../../testResources/testconfigfile_colon",
 			expectedConfig: &ContainerInitConfig{
-				Properties: map[string]string{
-					SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			isErrorExpected: false,
 		},
@@ -32,11 +40,9 @@ func TestConfig_WithExistingFile(t *testing.T) {
 			name:           "valid path to valid config file with : separator and quotes",
 			configFilePath: "../../testResources/testconfigfile_colon_quotes",
 			expectedConfig: &ContainerInitConfig{
-				Properties: map[string]string{
-					SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			isErrorExpected: false,
 		},
@@ -44,11 +50,29 @@ func TestConfig_WithExistingFile(t *testing.T) {
 			name:           "valid path to valid config file with = separator",
 			configFilePath: "../../testResources/testconfigfile_equals",
 			expectedConfig: &ContainerInitConfig{
-				Properties: map[string]string{
-					SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			},
+			isErrorExpected: false,
+		},
+		{
+			name:           "valid path to valid yaml config file",
+			configFilePath: "../../testResources/testconfigfile.yml",
+			expectedConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			},
+			isErrorExpected: false,
+		},
+		{
+			name:           "valid path to valid json config file",
+			configFilePath: "../../testResources/testconfigfile.json",
+			expectedConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			isErrorExpected: false,
 		},
@@ -70,9 +94,9 @@ func TestConfig_WithExistingFile(t *testing.T) {
 			name:           "valid path to config file with invalid path variables",
 			configFilePath: "../../testResources/testconfigfile_colon_invalidpaths",
 			expectedConfig: &ContainerInitConfig{
-				Properties: map[string]string{
-					ProxyIpAddressPrefixPropertyName: "172.18.*",
-				},
+				SshKeyPathOnHost:           "/home/invalid_dir/invalid_ssh_key",
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: "/home/invalid_dir/invalid_ansible_inventory",
 			},
 			isErrorExpected: false,
 		},
@@ -80,7 +104,7 @@ func TestConfig_WithExistingFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			actualConfig, err := NewContainerInitConfigFromFile(tt.configFilePath)
+			actualConfig, err := NewContainerInitConfigFromFile(tt.configFilePath, DefaultProfile)
 
 			if err != nil {
 				if tt.isErrorExpected {
@@ -89,9 +113,9 @@ func TestConfig_WithExistingFile(t *testing.T) {
 					t.Fatalf("Unexpected error: %v", err)
 				}
 			} else {
-				require.Equal(t, tt.expectedConfig.Properties[SshKeyPathOnHostPropertyName], actualConfig.Properties[SshKeyPathOnHostPropertyName])
-				require.Equal(t, tt.expectedConfig.Properties[ProxyIpAddressPrefixPropertyName], actualConfig.Properties[ProxyIpAddressPrefixPropertyName])
-				require.Equal(t, tt.expectedConfig.Properties[AnsibleInventoryPathOnHostPropertyName], actualConfig.Properties[AnsibleInventoryPathOnHostPropertyName])
+				require.Equal(t, tt.expectedConfig.SshKeyPathOnHost, actualConfig.SshKeyPathOnHost)
+				require.Equal(t, tt.expectedConfig.ProxyIpAddressPrefix, actualConfig.ProxyIpAddressPrefix)
+				require.Equal(t, tt.expectedConfig.AnsibleInventoryPathOnHost, actualConfig.AnsibleInventoryPathOnHost)
 			}
 		})
 	}
@@ -198,6 +222,76 @@ func TestValidateIpAddressPrefix(t *testing.T) {
 			ipAddressPrefix: "17218100",
 			expectedValid:   false,
 		},
+		{
+			name:            "valid cidr range",
+			ipAddressPrefix: "10.0.16.0/20",
+			expectedValid:   true,
+		},
+		{
+			name:            "valid cidr range, leading and trailing spaces",
+			ipAddressPrefix: "  172.18.0.0/16  ",
+			expectedValid:   true,
+		},
+		{
+			name:            "invalid cidr range, prefix length out of range",
+			ipAddressPrefix: "10.0.16.0/33",
+			expectedValid:   false,
+		},
+		{
+			name:            "invalid cidr range, octet out of range",
+			ipAddressPrefix: "10.0.300.0/24",
+			expectedValid:   false,
+		},
+		{
+			name:            "valid ipv6 cidr range",
+			ipAddressPrefix: "fd00::/64",
+			expectedValid:   true,
+		},
+		{
+			name:            "invalid ipv6 cidr range, prefix length not multiple of 16",
+			ipAddressPrefix: "fd00::/60",
+			expectedValid:   false,
+		},
+		{
+			name:            "valid ipv6 prefix, one group",
+			ipAddressPrefix: "fd00:*",
+			expectedValid:   true,
+		},
+		{
+			name:            "valid ipv6 prefix, compressed groups",
+			ipAddressPrefix: "fd00::10:*",
+			expectedValid:   true,
+		},
+		{
+			name:            "valid ipv6 prefix, seven groups",
+			ipAddressPrefix: "fd00:10:20:30:40:50:60:*",
+			expectedValid:   true,
+		},
+		{
+			name:            "invalid ipv6 prefix, no asterisk",
+			ipAddressPrefix: "fd00:10::",
+			expectedValid:   false,
+		},
+		{
+			name:            "invalid ipv6 prefix, uppercase digits",
+			ipAddressPrefix: "FD00:10:*",
+			expectedValid:   false,
+		},
+		{
+			name:            "invalid ipv6 prefix, leading zeros",
+			ipAddressPrefix: "fd00:0010:*",
+			expectedValid:   false,
+		},
+		{
+			name:            "invalid ipv6 prefix, too many groups",
+			ipAddressPrefix: "fd00:10:20:30:40:50:60:70:*",
+			expectedValid:   false,
+		},
+		{
+			name:            "invalid ipv6 prefix, two compressed runs",
+			ipAddressPrefix: "fd00::10::*",
+			expectedValid:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -293,3 +387,1226 @@ func TestResolveTildeInPath(t *testing.T) {
 		})
 	}
 }
+
+func TestPropertyNameConversions(t *testing.T) {
+	require.Equal(t, "sshKeyPathOnHost", FlagNameForProperty(SshKeyPathOnHostPropertyName))
+	require.Equal(t, "ZDM_UTIL_SSH_KEY_PATH_ON_HOST", EnvVarNameForProperty(SshKeyPathOnHostPropertyName))
+	require.Equal(t, "proxyIpAddressPrefix", FlagNameForProperty(ProxyIpAddressPrefixPropertyName))
+	require.Equal(t, "ZDM_UTIL_PROXY_IP_ADDRESS_PREFIX", EnvVarNameForProperty(ProxyIpAddressPrefixPropertyName))
+}
+
+func TestDetectFileFormat(t *testing.T) {
+	tests := []struct {
+		name           string
+		filePath       string
+		content        string
+		expectedFormat FileFormat
+	}{
+		{
+			name:           "yml extension",
+			filePath:       "config.yml",
+			content:        "proxy_ip_address_prefix=172.18.*",
+			expectedFormat: YamlFileFormat,
+		},
+		{
+			name:           "json extension",
+			filePath:       "config.JSON",
+			content:        "",
+			expectedFormat: JsonFileFormat,
+		},
+		{
+			name:           "no extension, json content",
+			filePath:       "ansible_container_init_config",
+			content:        "  {\"proxy_ip_address_prefix\": \"172.18.*\"}",
+			expectedFormat: JsonFileFormat,
+		},
+		{
+			name:           "no extension, flat content valid as yaml",
+			filePath:       "ansible_container_init_config",
+			content:        "proxy_ip_address_prefix: 172.18.*\nssh_key_path_on_host: C:\\keys\\id_rsa\n",
+			expectedFormat: LegacyFileFormat,
+		},
+		{
+			name:           "no extension, legacy content with profiles and comments",
+			filePath:       "ansible_container_init_config",
+			content:        "# defaults\nproxy_ip_address_prefix: 172.18.*\n\n[staging]\r\n; staging network\r\nproxy_ip_address_prefix: 172.19.*\r\n",
+			expectedFormat: LegacyFileFormat,
+		},
+		{
+			name:           "no extension, yaml content with profiles",
+			filePath:       "ansible_container_init_config",
+			content:        "proxy_ip_address_prefix: 172.18.*\nprofiles:\n  staging:\n    proxy_ip_address_prefix: 172.19.*\n",
+			expectedFormat: YamlFileFormat,
+		},
+		{
+			name:           "no extension, yaml content with list",
+			filePath:       "ansible_container_init_config",
+			content:        "proxy_ip_address_prefix: 172.18.*\nsome_list:\n- first\n- second\n",
+			expectedFormat: YamlFileFormat,
+		},
+		{
+			name:           "no extension, legacy content with = separator",
+			filePath:       "ansible_container_init_config",
+			content:        "proxy_ip_address_prefix=172.18.*\nssh_key_path_on_host=/home/my_key\n",
+			expectedFormat: LegacyFileFormat,
+		},
+		{
+			name:           "no extension, empty content",
+			filePath:       "ansible_container_init_config",
+			content:        "",
+			expectedFormat: LegacyFileFormat,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedFormat, DetectFileFormat(tt.filePath, []byte(tt.content)))
+		})
+	}
+}
+
+func TestParseConfigFileContent(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		format           FileFormat
+		expectedProfiles map[string]map[string]string
+		isErrorExpected  bool
+	}{
+		{
+			name:    "legacy, windows path with = separator",
+			content: "ssh_key_path_on_host=C:\\keys\\id_rsa\nproxy_ip_address_prefix : \"172.18.*\"\n",
+			format:  LegacyFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {
+					SshKeyPathOnHostPropertyName:     "C:\\keys\\id_rsa",
+					ProxyIpAddressPrefixPropertyName: "172.18.*",
+				},
+			},
+		},
+		{
+			name:    "yaml, windows path and list",
+			content: "ssh_key_path_on_host: C:\\keys\\id_rsa\nsome_list:\n  - first\n  - 2\n",
+			format:  YamlFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {
+					SshKeyPathOnHostPropertyName: "C:\\keys\\id_rsa",
+					"some_list":                  "first,2",
+				},
+			},
+		},
+		{
+			name:    "json, list",
+			content: `{"some_list": ["first", "second"], "proxy_ip_address_prefix": "172.18.*"}`,
+			format:  JsonFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {
+					ProxyIpAddressPrefixPropertyName: "172.18.*",
+					"some_list":                      "first,second",
+				},
+			},
+		},
+		{
+			name:    "legacy, profiles",
+			content: "proxy_ip_address_prefix: 172.18.*\n\n[staging]\nproxy_ip_address_prefix: 172.19.*\n[ prod-eu ]\nssh_key_path_on_host=C:\\keys\\id_rsa\n",
+			format:  LegacyFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {ProxyIpAddressPrefixPropertyName: "172.18.*"},
+				"staging":      {ProxyIpAddressPrefixPropertyName: "172.19.*"},
+				"prod-eu":      {SshKeyPathOnHostPropertyName: "C:\\keys\\id_rsa"},
+			},
+		},
+		{
+			name:    "yaml, profiles",
+			content: "profiles:\n  staging:\n    proxy_ip_address_prefix: 172.19.*\n  prod-eu:\n",
+			format:  YamlFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {},
+				"staging":      {ProxyIpAddressPrefixPropertyName: "172.19.*"},
+				"prod-eu":      {},
+			},
+		},
+		{
+			name:    "json, profiles",
+			content: `{"proxy_ip_address_prefix": "172.18.*", "profiles": {"staging": {"proxy_ip_address_prefix": "172.19.*"}}}`,
+			format:  JsonFileFormat,
+			expectedProfiles: map[string]map[string]string{
+				DefaultProfile: {ProxyIpAddressPrefixPropertyName: "172.18.*"},
+				"staging":      {ProxyIpAddressPrefixPropertyName: "172.19.*"},
+			},
+		},
+		{
+			name:            "legacy, profile defined twice",
+			content:         "[staging]\n[staging]\n",
+			format:          LegacyFileFormat,
+			isErrorExpected: true,
+		},
+		{
+			name:            "legacy, invalid profile name",
+			content:         "[prod eu]\n",
+			format:          LegacyFileFormat,
+			isErrorExpected: true,
+		},
+		{
+			name:            "yaml, profiles not a mapping",
+			content:         "profiles:\n  - staging\n",
+			format:          YamlFileFormat,
+			isErrorExpected: true,
+		},
+		{
+			name:            "yaml, nested mapping",
+			content:         "ssh_key_path_on_host:\n  path: /home/my_key\n",
+			format:          YamlFileFormat,
+			isErrorExpected: true,
+		},
+		{
+			name:            "json, malformed",
+			content:         `{"proxy_ip_address_prefix": }`,
+			format:          JsonFileFormat,
+			isErrorExpected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualProfiles, err := parseConfigFileContent([]byte(tt.content), tt.format)
+			if tt.isErrorExpected {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedProfiles, actualProfiles)
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	containerConfig := &ContainerInitConfig{
+		SshKeyPathOnHost:           "C:\\keys\\id_rsa",
+		ProxyIpAddressPrefix:       "172.18.*",
+		AnsibleInventoryPathOnHost: "/home/my_path/my_inventory",
+	}
+
+	tests := []struct {
+		name            string
+		format          FileFormat
+		expectedContent string
+	}{
+		{
+			name:   "legacy",
+			format: LegacyFileFormat,
+			expectedContent: "config_version: 2\n" +
+				"ssh_key_path_on_host: C:\\keys\\id_rsa\n" +
+				"proxy_ip_address_prefix: 172.18.*\n" +
+				"ansible_inventory_path_on_host: /home/my_path/my_inventory\n",
+		},
+		{
+			name:   "yaml",
+			format: YamlFileFormat,
+			expectedContent: "config_version: 2\n" +
+				"ssh_key_path_on_host: C:\\keys\\id_rsa\n" +
+				"proxy_ip_address_prefix: 172.18.*\n" +
+				"ansible_inventory_path_on_host: /home/my_path/my_inventory\n",
+		},
+		{
+			name:   "json",
+			format: JsonFileFormat,
+			expectedContent: "{\n" +
+				"  \"config_version\": 2,\n" +
+				"  \"ssh_key_path_on_host\": \"C:\\\\keys\\\\id_rsa\",\n" +
+				"  \"proxy_ip_address_prefix\": \"172.18.*\",\n" +
+				"  \"ansible_inventory_path_on_host\": \"/home/my_path/my_inventory\"\n" +
+				"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := containerConfig.Marshal(tt.format)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedContent, string(content))
+
+			// the written content must be read back with the same properties. Legacy content is detected as yaml, which it is compatible with
+			properties, err := parseConfigFileContent(content, DetectFileFormat("ansible_container_init_config", content))
+			require.Nil(t, err)
+			require.Equal(t, "2", properties[DefaultProfile][ConfigVersionKey])
+			delete(properties[DefaultProfile], ConfigVersionKey)
+			require.Equal(t, containerConfig.Properties(), properties[DefaultProfile])
+		})
+	}
+}
+
+func TestConfigFile_MarshalProfiles(t *testing.T) {
+	configFile := NewEmptyConfigFile()
+	configFile.SetProfileProperties(DefaultProfile, map[string]string{ProxyIpAddressPrefixPropertyName: "172.18.*"})
+	configFile.SetProfileProperties("staging", map[string]string{
+		ProxyIpAddressPrefixPropertyName: "172.19.*",
+		SshKeyPathOnHostPropertyName:     "/home/me/staging_key",
+	})
+	configFile.SetProfileProperties("prod-eu", map[string]string{ProxyIpAddressPrefixPropertyName: "172.20.*"})
+
+	tests := []struct {
+		name            string
+		format          FileFormat
+		expectedContent string
+	}{
+		{
+			name:   "legacy",
+			format: LegacyFileFormat,
+			expectedContent: "config_version: 2\n" +
+				"proxy_ip_address_prefix: 172.18.*\n" +
+				"\n" +
+				"[prod-eu]\n" +
+				"proxy_ip_address_prefix: 172.20.*\n" +
+				"\n" +
+				"[staging]\n" +
+				"ssh_key_path_on_host: /home/me/staging_key\n" +
+				"proxy_ip_address_prefix: 172.19.*\n",
+		},
+		{
+			name:   "yaml",
+			format: YamlFileFormat,
+			expectedContent: "config_version: 2\n" +
+				"proxy_ip_address_prefix: 172.18.*\n" +
+				"profiles:\n" +
+				"    prod-eu:\n" +
+				"        proxy_ip_address_prefix: 172.20.*\n" +
+				"    staging:\n" +
+				"        ssh_key_path_on_host: /home/me/staging_key\n" +
+				"        proxy_ip_address_prefix: 172.19.*\n",
+		},
+		{
+			name:   "json",
+			format: JsonFileFormat,
+			expectedContent: "{\n" +
+				"  \"config_version\": 2,\n" +
+				"  \"proxy_ip_address_prefix\": \"172.18.*\",\n" +
+				"  \"profiles\": {\n" +
+				"    \"prod-eu\": {\n" +
+				"      \"proxy_ip_address_prefix\": \"172.20.*\"\n" +
+				"    },\n" +
+				"    \"staging\": {\n" +
+				"      \"ssh_key_path_on_host\": \"/home/me/staging_key\",\n" +
+				"      \"proxy_ip_address_prefix\": \"172.19.*\"\n" +
+				"    }\n" +
+				"  }\n" +
+				"}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := configFile.Marshal(tt.format)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedContent, string(content))
+
+			detectedFormat := DetectFileFormat("ansible_container_init_config", content)
+			require.Equal(t, tt.format, detectedFormat)
+			profiles, err := parseConfigFileContent(content, detectedFormat)
+			require.Nil(t, err)
+			require.Equal(t, "2", profiles[DefaultProfile][ConfigVersionKey])
+			delete(profiles[DefaultProfile], ConfigVersionKey)
+			require.Equal(t, configFile.Profiles, profiles)
+		})
+	}
+}
+
+func TestNewContainerInitConfigFromFile_Profiles(t *testing.T) {
+	sshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key")
+	configFilePath := filepath.Join(t.TempDir(), "ansible_container_init_config")
+	content := "proxy_ip_address_prefix: 172.18.*\n\n[staging]\nssh_key_path_on_host: " + sshKeyPath + "\nproxy_ip_address_prefix: 172.19.*\n"
+	require.Nil(t, os.WriteFile(configFilePath, []byte(content), 0600))
+
+	defaultConfig, err := NewContainerInitConfigFromFile(configFilePath, DefaultProfile)
+	require.Nil(t, err)
+	require.Equal(t, DefaultProfile, defaultConfig.Profile)
+	require.Equal(t, "172.18.*", defaultConfig.ProxyIpAddressPrefix)
+	require.Equal(t, "", defaultConfig.SshKeyPathOnHost)
+
+	stagingConfig, err := NewContainerInitConfigFromFile(configFilePath, "staging")
+	require.Nil(t, err)
+	require.Equal(t, "staging", stagingConfig.Profile)
+	require.Equal(t, "172.19.*", stagingConfig.ProxyIpAddressPrefix)
+	require.Equal(t, sshKeyPath, stagingConfig.SshKeyPathOnHost)
+
+	_, err = NewContainerInitConfigFromFile(configFilePath, "prod-eu")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "Available profiles: staging")
+}
+
+func TestCheckProfileName(t *testing.T) {
+	require.Nil(t, CheckProfileName("prod-eu"))
+	require.Nil(t, CheckProfileName("staging_2.1"))
+	require.NotNil(t, CheckProfileName(""))
+	require.NotNil(t, CheckProfileName("-staging"))
+	require.NotNil(t, CheckProfileName("prod eu"))
+	require.NotNil(t, CheckProfileName("prod/eu"))
+}
+
+func TestApplyLayers(t *testing.T) {
+	containerConfig := NewEmptyContainerInitConfig()
+	require.Nil(t, containerConfig.SetPropertyFromSource(ProxyIpAddressPrefixPropertyName, "172.18.*", FileSource))
+	require.Nil(t, containerConfig.SetPropertyFromSource(SshKeyPathOnHostPropertyName, "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key", FileSource))
+
+	containerConfig.ApplyLayers(
+		NewFlagLayer(map[string]string{SshKeyPathOnHostPropertyName: "", ProxyIpAddressPrefixPropertyName: "not_a_prefix"}),
+		PropertyLayer{Source: EnvVarSource, Properties: map[string]string{
+			ProxyIpAddressPrefixPropertyName:       "10.0.*",
+			AnsibleInventoryPathOnHostPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+		}})
+
+	require.Equal(t, map[string]string{
+		SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+		ProxyIpAddressPrefixPropertyName:       "not_a_prefix",
+		AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+	}, containerConfig.Properties())
+	require.Equal(t, map[string]PropertySource{
+		SshKeyPathOnHostPropertyName:           FileSource,
+		ProxyIpAddressPrefixPropertyName:       FlagSource,
+		AnsibleInventoryPathOnHostPropertyName: EnvVarSource,
+	}, containerConfig.Sources)
+	require.Equal(t, "environment variable ZDM_UTIL_ANSIBLE_INVENTORY_PATH_ON_HOST", DescribePropertySource(AnsibleInventoryPathOnHostPropertyName, EnvVarSource))
+
+	// the invalid flag value does not fall back to the environment variable, and is reported by Validate
+	validationErrors := containerConfig.Validate()
+	require.Equal(t, 1, len(validationErrors))
+	require.Equal(t, "proxy_ip_address_prefix: Malformed IP Address prefix not_a_prefix. At least one octet must be specified. Example: 172.* or 172.18.* or 172.18.10.* or fd00:10:* (from flag -proxyIpAddressPrefix)",
+		validationErrors[0].Error())
+}
+
+func TestValidate(t *testing.T) {
+	sshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key")
+	inventoryPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory")
+	tests := []struct {
+		name            string
+		containerConfig *ContainerInitConfig
+		expectedErrors  ValidationErrors
+	}{
+		{
+			name: "valid configuration",
+			containerConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           sshKeyPath,
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: inventoryPath,
+			},
+			expectedErrors: ValidationErrors{},
+		},
+		{
+			name:            "empty configuration",
+			containerConfig: NewEmptyContainerInitConfig(),
+			expectedErrors: ValidationErrors{
+				{Field: SshKeyPathOnHostPropertyName, Reason: "not provided",
+					Hint: "Specify it with flag -sshKeyPathOnHost, environment variable ZDM_UTIL_SSH_KEY_PATH_ON_HOST or in the configuration file"},
+				{Field: ProxyIpAddressPrefixPropertyName, Reason: "not provided",
+					Hint: "Specify it with flag -proxyIpAddressPrefix, environment variable ZDM_UTIL_PROXY_IP_ADDRESS_PREFIX or in the configuration file"},
+				{Field: AnsibleInventoryPathOnHostPropertyName, Reason: "not provided",
+					Hint: "Specify it with flag -ansibleInventoryPathOnHost, environment variable ZDM_UTIL_ANSIBLE_INVENTORY_PATH_ON_HOST or in the configuration file"},
+			},
+		},
+		{
+			name: "all values invalid",
+			containerConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           "/home/invalid_dir/invalid_ssh_key",
+				ProxyIpAddressPrefix:       "172.300.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir"),
+				Sources:                    map[string]PropertySource{ProxyIpAddressPrefixPropertyName: EnvVarSource},
+			},
+			expectedErrors: ValidationErrors{
+				{Field: SshKeyPathOnHostPropertyName, Value: "/home/invalid_dir/invalid_ssh_key",
+					Reason: "File /home/invalid_dir/invalid_ssh_key is invalid. Error: stat /home/invalid_dir/invalid_ssh_key: no such file or directory"},
+				{Field: ProxyIpAddressPrefixPropertyName, Value: "172.300.*", Source: EnvVarSource,
+					Reason: "Malformed IP Address prefix 172.300.*. One or more octets may be out of range", Hint: "Example: 172.* or 172.18.* or 172.18.10.* or fd00:10:*"},
+				{Field: AnsibleInventoryPathOnHostPropertyName, Value: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir"),
+					Reason: "File " + testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir") + " is actually a directory, not a file"},
+			},
+		},
+		{
+			name: "valid configuration with a jumphost",
+			containerConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           sshKeyPath,
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: inventoryPath,
+				JumphostPublicIpAddress:    "203.0.113.10",
+				JumphostPrivateIpAddress:   "172.18.1.10",
+			},
+			expectedErrors: ValidationErrors{},
+		},
+		{
+			name: "jumphost private IP address without public IP address",
+			containerConfig: &ContainerInitConfig{
+				SshKeyPathOnHost:           sshKeyPath,
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: inventoryPath,
+				JumphostPrivateIpAddress:   "172.18.1.300",
+			},
+			expectedErrors: ValidationErrors{
+				{Field: JumphostPrivateIpAddressPropertyName, Value: "172.18.1.300", Reason: "Invalid IP Address or hostname 172.18.1.300",
+					Hint: "A hostname is made of labels separated by dots, each made of letters, digits and hyphens, e.g. proxy-0.example.internal"},
+				{Field: JumphostPrivateIpAddressPropertyName, Value: "172.18.1.300",
+					Reason: "The private IP address of the jumphost is set, but not its public IP address",
+					Hint:   "Specify jumphost_public_ip_address too, or remove jumphost_private_ip_address if the proxies can be reached directly"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedErrors, tt.containerConfig.Validate())
+		})
+	}
+}
+
+func TestFieldError_Message(t *testing.T) {
+	fieldError := CheckIpAddressPrefix("172.18.10.0")
+	require.NotNil(t, fieldError)
+	require.Equal(t, "Malformed IP Address prefix 172.18.10.0. The least significant byte must be an asterisk. Example: 172.* or 172.18.* or 172.18.10.* or fd00:10:*", fieldError.Message())
+	require.Nil(t, CheckIpAddressPrefix("172.18.10.*"))
+	require.Nil(t, CheckIPAddress("172.18.10.1"))
+}
+
+func TestParseSshKeys(t *testing.T) {
+	tests := []struct {
+		name            string
+		value           string
+		expectedSshKeys []SshKey
+	}{
+		{
+			name:            "empty value",
+			value:           "",
+			expectedSshKeys: nil,
+		},
+		{
+			name:  "single key with one host pattern",
+			value: "/home/me/monitoring_key=172.18.10.5",
+			expectedSshKeys: []SshKey{
+				{PathOnHost: "/home/me/monitoring_key", HostPatterns: []string{"172.18.10.5"}},
+			},
+		},
+		{
+			name:  "several keys with several host patterns and spaces",
+			value: " /home/me/monitoring_key = 172.18.10.5 ,/home/me/other_key=172.19.*  172.20.* ",
+			expectedSshKeys: []SshKey{
+				{PathOnHost: "/home/me/monitoring_key", HostPatterns: []string{"172.18.10.5"}},
+				{PathOnHost: "/home/me/other_key", HostPatterns: []string{"172.19.*", "172.20.*"}},
+			},
+		},
+		{
+			name:  "path containing the separator",
+			value: "C:\\keys\\key=1=172.19.*",
+			expectedSshKeys: []SshKey{
+				{PathOnHost: "C:\\keys\\key=1", HostPatterns: []string{"172.19.*"}},
+			},
+		},
+		{
+			name:  "malformed key without host patterns is kept",
+			value: "/home/me/monitoring_key",
+			expectedSshKeys: []SshKey{
+				{PathOnHost: "/home/me/monitoring_key"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshKeys := ParseSshKeys(tt.value)
+			require.Equal(t, tt.expectedSshKeys, sshKeys)
+			require.Equal(t, sshKeys, ParseSshKeys(FormatSshKeys(sshKeys)))
+		})
+	}
+}
+
+func TestHostPatterns(t *testing.T) {
+	tests := []struct {
+		name                 string
+		value                string
+		expectedHostPatterns []string
+		expectedError        bool
+	}{
+		{
+			name:                 "wildcard prefix is kept as is",
+			value:                "172.18.*",
+			expectedHostPatterns: []string{"172.18.*"},
+		},
+		{
+			name:                 "single address is kept as is",
+			value:                "172.18.10.5",
+			expectedHostPatterns: []string{"172.18.10.5"},
+		},
+		{
+			name:                 "range aligned on an octet",
+			value:                "10.0.0.0/16",
+			expectedHostPatterns: []string{"10.0.*"},
+		},
+		{
+			name:                 "range of the third octet",
+			value:                "10.0.16.0/20",
+			expectedHostPatterns: []string{"10.0.16.*", "10.0.17.*", "10.0.18.*", "10.0.19.*", "10.0.2?.*", "10.0.30.*", "10.0.31.*"},
+		},
+		{
+			name:  "range of the last octet",
+			value: "192.168.1.96/27",
+			expectedHostPatterns: []string{"192.168.1.96", "192.168.1.97", "192.168.1.98", "192.168.1.99", "192.168.1.10?", "192.168.1.11?",
+				"192.168.1.120", "192.168.1.121", "192.168.1.122", "192.168.1.123", "192.168.1.124", "192.168.1.125", "192.168.1.126", "192.168.1.127"},
+		},
+		{
+			name:                 "range covering whole hundreds",
+			value:                "10.0.0.0/9",
+			expectedHostPatterns: []string{"10.?.*", "10.??.*", "10.10?.*", "10.11?.*", "10.120.*", "10.121.*", "10.122.*", "10.123.*", "10.124.*", "10.125.*", "10.126.*", "10.127.*"},
+		},
+		{
+			name:                 "host bits are ignored",
+			value:                "10.0.17.3/22",
+			expectedHostPatterns: []string{"10.0.16.*", "10.0.17.*", "10.0.18.*", "10.0.19.*"},
+		},
+		{
+			name:                 "single address range",
+			value:                "10.0.16.5/32",
+			expectedHostPatterns: []string{"10.0.16.5"},
+		},
+		{
+			name:                 "all addresses",
+			value:                "0.0.0.0/0",
+			expectedHostPatterns: []string{"*"},
+		},
+		{
+			name:          "malformed range",
+			value:         "10.0.16.0/40",
+			expectedError: true,
+		},
+		{
+			name:                 "ipv6 range",
+			value:                "fd00:10:20:30::/64",
+			expectedHostPatterns: []string{"fd00:10:20:30:*"},
+		},
+		{
+			name:                 "ipv6 range with compressible zero groups",
+			value:                "fd00:0:0:10::/64",
+			expectedHostPatterns: []string{"fd00:0:0:10:*", "fd00::10:*"},
+		},
+		{
+			name:                 "ipv6 range ending with zero groups",
+			value:                "fd00:10::/48",
+			expectedHostPatterns: []string{"fd00:10:0:*", "fd00:10::*"},
+		},
+		{
+			name:                 "single ipv6 address range",
+			value:                "fd00:0:0:10:0:0:0:5/128",
+			expectedHostPatterns: []string{"fd00:0:0:10::5"},
+		},
+		{
+			name:          "ipv6 range with prefix length not multiple of 16",
+			value:         "fd00::/60",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostPatterns, err := HostPatterns(tt.value)
+			if tt.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedHostPatterns, hostPatterns)
+		})
+	}
+}
+
+func TestMatchesIpAddressPrefix(t *testing.T) {
+	tests := []struct {
+		name            string
+		ipPrefix        string
+		ipAddress       string
+		expectedMatches bool
+	}{
+		{"wildcard prefix", "172.18.*", "172.18.10.5", true},
+		{"other network", "172.18.*", "172.19.10.5", false},
+		{"cidr range", "10.0.16.0/22", "10.0.19.200", true},
+		{"outside of the cidr range", "10.0.16.0/22", "10.0.20.1", false},
+		{"ipv6 prefix", "fd00:10:*", "fd00:10:0:0:0:0:0:5", true},
+		{"hostname", "172.18.*", "proxy-0.example.internal", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedMatches, MatchesIpAddressPrefix(tt.ipPrefix, tt.ipAddress))
+		})
+	}
+}
+
+func TestCheckHostAddress(t *testing.T) {
+	tests := []struct {
+		name          string
+		address       string
+		expectedValid bool
+	}{
+		{"ipv4 address", "172.18.10.5", true},
+		{"ipv6 address", "fd00::5", true},
+		{"fully qualified hostname", "proxy-0.example.internal", true},
+		{"single label hostname", "proxy0", true},
+		{"underscore", "proxy_0.example.internal", false},
+		{"label starting with a hyphen", "-proxy.example.internal", false},
+		{"malformed ip address", "172.18.10.256", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fieldError := CheckHostAddress(tt.address)
+			if tt.expectedValid {
+				require.Nil(t, fieldError)
+				return
+			}
+			require.NotNil(t, fieldError)
+			require.Equal(t, fmt.Sprintf("Invalid IP Address or hostname %v", tt.address), fieldError.Reason)
+		})
+	}
+}
+
+func TestValidate_AdditionalSshKeys(t *testing.T) {
+	sshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key")
+	monitoringSshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_monitoring_ssh_key")
+	tests := []struct {
+		name           string
+		sshKeys        []SshKey
+		expectedValues []string
+	}{
+		{
+			name: "valid keys, including the main key for other hosts",
+			sshKeys: []SshKey{
+				{PathOnHost: monitoringSshKeyPath, HostPatterns: []string{"172.18.10.5", "monitoring-*"}},
+				{PathOnHost: sshKeyPath, HostPatterns: []string{"172.19.*"}},
+			},
+			expectedValues: []string{},
+		},
+		{
+			name: "malformed key and invalid host pattern",
+			sshKeys: []SshKey{
+				{PathOnHost: monitoringSshKeyPath},
+				{PathOnHost: sshKeyPath, HostPatterns: []string{"172.19.*", "#172.20.*"}},
+			},
+			expectedValues: []string{monitoringSshKeyPath + "=", "#172.20.*"},
+		},
+		{
+			name: "different keys with the same file name as the main key",
+			sshKeys: []SshKey{
+				{PathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_ssh_key"), HostPatterns: []string{"172.19.*"}},
+			},
+			expectedValues: []string{
+				testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_ssh_key"),
+				testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_ssh_key"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerConfig := &ContainerInitConfig{
+				SshKeyPathOnHost:           sshKeyPath,
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				AdditionalSshKeys:          tt.sshKeys,
+			}
+			actualValues := make([]string, 0)
+			for _, fieldError := range containerConfig.Validate() {
+				require.Equal(t, AdditionalSshKeysPropertyName, fieldError.Field)
+				actualValues = append(actualValues, fieldError.Value)
+			}
+			require.Equal(t, tt.expectedValues, actualValues)
+		})
+	}
+}
+
+func TestParseHostOverrides(t *testing.T) {
+	tests := []struct {
+		name                  string
+		value                 string
+		expectedHostOverrides []HostOverride
+	}{
+		{
+			name:                  "empty value",
+			value:                 "",
+			expectedHostOverrides: nil,
+		},
+		{
+			name:  "group user, host user and port, host port with spaces",
+			value: " monitoring=centos, 172.18.10.5 = rocky:2222 ,fd00::5=:2222",
+			expectedHostOverrides: []HostOverride{
+				{Target: "monitoring", User: "centos"},
+				{Target: "172.18.10.5", User: "rocky", Port: "2222"},
+				{Target: "fd00::5", Port: "2222"},
+			},
+		},
+		{
+			name:  "malformed override without user and port is kept",
+			value: "proxies",
+			expectedHostOverrides: []HostOverride{
+				{Target: "proxies"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostOverrides := ParseHostOverrides(tt.value)
+			require.Equal(t, tt.expectedHostOverrides, hostOverrides)
+			require.Equal(t, hostOverrides, ParseHostOverrides(FormatHostOverrides(hostOverrides)))
+		})
+	}
+}
+
+func TestValidate_RemoteUserAndHostOverrides(t *testing.T) {
+	tests := []struct {
+		name           string
+		remoteUser     string
+		hostOverrides  string
+		expectedFields []string
+		expectedValues []string
+	}{
+		{
+			name:           "valid user and overrides",
+			remoteUser:     "ec2-user",
+			hostOverrides:  "monitoring=centos,proxies=:2222,172.18.10.5=rocky:22,proxy-1.example.internal=:2200",
+			expectedFields: []string{},
+			expectedValues: []string{},
+		},
+		{
+			name:           "invalid user",
+			remoteUser:     "Administrator",
+			expectedFields: []string{RemoteUserPropertyName},
+			expectedValues: []string{"Administrator"},
+		},
+		{
+			name:           "malformed override, unknown group, invalid user and port",
+			hostOverrides:  "proxies=,bastion_host=centos,monitoring=root@centos:65536",
+			expectedFields: []string{HostOverridesPropertyName, HostOverridesPropertyName, HostOverridesPropertyName, HostOverridesPropertyName},
+			expectedValues: []string{"proxies=", "bastion_host", "root@centos", "65536"},
+		},
+		{
+			name:           "hosts overridden twice",
+			hostOverrides:  "fd00::5=rocky,FD00:0:0:0:0:0:0:5=:2222,proxy-0.example.internal=rocky,Proxy-0.example.internal=:2222",
+			expectedFields: []string{HostOverridesPropertyName, HostOverridesPropertyName},
+			expectedValues: []string{"FD00:0:0:0:0:0:0:5=:2222", "Proxy-0.example.internal=:2222"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containerConfig := &ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				RemoteUser:                 tt.remoteUser,
+				HostOverrides:              ParseHostOverrides(tt.hostOverrides),
+			}
+			actualFields := make([]string, 0)
+			actualValues := make([]string, 0)
+			for _, fieldError := range containerConfig.Validate() {
+				actualFields = append(actualFields, fieldError.Field)
+				actualValues = append(actualValues, fieldError.Value)
+			}
+			require.Equal(t, tt.expectedFields, actualFields)
+			require.Equal(t, tt.expectedValues, actualValues)
+		})
+	}
+}
+
+func TestRemoteUserAndPort(t *testing.T) {
+	containerConfig := &ContainerInitConfig{
+		RemoteUser:    "rocky",
+		HostOverrides: ParseHostOverrides("172.18.100.45=:2222,monitoring=centos,proxies=:2200,fd00:10::32=ec2-user,proxy-2.example.internal=ubuntu"),
+	}
+	tests := []struct {
+		groupName    string
+		addresses    []string
+		expectedUser string
+		expectedPort string
+	}{
+		{"proxies", []string{"172.18.10.32"}, "rocky", "2200"},
+		{"proxies", []string{"FD00:10:0:0:0:0:0:32"}, "ec2-user", "2200"},
+		{"proxies", []string{"172.18.12.47", "PROXY-2.example.internal"}, "ubuntu", "2200"},
+		{"monitoring", []string{"172.18.100.45"}, "centos", "2222"},
+		{"monitoring", []string{"172.18.100.46"}, "centos", ""},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.addresses, " "), func(t *testing.T) {
+			user, port := containerConfig.RemoteUserAndPort(tt.groupName, tt.addresses...)
+			require.Equal(t, tt.expectedUser, user)
+			require.Equal(t, tt.expectedPort, port)
+		})
+	}
+
+	user, port := NewEmptyContainerInitConfig().RemoteUserAndPort("proxies", "172.18.10.32")
+	require.Equal(t, DefaultRemoteUser, user)
+	require.Equal(t, "", port)
+}
+
+func TestConfigFile_MarshalKeepsComments(t *testing.T) {
+	tests := []struct {
+		name            string
+		fileName        string
+		content         string
+		expectedContent string
+	}{
+		{
+			name:     "legacy",
+			fileName: "config",
+			content: "# main settings\nssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix=172.18.*\n\n" +
+				"[staging]\n# staging proxies\nproxy_ip_address_prefix: 10.0.*\nssh_key_path_on_host: /home/ubuntu/key\n",
+			expectedContent: "# main settings\nssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix: 172.19.*\nansible_inventory_path_on_host: /home/ubuntu/inventory\n\n" +
+				"[staging]\n# staging proxies\nproxy_ip_address_prefix: 10.0.*\n\n" +
+				"[prod]\nproxy_ip_address_prefix: 10.1.*\n",
+		},
+		{
+			name:     "yaml",
+			fileName: "config.yml",
+			content: "# zdm-util configuration\nssh_key_path_on_host: /home/ubuntu/key # main key\nproxy_ip_address_prefix: \"172.18.*\"\n" +
+				"profiles:\n    # staging proxies\n    staging:\n        proxy_ip_address_prefix: 10.0.*\n        ssh_key_path_on_host: /home/ubuntu/key\n",
+			expectedContent: "# zdm-util configuration\nssh_key_path_on_host: /home/ubuntu/key # main key\nproxy_ip_address_prefix: 172.19.*\nansible_inventory_path_on_host: /home/ubuntu/inventory\n" +
+				"profiles:\n    # staging proxies\n    staging:\n        proxy_ip_address_prefix: 10.0.*\n" +
+				"    prod:\n        proxy_ip_address_prefix: 10.1.*\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), tt.fileName)
+			require.Nil(t, os.WriteFile(filePath, []byte(tt.content), 0644))
+			configFile, err := ReadConfigFile(filePath)
+			require.Nil(t, err)
+
+			configFile.SetProperty(DefaultProfile, ProxyIpAddressPrefixPropertyName, "172.19.*")
+			configFile.SetProperty(DefaultProfile, AnsibleInventoryPathOnHostPropertyName, "/home/ubuntu/inventory")
+			require.True(t, configFile.UnsetProperty("staging", SshKeyPathOnHostPropertyName))
+			require.False(t, configFile.UnsetProperty("staging", AdditionalSshKeysPropertyName))
+			configFile.SetProperty("prod", ProxyIpAddressPrefixPropertyName, "10.1.*")
+
+			content, err := configFile.Marshal("")
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedContent, string(content))
+		})
+	}
+}
+
+func TestConfigFile_MarshalVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		migrations      []migration
+		upgradeVersion  bool
+		expectedContent string
+	}{
+		{
+			name:            "older file without changes keeps its schema",
+			content:         "ssh_key_path_on_host: /home/ubuntu/key\n",
+			expectedContent: "ssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix: 172.19.*\n",
+		},
+		{
+			name:            "older file explicitly migrated",
+			content:         "ssh_key_path_on_host: /home/ubuntu/key\n",
+			upgradeVersion:  true,
+			expectedContent: "config_version: 2\nssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix: 172.19.*\n",
+		},
+		{
+			name:            "older file changed by a migration",
+			content:         "ssh_key: /home/ubuntu/key\n",
+			migrations:      []migration{{toVersion: 2, renamedProperties: map[string]string{"ssh_key": SshKeyPathOnHostPropertyName}}},
+			expectedContent: "config_version: 2\nssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix: 172.19.*\n",
+		},
+		{
+			name:            "file of the current version",
+			content:         "config_version: 2\nssh_key_path_on_host: /home/ubuntu/key\n",
+			expectedContent: "config_version: 2\nssh_key_path_on_host: /home/ubuntu/key\nproxy_ip_address_prefix: 172.19.*\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.migrations != nil {
+				defaultMigrations := migrations
+				migrations = tt.migrations
+				defer func() { migrations = defaultMigrations }()
+			}
+			filePath := filepath.Join(t.TempDir(), "config")
+			require.Nil(t, os.WriteFile(filePath, []byte(tt.content), 0644))
+			configFile, err := ReadConfigFile(filePath)
+			require.Nil(t, err)
+			if tt.upgradeVersion {
+				configFile.Version = CurrentConfigVersion
+			}
+
+			configFile.SetProperty(DefaultProfile, ProxyIpAddressPrefixPropertyName, "172.19.*")
+			content, err := configFile.Marshal("")
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedContent, string(content))
+		})
+	}
+
+	content, err := NewEmptyConfigFile().Marshal(LegacyFileFormat)
+	require.Nil(t, err)
+	require.Equal(t, "config_version: 2\n", string(content))
+}
+
+func TestWriteConfigFile(t *testing.T) {
+	dirPath := t.TempDir()
+	filePath := filepath.Join(dirPath, "config")
+	require.Nil(t, os.WriteFile(filePath, []byte("ssh_key_path_on_host: /home/ubuntu/old_key\n"), 0644))
+
+	require.Nil(t, WriteConfigFile(filePath, []byte("ssh_key_path_on_host: /home/ubuntu/key\n")))
+
+	content, err := os.ReadFile(filePath)
+	require.Nil(t, err)
+	require.Equal(t, "ssh_key_path_on_host: /home/ubuntu/key\n", string(content))
+	if runtime.GOOS != "windows" {
+		fileInfo, err := os.Stat(filePath)
+		require.Nil(t, err)
+		require.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+	}
+	// the temporary file has been renamed
+	entries, err := os.ReadDir(dirPath)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestConfigFile_Migrate(t *testing.T) {
+	tests := []struct {
+		name                 string
+		content              string
+		migrations           []migration
+		expectedVersion      int
+		expectedProperties   map[string]map[string]string
+		expectedChanges      []string
+		expectedErrorMessage string
+	}{
+		{
+			name:               "file without version",
+			content:            "proxy_ip_address_prefix: 172.18.*\n",
+			expectedVersion:    1,
+			expectedProperties: map[string]map[string]string{DefaultProfile: {ProxyIpAddressPrefixPropertyName: "172.18.*"}},
+			expectedChanges:    []string{},
+		},
+		{
+			name:               "file of the current version",
+			content:            "config_version: 2\nproxy_ip_address_prefix: 172.18.*\n",
+			expectedVersion:    2,
+			expectedProperties: map[string]map[string]string{DefaultProfile: {ProxyIpAddressPrefixPropertyName: "172.18.*"}},
+			expectedChanges:    []string{},
+		},
+		{
+			name:    "renames, default values and lists",
+			content: "ssh_key: /home/me/key\nhosts: 10.0.0.1;10.0.0.2\n\n[staging]\nssh_key: /home/me/staging_key\nssh_key_path_on_host: /home/me/other_key\n",
+			migrations: []migration{
+				{toVersion: 2, renamedProperties: map[string]string{"ssh_key": SshKeyPathOnHostPropertyName}},
+				{toVersion: 3, defaultValues: map[string]string{ProxyIpAddressPrefixPropertyName: "172.*"}, listSeparators: map[string]string{"hosts": ";"}},
+			},
+			expectedVersion: 1,
+			expectedProperties: map[string]map[string]string{
+				DefaultProfile: {SshKeyPathOnHostPropertyName: "/home/me/key", ProxyIpAddressPrefixPropertyName: "172.*", "hosts": "10.0.0.1,10.0.0.2"},
+				"staging":      {SshKeyPathOnHostPropertyName: "/home/me/other_key", ProxyIpAddressPrefixPropertyName: "172.*"},
+			},
+			expectedChanges: []string{
+				"ssh_key renamed to ssh_key_path_on_host",
+				"profile staging: ssh_key removed, as it was replaced by ssh_key_path_on_host which is already set",
+				"proxy_ip_address_prefix set to its default value 172.*",
+				"hosts split into a list of 2 elements",
+				"profile staging: proxy_ip_address_prefix set to its default value 172.*",
+			},
+		},
+		{
+			name:                 "newer version",
+			content:              "config_version: 3\n",
+			expectedErrorMessage: "the file was written for version 3 of the configuration schema, but this utility only supports versions up to 2",
+		},
+		{
+			name:                 "invalid version",
+			content:              "config_version: two\n",
+			expectedErrorMessage: "invalid config_version two, it must be a positive integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.migrations != nil {
+				defaultMigrations := migrations
+				migrations = tt.migrations
+				defer func() { migrations = defaultMigrations }()
+			}
+			filePath := filepath.Join(t.TempDir(), "config")
+			require.Nil(t, os.WriteFile(filePath, []byte(tt.content), 0644))
+
+			configFile, err := ReadConfigFile(filePath)
+			if tt.expectedErrorMessage != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.expectedErrorMessage)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedVersion, configFile.Version)
+			require.Equal(t, tt.expectedProperties, configFile.Profiles)
+			require.Equal(t, tt.expectedChanges, configFile.MigrationChanges)
+		})
+	}
+}
+
+func TestCheckUnknownProperties(t *testing.T) {
+	tests := []struct {
+		name         string
+		propertyName string
+		expectedHint string
+	}{
+		{
+			name:         "misspelt property",
+			propertyName: "proxy_ip_adress_prefix",
+			expectedHint: "Did you mean proxy_ip_address_prefix?",
+		},
+		{
+			name:         "property in the form of a flag name",
+			propertyName: "ansible-inventory-path-on-host",
+			expectedHint: "Did you mean ansible_inventory_path_on_host?",
+		},
+		{
+			name:         "shortened property",
+			propertyName: "ssh_key_path",
+			expectedHint: "Did you mean ssh_key_path_on_host?",
+		},
+		{
+			name:         "misspelt version",
+			propertyName: "config_versoin",
+			expectedHint: "Did you mean config_version?",
+		},
+		{
+			name:         "unrelated property",
+			propertyName: "some_other_property",
+			expectedHint: "Valid properties are: ssh_key_path_on_host, proxy_ip_address_prefix, ansible_inventory_path_on_host, additional_ssh_keys, jumphost_public_ip_address, jumphost_private_ip_address, remote_user, host_overrides",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := NewEmptyConfigFile()
+			configFile.SetProperty(DefaultProfile, tt.propertyName, "value")
+			validationErrors := configFile.ContainerInitConfig(DefaultProfile).CheckUnknownProperties()
+			require.Equal(t, ValidationErrors{{
+				Field:  tt.propertyName,
+				Value:  "value",
+				Source: FileSource,
+				Reason: "unknown property",
+				Hint:   tt.expectedHint,
+			}}, validationErrors)
+		})
+	}
+}
+
+func TestWarnAboutUnknownProperties(t *testing.T) {
+	transcriptDir := t.TempDir()
+	_, err := logger.StartTranscript(transcriptDir)
+	require.Nil(t, err)
+	configFile := NewEmptyConfigFile()
+	configFile.SetProperty(DefaultProfile, "proxy_ip_adress_prefix", "172.18.*")
+	configFile.ContainerInitConfig(DefaultProfile).WarnAboutUnknownProperties()
+	logger.CloseTranscript()
+
+	transcripts, err := filepath.Glob(filepath.Join(transcriptDir, "*"))
+	require.Nil(t, err)
+	require.Len(t, transcripts, 1)
+	transcript, err := os.ReadFile(transcripts[0])
+	require.Nil(t, err)
+	require.Contains(t, string(transcript), "WARN  proxy_ip_adress_prefix: unknown property. Did you mean proxy_ip_address_prefix? (from configuration file). It is ignored")
+}
+
+func TestInspectSshKey(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	publicKey, err := ssh.NewPublicKey(ed25519Key.Public())
+	require.Nil(t, err)
+
+	openSshKey, err := ssh.MarshalPrivateKey(ed25519Key, "")
+	require.Nil(t, err)
+	encryptedOpenSshKey, err := ssh.MarshalPrivateKeyWithPassphrase(ed25519Key, "", []byte("secret passphrase"))
+	require.Nil(t, err)
+	pemRsaKey := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}
+
+	tests := []struct {
+		name            string
+		content         []byte
+		expectedKeyInfo *SshKeyInfo
+		expectedReason  string
+	}{
+		{
+			name:            "OpenSSH key",
+			content:         pem.EncodeToMemory(openSshKey),
+			expectedKeyInfo: &SshKeyInfo{Type: ssh.KeyAlgoED25519},
+		},
+		{
+			name:            "OpenSSH key protected by a passphrase",
+			content:         pem.EncodeToMemory(encryptedOpenSshKey),
+			expectedKeyInfo: &SshKeyInfo{Type: ssh.KeyAlgoED25519, Encrypted: true},
+		},
+		{
+			name:            "PEM RSA key",
+			content:         pem.EncodeToMemory(pemRsaKey),
+			expectedKeyInfo: &SshKeyInfo{Type: ssh.KeyAlgoRSA},
+		},
+		{
+			name:            "PEM RSA key protected by a passphrase",
+			content:         pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-128-CBC,00"}, Bytes: []byte{0}}),
+			expectedKeyInfo: &SshKeyInfo{Type: ssh.KeyAlgoRSA, Encrypted: true},
+		},
+		{
+			name:           "public key",
+			content:        ssh.MarshalAuthorizedKey(publicKey),
+			expectedReason: "is a public key, but the private key is needed to access the hosts",
+		},
+		{
+			name:           "PuTTY key",
+			content:        []byte("PuTTY-User-Key-File-3: ssh-ed25519\nEncryption: none\n"),
+			expectedReason: "is in the PuTTY format, which is not supported",
+		},
+		{
+			name:           "encrypted PKCS#8 key",
+			content:        pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte{0}}),
+			expectedReason: "is an encrypted PKCS#8 key, which is not supported",
+		},
+		{
+			name:           "empty file",
+			content:        []byte("\n"),
+			expectedReason: "is empty",
+		},
+		{
+			name:           "other content",
+			content:        []byte("not a key"),
+			expectedReason: "is not a valid private key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyPath := filepath.Join(t.TempDir(), "key")
+			require.Nil(t, os.WriteFile(keyPath, tt.content, 0600))
+
+			keyInfo, fieldError := InspectSshKey(keyPath)
+			if tt.expectedReason != "" {
+				require.NotNil(t, fieldError)
+				require.Contains(t, fieldError.Reason, tt.expectedReason)
+				require.Equal(t, fieldError, CheckSshPrivateKey(keyPath))
+				return
+			}
+			require.Nil(t, fieldError)
+			require.Equal(t, tt.expectedKeyInfo, keyInfo)
+			require.Nil(t, CheckSshPrivateKey(keyPath))
+		})
+	}
+}
+
+func TestCheckSshKeyPassphrase(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	encryptedKey, err := ssh.MarshalPrivateKeyWithPassphrase(ed25519Key, "", []byte("secret passphrase"))
+	require.Nil(t, err)
+	keyPath := filepath.Join(t.TempDir(), "key")
+	require.Nil(t, os.WriteFile(keyPath, pem.EncodeToMemory(encryptedKey), 0600))
+
+	require.Nil(t, CheckSshKeyPassphrase(keyPath, "secret passphrase"))
+	fieldError := CheckSshKeyPassphrase(keyPath, "wrong passphrase")
+	require.NotNil(t, fieldError)
+	require.Contains(t, fieldError.Reason, "The passphrase of SSH key "+keyPath+" is not correct")
+}
+
+func TestSshKeyPermissionsWarning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on Windows")
+	}
+	keyPath := filepath.Join(t.TempDir(), "key")
+	require.Nil(t, os.WriteFile(keyPath, []byte("key"), 0600))
+	require.Equal(t, "", SshKeyPermissionsWarning(keyPath))
+
+	require.Nil(t, os.Chmod(keyPath, 0644))
+	require.Equal(t, "SSH key "+keyPath+" can be accessed by other users, as its permissions are -rw-r--r--. Please restrict them with: chmod 600 "+keyPath,
+		SshKeyPermissionsWarning(keyPath))
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+const (
+	// ConfigVersionKey is the key of the version of the configuration schema, at the top level of the configuration file.
+	// Files without it were written before the schema was versioned, and are considered to be of version 1
+	ConfigVersionKey = "config_version"
+
+	// CurrentConfigVersion is the version of the configuration schema written by this utility.
+	// When the schema changes, this version must be increased and a migration from the previous version added to migrations
+	CurrentConfigVersion = 2
+)
+
+// migration upgrades the properties of each profile from the previous version of the configuration schema.
+// The changes are applied in the order of the fields, so a default value or a split refers to the name after any rename
+type migration struct {
+	// toVersion is the version of the schema after the migration
+	toVersion   int
+	description string
+	// renamedProperties maps the old name of each renamed property to its new name
+	renamedProperties map[string]string
+	// defaultValues are the values of the properties that are now required, which are set if the profile does not set them
+	defaultValues map[string]string
+	// listSeparators maps the name of each property that has become a list to the separator of its elements in the previous version,
+	// so that its value is split into list elements
+	listSeparators map[string]string
+}
+
+// migrations lists the changes of the configuration schema, in order of version
+var migrations = []migration{
+	{
+		toVersion:   2,
+		description: "the version of the configuration schema is recorded in the file. The properties of version 1 are unchanged",
+	},
+}
+
+// migrate upgrades the profiles of the file to the current version of the configuration schema, recording the version the file was written for
+// and the changes made. It returns an error if the version is not valid or is newer than the current version
+func (f *ConfigFile) migrate(filePath string) error {
+	defaultProperties := f.Profiles[DefaultProfile]
+	f.Version = 1
+	if versionValue, found := defaultProperties[ConfigVersionKey]; found {
+		version, err := strconv.Atoi(versionValue)
+		if err != nil || version < 1 {
+			return fmt.Errorf("invalid %v %v, it must be a positive integer", ConfigVersionKey, versionValue)
+		}
+		if version > CurrentConfigVersion {
+			return fmt.Errorf("the file was written for version %v of the configuration schema, but this utility only supports versions up to %v. Please upgrade this utility",
+				version, CurrentConfigVersion)
+		}
+		f.Version = version
+		delete(defaultProperties, ConfigVersionKey)
+	}
+
+	f.MigrationChanges = make([]string, 0)
+	for _, m := range migrations {
+		if m.toVersion <= f.Version {
+			continue
+		}
+		for _, profile := range append([]string{DefaultProfile}, f.ProfileNames()...) {
+			for _, change := range m.apply(f.Profiles[profile]) {
+				if profile != DefaultProfile {
+					change = fmt.Sprintf("profile %v: %v", profile, change)
+				}
+				f.MigrationChanges = append(f.MigrationChanges, change)
+			}
+		}
+	}
+
+	if f.Version < CurrentConfigVersion {
+		if len(f.MigrationChanges) == 0 {
+			logger.Debugf("The configuration file %v was written for version %v of the configuration schema, and is compatible with version %v \n",
+				filePath, f.Version, CurrentConfigVersion)
+		} else {
+			logger.Infof("The configuration file %v was written for version %v of the configuration schema, and has been migrated to version %v: \n - %v \n"+
+				"The file will be updated the next time it is written. \n", filePath, f.Version, CurrentConfigVersion, strings.Join(f.MigrationChanges, "\n - "))
+		}
+	}
+	return nil
+}
+
+// apply makes the changes of the migration to the properties of a profile, returning a description of each change
+func (m migration) apply(properties map[string]string) []string {
+	changes := make([]string, 0)
+	for _, oldName := range sortedKeys(m.renamedProperties) {
+		newName := m.renamedProperties[oldName]
+		value, found := properties[oldName]
+		if !found {
+			continue
+		}
+		delete(properties, oldName)
+		if _, isNewNameSet := properties[newName]; isNewNameSet {
+			changes = append(changes, fmt.Sprintf("%v removed, as it was replaced by %v which is already set", oldName, newName))
+			continue
+		}
+		properties[newName] = value
+		changes = append(changes, fmt.Sprintf("%v renamed to %v", oldName, newName))
+	}
+	for _, name := range sortedKeys(m.defaultValues) {
+		if _, found := properties[name]; !found {
+			properties[name] = m.defaultValues[name]
+			changes = append(changes, fmt.Sprintf("%v set to its default value %v", name, m.defaultValues[name]))
+		}
+	}
+	for _, name := range sortedKeys(m.listSeparators) {
+		value, found := properties[name]
+		if !found || !strings.Contains(value, m.listSeparators[name]) {
+			continue
+		}
+		elements := make([]string, 0)
+		for _, element := range strings.Split(value, m.listSeparators[name]) {
+			if element = FormatString(element); element != "" {
+				elements = append(elements, element)
+			}
+		}
+		properties[name] = strings.Join(elements, listValueSeparator)
+		changes = append(changes, fmt.Sprintf("%v split into a list of %v elements", name, len(elements)))
+	}
+	return changes
+}
+
+// withVersion returns the profiles to write to the configuration file, with the current version of the schema at the top level
+func withVersion(profiles map[string]map[string]string) map[string]map[string]string {
+	profilesWithVersion := make(map[string]map[string]string, len(profiles))
+	for profile, properties := range profiles {
+		profilesWithVersion[profile] = properties
+	}
+	defaultProperties := map[string]string{ConfigVersionKey: strconv.Itoa(CurrentConfigVersion)}
+	for name, value := range profiles[DefaultProfile] {
+		defaultProperties[name] = value
+	}
+	profilesWithVersion[DefaultProfile] = defaultProperties
+	return profilesWithVersion
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
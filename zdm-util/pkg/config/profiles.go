@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultProfile is the profile made of the properties at the top level of the configuration file. It is used when no profile is selected
+	DefaultProfile = ""
+
+	// profilesKey is the key of the mapping of the named profiles in YAML and JSON configuration files.
+	// In legacy configuration files, each named profile is instead a section starting with its name in brackets, e.g. [staging]
+	profilesKey = "profiles"
+)
+
+// profile names are also part of the container names, so they are restricted to the characters allowed by Docker
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// CheckProfileName returns an error if the name cannot be used for a profile. The error has no field set
+func CheckProfileName(profile string) *FieldError {
+	if !profileNameRegex.MatchString(profile) {
+		return &FieldError{
+			Value:  profile,
+			Reason: fmt.Sprintf("Invalid profile name %v", profile),
+			Hint:   "A profile name must start with a letter or a digit, and can only contain letters, digits, dots, hyphens and underscores, e.g. prod-eu",
+		}
+	}
+	return nil
+}
+
+// ConfigFile is the content of a configuration file, which can hold several profiles, e.g. one for each migration
+// managed from the same machine. Each profile has its own properties, which are not shared with the other profiles
+type ConfigFile struct {
+	// Format is the format the file was read in
+	Format FileFormat
+	// Profiles holds the property values of each profile, keyed by profile name. The default profile is keyed by DefaultProfile
+	Profiles map[string]map[string]string
+	// Version is the version of the configuration schema the file was written for. The profiles have been migrated to CurrentConfigVersion,
+	// but the version is only written back if it is the current one or if the migration changed the profiles, see Marshal
+	Version int
+	// MigrationChanges describes the changes made to migrate the profiles to CurrentConfigVersion, see migrations
+	MigrationChanges []string
+	// content is the content the file was read from, which is updated rather than rewritten to preserve its comments, see Marshal
+	content []byte
+}
+
+func NewEmptyConfigFile() *ConfigFile {
+	return &ConfigFile{
+		Profiles: map[string]map[string]string{DefaultProfile: make(map[string]string)},
+		Version:  CurrentConfigVersion,
+	}
+}
+
+// ReadConfigFile reads a configuration file in any of the supported formats, see DetectFileFormat. The values are not validated
+func ReadConfigFile(filePath string) (*ConfigFile, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the specified configuration file: %v ", err)
+	}
+
+	fileFormat := DetectFileFormat(filePath, content)
+	profiles, err := parseConfigFileContent(content, fileFormat)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the specified configuration file as %v: %v ", fileFormat, err)
+	}
+	configFile := &ConfigFile{Format: fileFormat, Profiles: profiles, content: content}
+	if err = configFile.migrate(filePath); err != nil {
+		return nil, fmt.Errorf("error migrating the specified configuration file: %v ", err)
+	}
+	return configFile, nil
+}
+
+// ReadConfigFileIfExists is like ReadConfigFile, but returns an empty configuration file if the file does not exist
+func ReadConfigFileIfExists(filePath string) (*ConfigFile, error) {
+	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
+		return NewEmptyConfigFile(), nil
+	}
+	return ReadConfigFile(filePath)
+}
+
+// ProfileNames returns the names of the named profiles, sorted alphabetically
+func (f *ConfigFile) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasProfile returns whether the file holds the profile. The default profile is always considered present, even if it has no properties
+func (f *ConfigFile) HasProfile(profile string) bool {
+	_, found := f.Profiles[profile]
+	return found || profile == DefaultProfile
+}
+
+// SetProfileProperties replaces all the properties of the profile, leaving the other profiles as they are
+func (f *ConfigFile) SetProfileProperties(profile string, properties map[string]string) {
+	if f.Profiles == nil {
+		f.Profiles = make(map[string]map[string]string)
+	}
+	f.Profiles[profile] = properties
+}
+
+// SetProperty sets the value of a single property of the profile, adding the profile if the file does not hold it yet
+func (f *ConfigFile) SetProperty(profile string, propertyName string, value string) {
+	if f.Profiles == nil {
+		f.Profiles = make(map[string]map[string]string)
+	}
+	if f.Profiles[profile] == nil {
+		f.Profiles[profile] = make(map[string]string)
+	}
+	f.Profiles[profile][propertyName] = value
+}
+
+// UnsetProperty removes a single property of the profile. It returns whether the property was set
+func (f *ConfigFile) UnsetProperty(profile string, propertyName string) bool {
+	if _, found := f.Profiles[profile][propertyName]; !found {
+		return false
+	}
+	delete(f.Profiles[profile], propertyName)
+	return true
+}
+
+// Marshal returns the content of the configuration file in the specified format, with the properties of the default profile first,
+// followed by each named profile in alphabetical order. The current version of the configuration schema is written first if the file is new,
+// already had a version or was changed by a migration, so that updating a property of an older file that is still compatible does not add it.
+// If the file was read in the same format, its content is updated instead, so that its comments and the order of its properties are kept
+func (f *ConfigFile) Marshal(format FileFormat) ([]byte, error) {
+	if format == "" {
+		format = f.Format
+	}
+	profiles := f.Profiles
+	if f.Version == CurrentConfigVersion || len(f.MigrationChanges) > 0 {
+		profiles = withVersion(f.Profiles)
+	}
+	if f.content != nil && format == f.Format {
+		switch format {
+		case LegacyFileFormat:
+			return updateLegacyContent(f.content, profiles), nil
+		case YamlFileFormat:
+			return updateYamlContent(f.content, profiles)
+		}
+	}
+
+	switch format {
+	case LegacyFileFormat, "":
+		var buf bytes.Buffer
+		writeLegacyProperties(&buf, profiles[DefaultProfile])
+		for _, profile := range f.ProfileNames() {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString(fmt.Sprintf("[%s]\n", profile))
+			writeLegacyProperties(&buf, profiles[profile])
+		}
+		return buf.Bytes(), nil
+	case YamlFileFormat:
+		return yaml.Marshal(profilesNode(profiles))
+	case JsonFileFormat:
+		var buf bytes.Buffer
+		writeJsonNode(&buf, profilesNode(profiles), "")
+		buf.WriteString("\n")
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported configuration file format %v", format)
+	}
+}
+
+func writeLegacyProperties(buf *bytes.Buffer, properties map[string]string) {
+	for _, name := range orderedNames(properties) {
+		buf.WriteString(fmt.Sprintf("%s: %s\n", name, properties[name]))
+	}
+}
+
+// profilesNode returns the content of a file holding the profiles as a YAML mapping, which keeps the properties in order, unlike a map
+func profilesNode(profiles map[string]map[string]string) *yaml.Node {
+	mapping := propertiesNode(profiles[DefaultProfile])
+	if profileNames := namedProfiles(profiles); len(profileNames) > 0 {
+		profilesMapping := &yaml.Node{Kind: yaml.MappingNode}
+		for _, profile := range profileNames {
+			profilesMapping.Content = append(profilesMapping.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: profile},
+				propertiesNode(profiles[profile]))
+		}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: profilesKey}, profilesMapping)
+	}
+	return mapping
+}
+
+func propertiesNode(properties map[string]string) *yaml.Node {
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	for _, name := range orderedNames(properties) {
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: name},
+			propertyValueNode(name, properties[name]))
+	}
+	return mapping
+}
+
+// propertyValueNode returns the value of a property as a string, except for the version of the configuration schema which is an integer
+func propertyValueNode(name string, value string) *yaml.Node {
+	if name == ConfigVersionKey {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: value}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// writeJsonNode writes a mapping of scalars and nested mappings as indented JSON, keeping the order of its keys
+func writeJsonNode(buf *bytes.Buffer, node *yaml.Node, indent string) {
+	if node.Kind != yaml.MappingNode {
+		if node.Tag == "!!int" {
+			buf.WriteString(node.Value)
+			return
+		}
+		encodedValue, _ := json.Marshal(node.Value)
+		buf.Write(encodedValue)
+		return
+	}
+	buf.WriteString("{\n")
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		encodedName, _ := json.Marshal(node.Content[i].Value)
+		buf.WriteString(fmt.Sprintf("%s  %s: ", indent, encodedName))
+		writeJsonNode(buf, node.Content[i+1], indent+"  ")
+		if i+2 < len(node.Content) {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString(indent + "}")
+}
+
+// parseLegacyProfileHeading returns the name of the profile if the line starts a profile section, e.g. [staging]
+func parseLegacyProfileHeading(line string) (string, bool) {
+	trimmedLine := strings.TrimSpace(line)
+	if len(trimmedLine) < 2 || trimmedLine[0] != '[' || trimmedLine[len(trimmedLine)-1] != ']' {
+		return "", false
+	}
+	return strings.TrimSpace(trimmedLine[1 : len(trimmedLine)-1]), true
+}
+
+func parseLegacyContent(content []byte) (map[string]map[string]string, error) {
+	profiles := map[string]map[string]string{DefaultProfile: make(map[string]string)}
+	profile := DefaultProfile
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isLegacyComment(line) {
+			continue
+		}
+		if profileName, isHeading := parseLegacyProfileHeading(line); isHeading {
+			if err := addProfile(profiles, profileName, make(map[string]string)); err != nil {
+				return nil, err
+			}
+			profile = profileName
+			continue
+		}
+		if separatorIdx := separatorIndex(line); separatorIdx >= 0 {
+			if propertyName := FormatString(line[:separatorIdx]); len(propertyName) > 0 {
+				profiles[profile][propertyName] = FormatString(line[separatorIdx+1:])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// parseStructuredProfiles converts the value of the profiles key of a YAML or JSON configuration file into the properties of each profile
+func parseStructuredProfiles(profiles map[string]map[string]string, value any) error {
+	profilesContent, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%v must be a mapping of profile names to their properties", profilesKey)
+	}
+	for profile, profileContent := range profilesContent {
+		if profileContent == nil {
+			profileContent = make(map[string]any)
+		}
+		structuredProperties, ok := profileContent.(map[string]any)
+		if !ok {
+			return fmt.Errorf("profile %v must be a mapping of property names to values", profile)
+		}
+		properties, err := structuredPropertiesToStrings(structuredProperties)
+		if err != nil {
+			return fmt.Errorf("profile %v: %v", profile, err)
+		}
+		if err = addProfile(profiles, profile, properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addProfile(profiles map[string]map[string]string, profile string, properties map[string]string) error {
+	if fieldError := CheckProfileName(profile); fieldError != nil {
+		return fmt.Errorf("%v", fieldError.Message())
+	}
+	if _, found := profiles[profile]; found {
+		return fmt.Errorf("profile %v is defined more than once", profile)
+	}
+	profiles[profile] = properties
+	return nil
+}
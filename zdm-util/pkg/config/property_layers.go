@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// PropertySource identifies where the value of a property comes from
+type PropertySource string
+
+const (
+	FlagSource      PropertySource = "flag"
+	EnvVarSource    PropertySource = "environment variable"
+	FileSource      PropertySource = "configuration file"
+	PromptSource    PropertySource = "prompt"
+	GeneratedSource PropertySource = "generated"
+	TerraformSource PropertySource = "Terraform output"
+)
+
+// PropertyLayer holds the property values provided by one source. Empty values are considered not provided
+type PropertyLayer struct {
+	Source     PropertySource
+	Properties map[string]string
+}
+
+// NewFlagLayer returns the layer of the values specified as command-line flags, keyed by property name
+func NewFlagLayer(flagValues map[string]string) PropertyLayer {
+	return PropertyLayer{
+		Source:     FlagSource,
+		Properties: flagValues,
+	}
+}
+
+// NewEnvVarLayer returns the layer of the values specified as environment variables, see EnvVarNameForProperty
+func NewEnvVarLayer() PropertyLayer {
+	envValues := make(map[string]string)
+	for _, propertyName := range propertyNames {
+		if value := FormatString(os.Getenv(EnvVarNameForProperty(propertyName))); value != "" {
+			envValues[propertyName] = value
+		}
+	}
+	return PropertyLayer{
+		Source:     EnvVarSource,
+		Properties: envValues,
+	}
+}
+
+// ApplyLayers sets each known property to its value from the first layer that provides it, so layers must be passed in order of precedence.
+// The layers take precedence over the values already in the configuration, which are typically those read from the configuration file.
+// The values are not validated, so an invalid value does not fall back to a layer of lower precedence and is reported by Validate instead
+func (c *ContainerInitConfig) ApplyLayers(layers ...PropertyLayer) {
+	for _, propertyName := range propertyNames {
+		for _, layer := range layers {
+			value := FormatString(layer.Properties[propertyName])
+			if value == "" {
+				continue
+			}
+			// the property is known, so this cannot fail
+			_ = c.SetPropertyFromSource(propertyName, value, layer.Source)
+			break
+		}
+	}
+}
+
+// DescribePropertySource describes where the value of the property comes from, e.g. flag -sshKeyPathOnHost
+func DescribePropertySource(propertyName string, source PropertySource) string {
+	switch source {
+	case FlagSource:
+		return fmt.Sprintf("%v -%v", source, FlagNameForProperty(propertyName))
+	case EnvVarSource:
+		return fmt.Sprintf("%v %v", source, EnvVarNameForProperty(propertyName))
+	case "":
+		return "unknown source"
+	default:
+		return string(source)
+	}
+}
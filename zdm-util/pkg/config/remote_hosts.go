@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+)
+
+const (
+	// DefaultRemoteUser is the user that Ansible connects as when remote_user is not set, which is that of the Ubuntu images
+	DefaultRemoteUser = "ubuntu"
+
+	// hostOverrideSeparator separates the group or host of an override from its user and port, e.g. monitoring=centos:2222
+	hostOverrideSeparator = "="
+	// hostOverridePortSeparator separates the user of an override from its port. User names cannot contain it
+	hostOverridePortSeparator = ":"
+
+	maxRemoteUserLength = 32
+
+	hostOverrideFormatHint = "Use the form <group or host>=<user>[:<port>] or <group or host>=:<port>, e.g. monitoring=centos or 172.18.10.5=rocky:2222 or proxy-0.example.internal=:2222"
+)
+
+// remoteUserRegexp matches the user names accepted by useradd with its default settings, e.g. ubuntu, ec2-user or svc_ansible
+var remoteUserRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+// RemoteUserHint is the default user of the images of a Linux distribution, together with the OS family that Ansible reports for it.
+// The playbooks use the install_*-centos.yml task variants on hosts of the RedHat and Rocky OS families, and the Debian ones otherwise
+type RemoteUserHint struct {
+	User          string
+	Distributions string
+	OsFamily      string
+}
+
+// RemoteUserHints lists the default users of the distributions supported by the Terraform automation
+var RemoteUserHints = []RemoteUserHint{
+	{User: "ubuntu", Distributions: "Ubuntu 20.04, 22.04 and 24.04", OsFamily: "Debian"},
+	{User: "centos", Distributions: "CentOS 7 and 8", OsFamily: "RedHat"},
+	{User: "ec2-user", Distributions: "CentOS Stream 9 and RHEL 7 and 8 on AWS", OsFamily: "RedHat"},
+	{User: "rocky", Distributions: "Rocky Linux 8 and 9", OsFamily: "Rocky"},
+}
+
+// HostOverride sets the user and the SSH port of the hosts of an inventory group, or of a single host, when they differ from the defaults.
+// An empty user or port leaves the default unchanged
+type HostOverride struct {
+	// Target is the name of an inventory group, e.g. monitoring, or the IP address or hostname of a host
+	Target string `json:"target"`
+	User   string `json:"user,omitempty"`
+	Port   string `json:"port,omitempty"`
+}
+
+func (o HostOverride) String() string {
+	value := o.User
+	if o.Port != "" {
+		value += hostOverridePortSeparator + o.Port
+	}
+	return o.Target + hostOverrideSeparator + value
+}
+
+// ParseHostOverrides parses a list of overrides separated by commas, each in the form <group or host>=<user>[:<port>].
+// Malformed overrides are kept as they are, so that they are reported by Validate
+func ParseHostOverrides(value string) []HostOverride {
+	var hostOverrides []HostOverride
+	for _, entry := range strings.Split(value, listValueSeparator) {
+		entry = FormatString(entry)
+		if entry == "" {
+			continue
+		}
+		hostOverride := HostOverride{Target: entry}
+		if separatorIdx := strings.Index(entry, hostOverrideSeparator); separatorIdx >= 0 {
+			hostOverride.Target = FormatString(entry[:separatorIdx])
+			hostOverride.User = FormatString(entry[separatorIdx+1:])
+			if portIdx := strings.LastIndex(hostOverride.User, hostOverridePortSeparator); portIdx >= 0 {
+				hostOverride.Port = FormatString(hostOverride.User[portIdx+1:])
+				hostOverride.User = FormatString(hostOverride.User[:portIdx])
+			}
+		}
+		hostOverrides = append(hostOverrides, hostOverride)
+	}
+	return hostOverrides
+}
+
+// FormatHostOverrides is the reverse of ParseHostOverrides
+func FormatHostOverrides(hostOverrides []HostOverride) string {
+	entries := make([]string, 0, len(hostOverrides))
+	for _, hostOverride := range hostOverrides {
+		entries = append(entries, hostOverride.String())
+	}
+	return strings.Join(entries, listValueSeparator)
+}
+
+// RemoteUserOrDefault returns the user that Ansible connects as on the hosts, unless overridden, see RemoteUserAndPort
+func (c *ContainerInitConfig) RemoteUserOrDefault() string {
+	if c.RemoteUser != "" {
+		return c.RemoteUser
+	}
+	return DefaultRemoteUser
+}
+
+// RemoteUserAndPort returns the user that Ansible connects as on a host of the group, and its SSH port, which is empty if it is the default one.
+// An override of the host takes precedence over an override of its group, which takes precedence over remote_user. The host is identified
+// by any of its addresses, e.g. both its hostname and the IP address it resolves to
+func (c *ContainerInitConfig) RemoteUserAndPort(groupName string, addresses ...string) (string, string) {
+	user, port := c.RemoteUserOrDefault(), ""
+	applyOverride := func(hostOverride HostOverride) {
+		if hostOverride.User != "" {
+			user = hostOverride.User
+		}
+		if hostOverride.Port != "" {
+			port = hostOverride.Port
+		}
+	}
+	for _, hostOverride := range c.HostOverrides {
+		if hostOverride.Target == groupName {
+			applyOverride(hostOverride)
+		}
+	}
+	for _, hostOverride := range c.HostOverrides {
+		if isGroupOverride(hostOverride) {
+			continue
+		}
+		for _, address := range addresses {
+			if address != "" && CanonicalHostAddress(hostOverride.Target) == CanonicalHostAddress(address) {
+				applyOverride(hostOverride)
+				break
+			}
+		}
+	}
+	return user, port
+}
+
+// CheckRemoteUser returns an error if the value is not a valid Linux user name. The error has no field set
+func CheckRemoteUser(remoteUser string) *FieldError {
+	if len(remoteUser) > maxRemoteUserLength || !remoteUserRegexp.MatchString(remoteUser) {
+		return &FieldError{
+			Value:  remoteUser,
+			Reason: fmt.Sprintf("Invalid user name %v", remoteUser),
+			Hint: fmt.Sprintf("A user name starts with a lowercase letter or an underscore, followed by at most %v lowercase letters, digits, underscores or hyphens, e.g. %v",
+				maxRemoteUserLength-1, describeRemoteUserHints()),
+		}
+	}
+	return nil
+}
+
+// ValidateRemoteUser checks the user name, printing the reason if it is not valid. It can be used as a prompt validator
+func ValidateRemoteUser(remoteUser string) bool {
+	return printFieldError(CheckRemoteUser(remoteUser))
+}
+
+// CheckSshPort returns an error if the value is not a valid TCP port. The error has no field set
+func CheckSshPort(port string) *FieldError {
+	if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+		return &FieldError{Value: port, Reason: fmt.Sprintf("Invalid SSH port %v", port), Hint: "The port must be a number between 1 and 65535"}
+	}
+	return nil
+}
+
+// CheckHostOverride returns an error for each problem found with the override. Its target must be one of the groups used by the playbooks
+// or the IP address or hostname of a host. The errors have no field set
+func CheckHostOverride(hostOverride HostOverride) ValidationErrors {
+	if hostOverride.Target == "" || (hostOverride.User == "" && hostOverride.Port == "") {
+		return ValidationErrors{{Value: hostOverride.String(), Reason: fmt.Sprintf("Malformed override %v", hostOverride), Hint: hostOverrideFormatHint}}
+	}
+	validationErrors := make(ValidationErrors, 0)
+	if !isGroupOverride(hostOverride) && CheckHostAddress(hostOverride.Target) != nil {
+		validationErrors = append(validationErrors, &FieldError{
+			Value:  hostOverride.Target,
+			Reason: fmt.Sprintf("Invalid group or host %v", hostOverride.Target),
+			Hint:   fmt.Sprintf("Specify the group %v or %v, or the IP address or hostname of a host", inventory.ProxiesGroupName, inventory.MonitoringGroupName),
+		})
+	}
+	if hostOverride.User != "" {
+		if fieldError := CheckRemoteUser(hostOverride.User); fieldError != nil {
+			validationErrors = append(validationErrors, fieldError)
+		}
+	}
+	if hostOverride.Port != "" {
+		if fieldError := CheckSshPort(hostOverride.Port); fieldError != nil {
+			validationErrors = append(validationErrors, fieldError)
+		}
+	}
+	return validationErrors
+}
+
+// ValidateHostOverride checks a single override, printing the reasons if it is not valid. It can be used as a prompt validator
+func ValidateHostOverride(hostOverride string) bool {
+	overrides := ParseHostOverrides(hostOverride)
+	if len(overrides) != 1 {
+		return printFieldError(&FieldError{Value: hostOverride, Reason: fmt.Sprintf("Malformed override %v", hostOverride), Hint: hostOverrideFormatHint})
+	}
+	validationErrors := CheckHostOverride(overrides[0])
+	for _, fieldError := range validationErrors {
+		printFieldError(fieldError)
+	}
+	return len(validationErrors) == 0
+}
+
+// checkHostOverrides checks each override, and that no group or host is overridden more than once. The errors have no field set
+func checkHostOverrides(hostOverrides []HostOverride) ValidationErrors {
+	validationErrors := make(ValidationErrors, 0)
+	targets := make(map[string]bool)
+	for _, hostOverride := range hostOverrides {
+		validationErrors = append(validationErrors, CheckHostOverride(hostOverride)...)
+		target := hostOverride.Target
+		if !isGroupOverride(hostOverride) {
+			target = CanonicalHostAddress(target)
+		}
+		if targets[target] {
+			validationErrors = append(validationErrors, &FieldError{
+				Value:  hostOverride.String(),
+				Reason: fmt.Sprintf("%v is overridden more than once", hostOverride.Target),
+				Hint:   "Merge its user and port into a single override",
+			})
+		}
+		targets[target] = true
+	}
+	return validationErrors
+}
+
+// isGroupOverride returns whether the override applies to one of the groups used by the playbooks, rather than to a single host
+func isGroupOverride(hostOverride HostOverride) bool {
+	return hostOverride.Target == inventory.ProxiesGroupName || hostOverride.Target == inventory.MonitoringGroupName
+}
+
+// describeRemoteUserHints lists the default users of the known distributions, e.g. for a hint
+func describeRemoteUserHints() string {
+	users := make([]string, 0, len(RemoteUserHints))
+	for _, hint := range RemoteUserHints {
+		users = append(users, hint.User)
+	}
+	return strings.Join(users, ", ")
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"runtime"
+)
+
+const (
+	puttyKeyFilePrefix      = "PuTTY-User-Key-File"
+	ssh2PublicKeyFilePrefix = "---- BEGIN SSH2 PUBLIC KEY ----"
+
+	supportedSshKeyFormatsHint = "Supported formats are OpenSSH (BEGIN OPENSSH PRIVATE KEY) and PEM (BEGIN RSA PRIVATE KEY, BEGIN EC PRIVATE KEY or BEGIN PRIVATE KEY)"
+)
+
+// SshKeyInfo describes an SSH private key, as found by InspectSshKey
+type SshKeyInfo struct {
+	// Type is the type of the key as named by SSH, e.g. ssh-ed25519 or ssh-rsa
+	Type string `json:"type"`
+	// Encrypted is true if the key is protected by a passphrase, which must be entered for the key to be used
+	Encrypted bool `json:"encrypted"`
+}
+
+// CheckSshPrivateKey returns an error if the path does not refer to a readable file containing an SSH private key in a supported format.
+// A key protected by a passphrase is valid, see InspectSshKey. The error has no field set
+func CheckSshPrivateKey(path string) *FieldError {
+	if fieldError := CheckFilePath(path); fieldError != nil {
+		return fieldError
+	}
+	_, fieldError := InspectSshKey(path)
+	return fieldError
+}
+
+// ValidateSshPrivateKey checks the SSH private key, printing the reason if it is not valid. It can be used as a prompt validator
+func ValidateSshPrivateKey(path string) bool {
+	return printFieldError(CheckSshPrivateKey(path))
+}
+
+// InspectSshKey parses the SSH private key to find its type and whether it is protected by a passphrase.
+// It returns an error explaining how to obtain a supported key if the file holds a public key, a PuTTY key or any other unsupported content.
+// The error has no field set
+func InspectSshKey(path string) (*SshKeyInfo, *FieldError) {
+	absPath, ok := ConvertToAbsolutePath(path)
+	if !ok {
+		return nil, &FieldError{Value: path, Reason: fmt.Sprintf("File path %v could not be converted to an absolute path", path)}
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, &FieldError{Value: path, Reason: fmt.Sprintf("SSH key %v could not be read. Error: %v", path, err)}
+	}
+
+	trimmedContent := bytes.TrimSpace(content)
+	switch {
+	case len(trimmedContent) == 0:
+		return nil, &FieldError{Value: path, Reason: fmt.Sprintf("SSH key %v is empty", path), Hint: supportedSshKeyFormatsHint}
+	case bytes.HasPrefix(trimmedContent, []byte(puttyKeyFilePrefix)):
+		return nil, &FieldError{
+			Value:  path,
+			Reason: fmt.Sprintf("SSH key %v is in the PuTTY format, which is not supported", path),
+			Hint:   fmt.Sprintf("Convert it to the OpenSSH format with: puttygen %v -O private-openssh -o <new key path>", path),
+		}
+	case bytes.HasPrefix(trimmedContent, []byte(ssh2PublicKeyFilePrefix)) || isAuthorizedKey(trimmedContent):
+		return nil, &FieldError{
+			Value:  path,
+			Reason: fmt.Sprintf("SSH key %v is a public key, but the private key is needed to access the hosts", path),
+			Hint:   "The private key is usually the file with the same name without the .pub extension",
+		}
+	}
+
+	if block, _ := pem.Decode(trimmedContent); block != nil && block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, &FieldError{
+			Value:  path,
+			Reason: fmt.Sprintf("SSH key %v is an encrypted PKCS#8 key, which is not supported", path),
+			Hint:   fmt.Sprintf("Convert it to the OpenSSH format, keeping its passphrase, with: ssh-keygen -p -f %v", path),
+		}
+	}
+
+	key, err := ssh.ParseRawPrivateKey(content)
+	var passphraseMissingError *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseMissingError) {
+		keyInfo := &SshKeyInfo{Encrypted: true}
+		// the public key is only stored unencrypted in the OpenSSH format
+		if passphraseMissingError.PublicKey != nil {
+			keyInfo.Type = passphraseMissingError.PublicKey.Type()
+		} else {
+			keyInfo.Type = pemKeyType(trimmedContent)
+		}
+		return keyInfo, nil
+	}
+	if err != nil {
+		return nil, &FieldError{Value: path, Reason: fmt.Sprintf("SSH key %v is not a valid private key. Error: %v", path, err), Hint: supportedSshKeyFormatsHint}
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, &FieldError{Value: path, Reason: fmt.Sprintf("SSH key %v has an unsupported type. Error: %v", path, err)}
+	}
+	return &SshKeyInfo{Type: signer.PublicKey().Type()}, nil
+}
+
+// CheckSshKeyPassphrase returns an error if the passphrase does not decrypt the SSH private key. The error has no field set
+func CheckSshKeyPassphrase(path string, passphrase string) *FieldError {
+	absPath, ok := ConvertToAbsolutePath(path)
+	if !ok {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("File path %v could not be converted to an absolute path", path)}
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("SSH key %v could not be read. Error: %v", path, err)}
+	}
+	if _, err = ssh.ParseRawPrivateKeyWithPassphrase(content, []byte(passphrase)); err != nil {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("The passphrase of SSH key %v is not correct. Error: %v", path, err)}
+	}
+	return nil
+}
+
+// SshKeyPermissionsWarning returns a warning if the SSH private key can be accessed by users other than its owner, or an empty string otherwise.
+// SSH refuses to use such a key on the host, whereas the copy in the container is always restricted to its user.
+// Permissions are not checked on Windows, where they do not map to the permission bits
+func SshKeyPermissionsWarning(path string) string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	absPath, ok := ConvertToAbsolutePath(path)
+	if !ok {
+		return ""
+	}
+	fileInfo, err := os.Stat(absPath)
+	if err != nil || fileInfo.Mode().Perm()&0077 == 0 {
+		return ""
+	}
+	return fmt.Sprintf("SSH key %v can be accessed by other users, as its permissions are %v. Please restrict them with: chmod 600 %v",
+		path, fileInfo.Mode().Perm(), path)
+}
+
+// isAuthorizedKey returns whether the content is a public key in the format of the authorized_keys file, e.g. ssh-ed25519 AAAA... me@host
+func isAuthorizedKey(content []byte) bool {
+	_, _, _, _, err := ssh.ParseAuthorizedKey(content)
+	return err == nil
+}
+
+// pemKeyType returns the type of an encrypted key in the PEM format, whose public key cannot be read without the passphrase
+func pemKeyType(content []byte) string {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return ""
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return ssh.KeyAlgoRSA
+	case "EC PRIVATE KEY":
+		return "ecdsa"
+	case "DSA PRIVATE KEY":
+		return ssh.KeyAlgoDSA
+	default:
+		return ""
+	}
+}
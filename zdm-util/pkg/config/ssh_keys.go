@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// sshKeyHostPatternsSeparator separates the path of an SSH key from its host patterns, e.g. /home/me/monitoring_key=10.0.0.5
+	sshKeyHostPatternsSeparator = "="
+
+	sshKeyFormatHint = "Use the form <key path>=<host pattern> [<host pattern> ...], e.g. ~/.ssh/monitoring_key=172.18.10.5"
+)
+
+// hostPatternRegexp matches a pattern of the Host keyword of the SSH configuration, optionally negated
+var hostPatternRegexp = regexp.MustCompile(`^!?[A-Za-z0-9.*?_:%\[\]-]+$`)
+
+// SshKey is an SSH private key on the host, together with the patterns of the hosts it gives access to.
+// The patterns use the syntax of the Host keyword of the SSH configuration, e.g. 172.19.* or 10.0.0.5
+type SshKey struct {
+	PathOnHost   string   `json:"pathOnHost"`
+	HostPatterns []string `json:"hostPatterns"`
+}
+
+func (k SshKey) String() string {
+	return k.PathOnHost + sshKeyHostPatternsSeparator + strings.Join(k.HostPatterns, " ")
+}
+
+// ParseSshKeys parses a list of SSH keys separated by commas, each in the form <key path>=<host pattern> [<host pattern> ...].
+// Malformed keys are kept as they are, so that they are reported by Validate
+func ParseSshKeys(value string) []SshKey {
+	var sshKeys []SshKey
+	for _, entry := range strings.Split(value, listValueSeparator) {
+		entry = FormatString(entry)
+		if entry == "" {
+			continue
+		}
+		sshKey := SshKey{PathOnHost: entry}
+		// the host patterns cannot contain the separator, whereas the path could
+		if separatorIdx := strings.LastIndex(entry, sshKeyHostPatternsSeparator); separatorIdx >= 0 {
+			sshKey.PathOnHost = FormatString(entry[:separatorIdx])
+			if hostPatterns := strings.Fields(entry[separatorIdx+1:]); len(hostPatterns) > 0 {
+				sshKey.HostPatterns = hostPatterns
+			}
+		}
+		sshKeys = append(sshKeys, sshKey)
+	}
+	return sshKeys
+}
+
+// FormatSshKeys is the reverse of ParseSshKeys
+func FormatSshKeys(sshKeys []SshKey) string {
+	entries := make([]string, 0, len(sshKeys))
+	for _, sshKey := range sshKeys {
+		entries = append(entries, sshKey.String())
+	}
+	return strings.Join(entries, listValueSeparator)
+}
+
+// SshKeyPaths returns the path of the main SSH key followed by those of the additional keys, listing each file only once
+func (c *ContainerInitConfig) SshKeyPaths() []string {
+	sshKeyPaths := make([]string, 0, len(c.AdditionalSshKeys)+1)
+	if c.SshKeyPathOnHost != "" {
+		sshKeyPaths = append(sshKeyPaths, c.SshKeyPathOnHost)
+	}
+	for _, sshKey := range c.AdditionalSshKeys {
+		if !slices.Contains(sshKeyPaths, sshKey.PathOnHost) {
+			sshKeyPaths = append(sshKeyPaths, sshKey.PathOnHost)
+		}
+	}
+	return sshKeyPaths
+}
+
+// CheckHostPattern returns an error if the value is not a valid pattern for the Host keyword of the SSH configuration. The error has no field set.
+// A CIDR range is also accepted, as it is translated into patterns, see HostPatterns
+func CheckHostPattern(hostPattern string) *FieldError {
+	if IsCidr(hostPattern) {
+		return CheckCidr(hostPattern)
+	}
+	if !hostPatternRegexp.MatchString(hostPattern) {
+		return &FieldError{
+			Value:  hostPattern,
+			Reason: fmt.Sprintf("Invalid host pattern %v", hostPattern),
+			Hint:   "A host pattern can only contain letters, digits, dots, hyphens, colons and the wildcards * and ?, e.g. 172.19.*, 10.0.0.5 or fd00:10::5, or a CIDR range",
+		}
+	}
+	return nil
+}
+
+// checkSshKeys checks each additional key and its host patterns. As all keys are copied to the same directory of the container,
+// their file names must also be different from each other and from the file name of the main key, unless they are the same file.
+// The errors have no field set
+func checkSshKeys(mainSshKeyPath string, sshKeys []SshKey) ValidationErrors {
+	validationErrors := make(ValidationErrors, 0)
+	pathsByFileName := make(map[string]string)
+	if mainSshKeyPath != "" {
+		pathsByFileName[filepath.Base(mainSshKeyPath)] = mainSshKeyPath
+	}
+
+	for _, sshKey := range sshKeys {
+		if sshKey.PathOnHost == "" || len(sshKey.HostPatterns) == 0 {
+			validationErrors = append(validationErrors, &FieldError{
+				Value:  sshKey.String(),
+				Reason: fmt.Sprintf("Malformed SSH key %v", sshKey),
+				Hint:   sshKeyFormatHint,
+			})
+			continue
+		}
+		if fieldError := CheckSshPrivateKey(sshKey.PathOnHost); fieldError != nil {
+			validationErrors = append(validationErrors, fieldError)
+		}
+		for _, hostPattern := range sshKey.HostPatterns {
+			if fieldError := CheckHostPattern(hostPattern); fieldError != nil {
+				validationErrors = append(validationErrors, fieldError)
+			}
+		}
+
+		fileName := filepath.Base(sshKey.PathOnHost)
+		if otherPath, found := pathsByFileName[fileName]; found && otherPath != sshKey.PathOnHost {
+			validationErrors = append(validationErrors, &FieldError{
+				Value:  sshKey.PathOnHost,
+				Reason: fmt.Sprintf("SSH keys %v and %v have the same file name", otherPath, sshKey.PathOnHost),
+				Hint:   "Rename one of them, as all keys are copied to the same directory of the container",
+			})
+		}
+		pathsByFileName[fileName] = sshKey.PathOnHost
+	}
+	return validationErrors
+}
+
+// ValidateHostPatterns checks space-separated host patterns, printing the reason if any of them is not valid. It can be used as a prompt validator
+func ValidateHostPatterns(hostPatterns string) bool {
+	for _, hostPattern := range strings.Fields(hostPatterns) {
+		if !printFieldError(CheckHostPattern(hostPattern)) {
+			return false
+		}
+	}
+	return true
+}
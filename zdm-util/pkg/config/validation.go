@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"github.com/phayes/permbits"
+	"net"
+	"os"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+const ipAddressPrefixExampleHint = "Example: 172.* or 172.18.* or 172.18.10.* or fd00:10:*"
+
+// FieldError describes why the value of a configuration field is not valid, and how to fix it
+type FieldError struct {
+	Field string `json:"field"`
+	Value string `json:"value,omitempty"`
+	// Source is where the value comes from, if known
+	Source PropertySource `json:"source,omitempty"`
+	Reason string         `json:"reason"`
+	Hint   string         `json:"hint,omitempty"`
+}
+
+// Message renders the reason and the hint, as displayed to the user when validating the value interactively
+func (e *FieldError) Message() string {
+	if e.Hint == "" {
+		return e.Reason
+	}
+	return e.Reason + ". " + e.Hint
+}
+
+func (e *FieldError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("%v: %v", e.Field, e.Message())
+	}
+	return fmt.Sprintf("%v: %v (from %v)", e.Field, e.Message(), DescribePropertySource(e.Field, e.Source))
+}
+
+// ValidationErrors lists all the problems found in a configuration, so that they can be reported at once
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Validate checks all the properties of the configuration, returning an error for each problem found with a required property that
+// is missing or with a property that is not valid
+func (c *ContainerInitConfig) Validate() ValidationErrors {
+	validationErrors := make(ValidationErrors, 0)
+	for _, propertyName := range propertyNames {
+		validationErrors = append(validationErrors, c.ValidateProperty(propertyName)...)
+	}
+	return validationErrors
+}
+
+// ValidateProperty checks the property with the specified name, returning an error for each problem found, or none if it is valid
+func (c *ContainerInitConfig) ValidateProperty(propertyName string) ValidationErrors {
+	value, found := c.Property(propertyName)
+	var validationErrors ValidationErrors
+	switch {
+	case !found && IsRequiredProperty(propertyName):
+		validationErrors = ValidationErrors{{
+			Reason: "not provided",
+			Hint: fmt.Sprintf("Specify it with flag -%v, environment variable %v or in the configuration file",
+				FlagNameForProperty(propertyName), EnvVarNameForProperty(propertyName)),
+		}}
+	case !found:
+		return nil
+	case propertyName == ProxyIpAddressPrefixPropertyName:
+		validationErrors = ValidationErrors{CheckIpAddressPrefix(value)}
+	case propertyName == SshKeyPathOnHostPropertyName:
+		validationErrors = ValidationErrors{CheckSshPrivateKey(value)}
+	case propertyName == AnsibleInventoryPathOnHostPropertyName:
+		validationErrors = ValidationErrors{CheckAnsibleInventory(value, inventory.MinNumberOfProxiesForLocalTesting)}
+	case propertyName == AdditionalSshKeysPropertyName:
+		validationErrors = checkSshKeys(c.SshKeyPathOnHost, c.AdditionalSshKeys)
+	case propertyName == JumphostPublicIpAddressPropertyName:
+		validationErrors = ValidationErrors{CheckHostAddress(value)}
+	case propertyName == JumphostPrivateIpAddressPropertyName:
+		validationErrors = ValidationErrors{CheckHostAddress(value)}
+		if c.JumphostPublicIpAddress == "" {
+			validationErrors = append(validationErrors, &FieldError{
+				Value:  value,
+				Reason: "The private IP address of the jumphost is set, but not its public IP address",
+				Hint: fmt.Sprintf("Specify %v too, or remove %v if the proxies can be reached directly",
+					JumphostPublicIpAddressPropertyName, JumphostPrivateIpAddressPropertyName),
+			})
+		}
+	case propertyName == RemoteUserPropertyName:
+		validationErrors = ValidationErrors{CheckRemoteUser(value)}
+	case propertyName == HostOverridesPropertyName:
+		validationErrors = checkHostOverrides(c.HostOverrides)
+	default:
+		validationErrors = ValidationErrors{{Value: value, Reason: "unknown property"}}
+	}
+
+	propertyErrors := make(ValidationErrors, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		if fieldError != nil {
+			fieldError.Field = propertyName
+			fieldError.Source = c.Sources[propertyName]
+			propertyErrors = append(propertyErrors, fieldError)
+		}
+	}
+	return propertyErrors
+}
+
+// CheckUnknownProperties returns an error for each unknown property found in the configuration file, suggesting the known property
+// it may be a misspelling of. Unknown properties are otherwise ignored, so these are only errors in strict mode, see WarnAboutUnknownProperties
+func (c *ContainerInitConfig) CheckUnknownProperties() ValidationErrors {
+	validationErrors := make(ValidationErrors, 0)
+	for _, propertyName := range sortedKeys(c.UnknownProperties) {
+		validationErrors = append(validationErrors, &FieldError{
+			Field:  propertyName,
+			Value:  c.UnknownProperties[propertyName],
+			Source: FileSource,
+			Reason: "unknown property",
+			Hint:   unknownPropertyHint(propertyName),
+		})
+	}
+	return validationErrors
+}
+
+// WarnAboutUnknownProperties prints a warning for each unknown property found in the configuration file, which is ignored when not in strict mode
+func (c *ContainerInitConfig) WarnAboutUnknownProperties() {
+	for _, fieldError := range c.CheckUnknownProperties() {
+		logger.Warnf("%v. It is ignored \n", fieldError)
+	}
+}
+
+func unknownPropertyHint(propertyName string) string {
+	if suggestion := suggestPropertyName(propertyName); suggestion != "" {
+		return fmt.Sprintf("Did you mean %v?", suggestion)
+	}
+	return "Valid properties are: " + strings.Join(propertyNames, ", ")
+}
+
+// suggestPropertyName returns the known property that is closest to the name, if the name is likely a misspelling of it or a shortened form of it.
+// It returns an empty string otherwise
+func suggestPropertyName(name string) string {
+	normalizedName := strings.ReplaceAll(strings.ToLower(FormatString(name)), "-", "_")
+	suggestion := ""
+	minDistance := -1
+	for _, propertyName := range append([]string{ConfigVersionKey}, propertyNames...) {
+		distance := editDistance(normalizedName, propertyName)
+		isShortenedForm := len(normalizedName) >= 4 && strings.HasPrefix(propertyName, normalizedName)
+		if distance > len(propertyName)/4 && !isShortenedForm {
+			continue
+		}
+		if minDistance < 0 || distance < minDistance {
+			suggestion = propertyName
+			minDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// editDistance returns the Levenshtein distance between the strings, which is the number of single character insertions,
+// deletions or substitutions needed to change one into the other
+func editDistance(s string, t string) int {
+	previousRow := make([]int, len(t)+1)
+	for j := range previousRow {
+		previousRow[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		currentRow := make([]int, len(t)+1)
+		currentRow[0] = i
+		for j := 1; j <= len(t); j++ {
+			substitutionCost := 1
+			if s[i-1] == t[j-1] {
+				substitutionCost = 0
+			}
+			currentRow[j] = min(previousRow[j]+1, currentRow[j-1]+1, previousRow[j-1]+substitutionCost)
+		}
+		previousRow = currentRow
+	}
+	return previousRow[len(t)]
+}
+
+// CheckFilePath returns an error if the path does not refer to a regular file readable by the user. The error has no field set
+func CheckFilePath(path string) *FieldError {
+	return checkFilePath(path, false)
+}
+
+// CheckPathOfWritableFile is like CheckFilePath, and also requires the file to be writable by the user
+func CheckPathOfWritableFile(path string) *FieldError {
+	return checkFilePath(path, true)
+}
+
+func checkFilePath(path string, needsWritePermission bool) *FieldError {
+
+	absPath, ok := ConvertToAbsolutePath(path)
+	if !ok {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("File path %v could not be converted to an absolute path", path)}
+	}
+	fileInfo, err := os.Stat(absPath)
+	if err != nil {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("File %v is invalid. Error: %v", path, err)}
+	}
+	if fileInfo.IsDir() {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("File %v is actually a directory, not a file", path)}
+	}
+
+	if !fileInfo.Mode().IsRegular() {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("File %v is not a regular file", path)}
+	}
+
+	permissions, err := permbits.Stat(absPath)
+	if err != nil {
+		// TODO should we return an error or just show a warning message but still proceed?
+		return &FieldError{Value: path, Reason: fmt.Sprintf("Permissions for file %v could not be checked. Error: %v", path, err)}
+	}
+
+	// TODO at the moment we are checking the user permissions - should this be different?
+	// TODO should we also validate that the user running the utility is indeed the user owning the file?
+	// at least read permission is always needed
+	if !permissions.UserRead() {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("Read permission needed for file %v, but this file is not readable by the user. Permissions: %v", path, permissions)}
+	}
+
+	if needsWritePermission && !permissions.UserWrite() {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("Write permission needed for file %v, but this file is not writable by the user. Permissions: %v", path, permissions)}
+	}
+
+	return nil
+}
+
+// CheckAnsibleInventory returns an error if the file is not an Ansible inventory that the playbooks can use with at least the specified
+// number of proxies, listing each problem found with its line number. The error has no field set
+func CheckAnsibleInventory(path string, minNumberOfProxies int) *FieldError {
+	if fieldError := CheckFilePath(path); fieldError != nil {
+		return fieldError
+	}
+	absPath, _ := ConvertToAbsolutePath(path)
+	err := inventory.CheckFile(absPath, minNumberOfProxies)
+	if err == nil {
+		return nil
+	}
+	inventoryErrors, ok := err.(inventory.Errors)
+	if !ok {
+		return &FieldError{Value: path, Reason: fmt.Sprintf("Ansible inventory %v could not be read. Error: %v", path, err)}
+	}
+	problems := make([]string, 0, len(inventoryErrors))
+	for _, inventoryError := range inventoryErrors {
+		problems = append(problems, inventoryError.Error())
+	}
+	return &FieldError{
+		Value:  path,
+		Reason: fmt.Sprintf("Ansible inventory %v is not valid: \n - %v", path, strings.Join(problems, "\n - ")),
+		Hint:   "See the example inventory zdm_ansible_inventory_example in the automation repository",
+	}
+}
+
+// ValidateAnsibleInventory checks the inventory with the minimum number of proxies of local testing deployments, printing the problems found
+// if it is not valid. It can be used as a prompt validator
+func ValidateAnsibleInventory(path string) bool {
+	return printFieldError(CheckAnsibleInventory(path, inventory.MinNumberOfProxiesForLocalTesting))
+}
+
+// CheckIpAddressPrefix returns an error if the prefix is not made of one to three octets followed by an asterisk, nor an IPv6 prefix
+// followed by an asterisk, nor a valid CIDR range. The error has no field set
+func CheckIpAddressPrefix(ipPrefix string) *FieldError {
+
+	if IsCidr(ipPrefix) {
+		return CheckCidr(ipPrefix)
+	}
+
+	trimmedIpPrefix := FormatString(ipPrefix)
+	malformedPrefixError := func(reason string) *FieldError {
+		return &FieldError{
+			Value:  trimmedIpPrefix,
+			Reason: fmt.Sprintf("Malformed IP Address prefix %v. %v", trimmedIpPrefix, reason),
+			Hint:   ipAddressPrefixExampleHint,
+		}
+	}
+
+	if strings.Contains(trimmedIpPrefix, ":") {
+		if reason := checkIpv6AddressPrefix(trimmedIpPrefix); reason != "" {
+			return malformedPrefixError(reason)
+		}
+		return nil
+	}
+
+	prefixComponents := strings.Split(trimmedIpPrefix, ".")
+
+	if len(prefixComponents) == 1 && prefixComponents[0] == trimmedIpPrefix {
+		return malformedPrefixError("At least one octet must be specified")
+	}
+
+	if len(prefixComponents) > 4 {
+		return malformedPrefixError("Too many octets were specified")
+	}
+
+	if prefixComponents[len(prefixComponents)-1] != "*" {
+		return malformedPrefixError("The least significant byte must be an asterisk")
+	}
+
+	numberOfAsterisks := strings.Count(trimmedIpPrefix, "*")
+	if numberOfAsterisks == 0 || numberOfAsterisks > 1 {
+		return malformedPrefixError("Exactly one asterisk must be present")
+	}
+
+	expandedPrefix := ""
+	for i := 0; i < 4; i++ {
+		if i < len(prefixComponents) && prefixComponents[i] != "*" {
+			if expandedPrefix == "" {
+				expandedPrefix = prefixComponents[i]
+			} else {
+				expandedPrefix = expandedPrefix + "." + prefixComponents[i]
+			}
+		} else {
+			expandedPrefix = expandedPrefix + ".0"
+		}
+	}
+
+	if CheckIPAddress(expandedPrefix) != nil {
+		return malformedPrefixError("One or more octets may be out of range")
+	}
+	return nil
+}
+
+// checkIpv6AddressPrefix returns the reason why the IPv6 prefix is malformed, or an empty string if it is valid.
+// The prefix is matched against the canonical form of the addresses, so its groups must be in the same form
+func checkIpv6AddressPrefix(ipPrefix string) string {
+	if !strings.HasSuffix(ipPrefix, ":*") {
+		return "The prefix must end with a colon followed by an asterisk"
+	}
+	if strings.Count(ipPrefix, "*") > 1 {
+		return "Exactly one asterisk must be present"
+	}
+
+	prefixWithoutAsterisk := strings.TrimSuffix(ipPrefix, "*")
+	for _, group := range strings.Split(prefixWithoutAsterisk, ":") {
+		if group == "" {
+			continue
+		}
+		if len(group) > 4 || strings.Trim(group, "0123456789abcdef") != "" || (len(group) > 1 && group[0] == '0') {
+			return fmt.Sprintf("Group %v must be written as in the canonical form of the addresses, with lowercase hexadecimal digits and without leading zeros", group)
+		}
+	}
+
+	// the prefix is valid if it can be completed into an address, with or without a compressed run of zero groups
+	if CheckIPAddress(prefixWithoutAsterisk+"1") != nil && CheckIPAddress(prefixWithoutAsterisk+":1") != nil {
+		return "The prefix cannot be completed into a valid IPv6 address"
+	}
+	return ""
+}
+
+// CheckIPAddress returns an error if the value is not a valid IP address. The error has no field set
+func CheckIPAddress(ipAddress string) *FieldError {
+	if net.ParseIP(ipAddress) == nil {
+		return &FieldError{Value: ipAddress, Reason: fmt.Sprintf("Invalid IP Address %v", ipAddress)}
+	}
+	return nil
+}
+
+// CheckHostAddress returns an error if the value is neither a valid IP address nor a hostname as defined by RFC 1123, e.g. proxy-0.example.internal.
+// The error has no field set
+func CheckHostAddress(address string) *FieldError {
+	if net.ParseIP(address) == nil && !inventory.IsHostname(address) {
+		return &FieldError{
+			Value:  address,
+			Reason: fmt.Sprintf("Invalid IP Address or hostname %v", address),
+			Hint:   "A hostname is made of labels separated by dots, each made of letters, digits and hyphens, e.g. proxy-0.example.internal",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// newDockerClient creates a Docker client configured from the environment.
+// In verbose mode, every call made to the Docker API is logged together with its outcome and duration
+func newDockerClient() (*client.Client, error) {
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if logger.IsVerbose() {
+		// the calls are observed through the tracing of the client, as replacing its transport would change how it connects to the daemon
+		opts = append(opts, client.WithTraceProvider(apiCallLoggingTracerProvider{}))
+	}
+	return client.NewClientWithOpts(opts...)
+}
+
+// apiCallLoggingTracerProvider logs the requests sent to the Docker API at debug level. The client reports each request as a span,
+// named after its method and path, that is given the status code of the response, or whose status is set to an error if the request failed
+type apiCallLoggingTracerProvider struct {
+	noop.TracerProvider
+}
+
+func (p apiCallLoggingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return apiCallLoggingTracer{}
+}
+
+type apiCallLoggingTracer struct {
+	noop.Tracer
+}
+
+func (t apiCallLoggingTracer) Start(ctx context.Context, spanName string, _ ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &apiCallLoggingSpan{name: spanName, start: time.Now()}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// apiCallLoggingSpan logs the request once its outcome is known, which is when its status is set, right after the response is received.
+// The span itself only ends when the body of the response is closed, which may be much later for streams
+type apiCallLoggingSpan struct {
+	noop.Span
+	name       string
+	start      time.Time
+	statusCode int64
+}
+
+func (s *apiCallLoggingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		if attr.Key == "http.status_code" || attr.Key == "http.response.status_code" {
+			s.statusCode = attr.Value.AsInt64()
+		}
+	}
+}
+
+func (s *apiCallLoggingSpan) SetStatus(code codes.Code, description string) {
+	if code == codes.Error && s.statusCode == 0 {
+		logger.Debugf("Docker API call %v failed after %v: %v \n", s.name, time.Since(s.start), description)
+		return
+	}
+	logger.Debugf("Docker API call %v returned %v after %v \n", s.name, s.statusCode, time.Since(s.start))
+}
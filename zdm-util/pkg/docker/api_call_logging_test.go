@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"context"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+func TestApiCallLoggingTracerProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_ping" {
+			w.Header().Set("Api-Version", "1.44")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	transcriptDir := t.TempDir()
+	_, err := logger.StartTranscript(transcriptDir)
+	require.Nil(t, err)
+
+	cli, err := client.NewClientWithOpts(client.WithHost("tcp://"+server.Listener.Addr().String()), client.WithVersion("1.44"),
+		client.WithTraceProvider(apiCallLoggingTracerProvider{}))
+	require.Nil(t, err)
+	_, err = cli.Ping(context.Background())
+	require.Nil(t, err)
+	_, err = cli.ContainerInspect(context.Background(), "missing")
+	require.NotNil(t, err)
+	server.Close()
+	_, err = cli.Ping(context.Background())
+	require.NotNil(t, err)
+	logger.CloseTranscript()
+
+	transcripts, err := filepath.Glob(filepath.Join(transcriptDir, "*"))
+	require.Nil(t, err)
+	require.Len(t, transcripts, 1)
+	transcript, err := os.ReadFile(transcripts[0])
+	require.Nil(t, err)
+	require.Regexp(t, `DEBUG Docker API call HEAD /_ping returned 200 after \S+`, string(transcript))
+	require.Regexp(t, `DEBUG Docker API call GET /v1.44/containers/missing/json returned 404 after \S+`, string(transcript))
+	require.Regexp(t, `DEBUG Docker API call HEAD /_ping failed after \S+: .*connection refused`, string(transcript))
+}
@@ -0,0 +1,254 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+	"zdm-proxy-automation/zdm-util/pkg/output"
+	"zdm-proxy-automation/zdm-util/pkg/userinteraction"
+)
+
+const (
+	sshConfigPathOnContainer    = containerHomeDir + "/.ssh/config"
+	archivedLogsPathOnContainer = containerHomeDir + "/zdm_proxy_archived_logs"
+	ansibleVarsPathOnContainer  = ansibleDirPathOnContainer + "/vars"
+
+	containerBackupStep = "container-backup"
+)
+
+// ContainerDestructionOptions controls how the container is destroyed
+type ContainerDestructionOptions struct {
+	// AssumeYes skips all confirmation prompts
+	AssumeYes bool
+	// BackupFilePath is the path of the tarball to which the container state is exported before removal.
+	// If empty and AssumeYes is false, the user is asked whether to back up the container state to DefaultBackupFilePath
+	BackupFilePath        string
+	DefaultBackupFilePath string
+	// InventoryName is the name of the Ansible inventory file in the Ansible directory of the container
+	InventoryName string
+	// Profile is the configuration profile whose container is destroyed
+	Profile  string
+	Reporter *output.Reporter
+}
+
+// ContainerDestructionResult describes what was done to destroy the container
+type ContainerDestructionResult struct {
+	ContainerId    string   `json:"containerId,omitempty"`
+	ContainerName  string   `json:"containerName"`
+	BackupFilePath string   `json:"backupFilePath,omitempty"`
+	BackedUpPaths  []string `json:"backedUpPaths"`
+	Removed        bool     `json:"removed"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// DestroyContainer removes the Ansible Control Host container, optionally exporting its state to a local tarball first.
+// The result is always returned, also when an error occurs, and describes what was done up to that point
+func DestroyContainer(ctx context.Context, userInputReader *bufio.Reader, options ContainerDestructionOptions) (*ContainerDestructionResult, error) {
+	result := &ContainerDestructionResult{
+		ContainerName: ContainerNameForProfile(options.Profile),
+		BackedUpPaths: make([]string, 0),
+	}
+
+	orchestrator, err := createDockerOrchestrator(ctx, options.Profile)
+	if err != nil {
+		return result, fmt.Errorf("unable to create a Docker client: %v", err)
+	}
+	defer orchestrator.CloseDockerClient()
+	orchestrator.reporter = options.Reporter
+
+	step := orchestrator.reporter.StartStep(containerLookupStep)
+	containerId, _, err := orchestrator.retrieveExistingContainer(orchestrator.containerName)
+	step.Done(err, output.Ids{"containerId": containerId})
+	if err != nil {
+		return result, fmt.Errorf("unable to check whether the container exists: %v", err)
+	}
+	if containerId == "" {
+		logger.Infof("The container %v does not exist, so there is nothing to destroy \n", orchestrator.containerName)
+		return result, nil
+	}
+	result.ContainerId = containerId
+
+	backupFilePath, err := decideBackupFilePath(userInputReader, options)
+	if err != nil {
+		return result, err
+	}
+	if backupFilePath == "" {
+		orchestrator.reporter.StartStep(containerBackupStep).Skipped(output.Ids{"containerId": containerId})
+	} else {
+		step = orchestrator.reporter.StartStep(containerBackupStep)
+		result.BackedUpPaths, err = orchestrator.backUpContainerState(containerId, options.InventoryName, backupFilePath)
+		step.Done(err, output.Ids{"containerId": containerId, "backupFilePath": backupFilePath})
+		if err != nil {
+			return result, fmt.Errorf("the container state could not be backed up to %v, so the container was not removed: %v", backupFilePath, err)
+		}
+		result.BackupFilePath = backupFilePath
+		logger.Infof("Container state successfully backed up to %v \n", backupFilePath)
+	}
+
+	if !options.AssumeYes {
+		logger.Infoln()
+		ynRemove, err := userinteraction.YesNoPrompt(fmt.Sprintf("The container %v will be removed. All its data and configuration will be lost. Are you sure you want to proceed?", orchestrator.containerName),
+			true, false, userInputReader, userinteraction.DefaultMaxAttempts)
+		if err != nil {
+			return result, fmt.Errorf("no clear confirmation was given about removing the container: %v", err)
+		}
+		if !ynRemove {
+			logger.Infof("You decided not to remove the container %v \n", orchestrator.containerName)
+			return result, nil
+		}
+	}
+
+	step = orchestrator.reporter.StartStep(containerRemoveStep)
+	err = orchestrator.removeExistingContainer(containerId)
+	step.Done(err, output.Ids{"containerId": containerId})
+	if err != nil {
+		return result, fmt.Errorf("the container %v could not be removed: %v", containerId, err)
+	}
+	result.Removed = true
+	logger.Infof("Container %v with ID %v successfully removed \n", orchestrator.containerName, containerId)
+	return result, nil
+}
+
+// decideBackupFilePath returns the path of the backup tarball, or an empty string if no backup should be made
+func decideBackupFilePath(userInputReader *bufio.Reader, options ContainerDestructionOptions) (string, error) {
+	if options.BackupFilePath != "" || options.AssumeYes {
+		return options.BackupFilePath, nil
+	}
+
+	logger.Infoln()
+	ynBackup, err := userinteraction.YesNoPrompt(fmt.Sprintf("Do you wish to back up the Ansible configuration, inventory, SSH configuration and collected logs of the container to %v before removing it?",
+		options.DefaultBackupFilePath), true, true, userInputReader, userinteraction.DefaultMaxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("it is not clear whether you wish to back up the container state: %v", err)
+	}
+	if ynBackup {
+		return options.DefaultBackupFilePath, nil
+	}
+	return "", nil
+}
+
+// backUpContainerState writes the state worth keeping from the container to a gzipped tarball, with paths relative to the home directory of the container.
+// Paths that do not exist in the container are skipped. It returns the paths that were backed up
+func (o *DockerOrchestrator) backUpContainerState(containerId string, inventoryName string, backupFilePath string) (backedUpPaths []string, err error) {
+	pathsToBackUp, err := o.listPathsToBackUp(containerId, inventoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	backupFile, err := os.OpenFile(backupFilePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := backupFile.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// an incomplete backup must not be mistaken for a good one
+			_ = os.Remove(backupFilePath)
+		}
+	}()
+
+	gzipWriter := gzip.NewWriter(backupFile)
+	tarWriter := tar.NewWriter(gzipWriter)
+	backedUpPaths = make([]string, 0, len(pathsToBackUp))
+	for _, pathToBackUp := range pathsToBackUp {
+		logger.Infof("Backing up %v \n", pathToBackUp)
+		if err = o.copyContainerPathToTar(containerId, pathToBackUp, tarWriter); err != nil {
+			return nil, fmt.Errorf("unable to back up %v: %v", pathToBackUp, err)
+		}
+		backedUpPaths = append(backedUpPaths, pathToBackUp)
+	}
+	if err = tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return backedUpPaths, nil
+}
+
+// listPathsToBackUp returns the paths of the Ansible configuration files, inventory, SSH configuration and log archives that exist in the container
+func (o *DockerOrchestrator) listPathsToBackUp(containerId string, inventoryName string) ([]string, error) {
+	paths := make([]string, 0)
+
+	varsFileNames, err := o.listFilesInContainerDirectory(containerId, ansibleVarsPathOnContainer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the Ansible configuration files: %v", err)
+	}
+	for _, varsFileName := range varsFileNames {
+		if strings.HasSuffix(varsFileName, ".yml") {
+			paths = append(paths, path.Join(ansibleVarsPathOnContainer, varsFileName))
+		}
+	}
+
+	type candidatePath struct {
+		path  string
+		isDir bool
+	}
+	candidatePaths := []candidatePath{
+		{sshConfigPathOnContainer, false},
+		{archivedLogsPathOnContainer, true},
+	}
+	if inventoryName != "" {
+		candidatePaths = append([]candidatePath{{path.Join(ansibleDirPathOnContainer, inventoryName), false}}, candidatePaths...)
+	}
+	for _, candidate := range candidatePaths {
+		exists, err := o.pathExistsInContainer(containerId, candidate.path, candidate.isDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check whether %v exists: %v", candidate.path, err)
+		}
+		if exists {
+			paths = append(paths, candidate.path)
+		}
+	}
+	return paths, nil
+}
+
+// copyContainerPathToTar adds the file or directory at the specified path in the container to the tar archive.
+// The archive returned by Docker has entries relative to the parent of the path, so they are rewritten relative to the home directory
+func (o *DockerOrchestrator) copyContainerPathToTar(containerId string, containerPath string, tarWriter *tar.Writer) error {
+	content, _, err := o.cli.CopyFromContainer(o.ctx, containerId, containerPath)
+	if err != nil {
+		return err
+	}
+	defer CloseReadCloser(content)
+
+	tarReader := tar.NewReader(content)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		header.Name = backupEntryName(containerPath, header.Name)
+		if err = tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// backupEntryName converts the name of an entry of the archive copied from the specified container path, which is relative to the parent of that path,
+// to a name relative to the home directory of the container
+func backupEntryName(containerPath string, entryName string) string {
+	entryPrefix := strings.TrimPrefix(strings.TrimPrefix(path.Dir(containerPath), containerHomeDir), "/")
+	name := path.Join(entryPrefix, entryName)
+	// directory entries keep their trailing slash, which path.Join removes
+	if strings.HasSuffix(entryName, "/") {
+		name += "/"
+	}
+	return name
+}
@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBackupEntryName(t *testing.T) {
+	tests := []struct {
+		name          string
+		containerPath string
+		entryName     string
+		expectedName  string
+	}{
+		{
+			name:          "ansible configuration file",
+			containerPath: "/home/ubuntu/zdm-proxy-automation/ansible/vars/zdm_proxy_core_config.yml",
+			entryName:     "zdm_proxy_core_config.yml",
+			expectedName:  "zdm-proxy-automation/ansible/vars/zdm_proxy_core_config.yml",
+		},
+		{
+			name:          "ssh configuration file",
+			containerPath: "/home/ubuntu/.ssh/config",
+			entryName:     "config",
+			expectedName:  ".ssh/config",
+		},
+		{
+			name:          "log archive directory",
+			containerPath: "/home/ubuntu/zdm_proxy_archived_logs",
+			entryName:     "zdm_proxy_archived_logs/",
+			expectedName:  "zdm_proxy_archived_logs/",
+		},
+		{
+			name:          "file in log archive directory",
+			containerPath: "/home/ubuntu/zdm_proxy_archived_logs",
+			entryName:     "zdm_proxy_archived_logs/zdm_proxy_logs_20240301T102030.zip",
+			expectedName:  "zdm_proxy_archived_logs/zdm_proxy_logs_20240301T102030.zip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedName, backupEntryName(tt.containerPath, tt.entryName))
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/moby/term"
+	"github.com/pkg/errors"
+
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// OpenShell attaches the local terminal to an interactive shell in the Ansible Control Host container, as user ubuntu and
+// in the Ansible directory of the automation. The container is the one of the specified profile. It returns the exit code of the shell
+func OpenShell(ctx context.Context, profile string) (int, error) {
+
+	orchestrator, err := createDockerOrchestrator(ctx, profile)
+	if err != nil {
+		return -1, fmt.Errorf("unable to create a Docker client: %v", err)
+	}
+	defer orchestrator.CloseDockerClient()
+
+	containerId, err := orchestrator.retrieveContainerReadyForUse()
+	if err != nil {
+		return -1, err
+	}
+
+	return orchestrator.execInteractiveShell(containerId)
+}
+
+func (o *DockerOrchestrator) execInteractiveShell(containerId string) (int, error) {
+	stdIn, stdOut, stdErr := term.StdStreams()
+	inFd, isInTerminal := term.GetFdInfo(stdIn)
+	outFd, isOutTerminal := term.GetFdInfo(stdOut)
+
+	execConfig := container.ExecOptions{
+		User:         containerUser,
+		Privileged:   false,
+		Tty:          isInTerminal,
+		Cmd:          []string{"/bin/bash", "--login"},
+		Env:          []string{"TERM=" + terminalType()},
+		WorkingDir:   ansibleDirPathOnContainer,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	response, err := o.cli.ContainerExecCreate(o.ctx, containerId, execConfig)
+	if err != nil {
+		return -1, err
+	}
+	execID := response.ID
+	if execID == "" {
+		return -1, errors.New("exec ID empty")
+	}
+
+	resp, err := o.cli.ContainerExecAttach(o.ctx, execID, container.ExecAttachOptions{Tty: execConfig.Tty})
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Close()
+
+	// the attached stream is not bound to the context, so it is closed explicitly on cancellation, which ends the copy of the output
+	// and restores the terminal
+	stopClosingOnCancel := context.AfterFunc(o.ctx, resp.Close)
+	defer stopClosingOnCancel()
+
+	if isInTerminal {
+		inState, err := term.SetRawTerminal(inFd)
+		if err != nil {
+			return -1, fmt.Errorf("unable to put the terminal into raw mode: %v", err)
+		}
+		defer restoreTerminal(inFd, inState)
+
+		if isOutTerminal {
+			outState, err := term.SetRawTerminalOutput(outFd)
+			if err != nil {
+				return -1, fmt.Errorf("unable to put the terminal output into raw mode: %v", err)
+			}
+			defer restoreTerminal(outFd, outState)
+		}
+
+		o.resizeExecToTerminal(execID, outFd)
+		stopMonitoringResize := o.monitorTerminalResize(execID, outFd)
+		defer stopMonitoringResize()
+	}
+
+	go func() {
+		_, _ = io.Copy(resp.Conn, stdIn)
+		_ = resp.CloseWrite()
+	}()
+
+	// the output stream is closed when the shell exits
+	if err = copyExecOutput(stdOut, stdErr, resp.Reader, execConfig.Tty); err != nil {
+		if o.ctx.Err() != nil {
+			return -1, o.ctx.Err()
+		}
+		return -1, err
+	}
+
+	return o.waitForExecExitCode(execID)
+}
+
+// copyExecOutput copies the output of an exec to the local output streams. Without a TTY, Docker multiplexes the standard output
+// and error of the exec in a single stream, in which each chunk is preceded by a header identifying its stream
+func copyExecOutput(stdOut, stdErr io.Writer, execOutput io.Reader, isTty bool) error {
+	if isTty {
+		_, err := io.Copy(stdOut, execOutput)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdOut, stdErr, execOutput)
+	return err
+}
+
+// resizeExecToTerminal propagates the size of the local terminal to the TTY of the exec
+func (o *DockerOrchestrator) resizeExecToTerminal(execID string, fd uintptr) {
+	winsize, err := term.GetWinsize(fd)
+	if err != nil || winsize.Height == 0 || winsize.Width == 0 {
+		return
+	}
+	_ = o.cli.ContainerExecResize(o.ctx, execID, container.ResizeOptions{
+		Height: uint(winsize.Height),
+		Width:  uint(winsize.Width),
+	})
+}
+
+func restoreTerminal(fd uintptr, state *term.State) {
+	if err := term.RestoreTerminal(fd, state); err != nil {
+		logger.Warnf("Error restoring the terminal state: %v \n", err)
+	}
+}
+
+func terminalType() string {
+	if termEnv := os.Getenv("TERM"); termEnv != "" {
+		return termEnv
+	}
+	return "xterm"
+}
@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOpenShell_ContainerNotReadyForUse(t *testing.T) {
+	tests := []struct {
+		name          string
+		dockerApi     *fakeDockerApi
+		expectedError string
+	}{
+		{
+			name:          "no container",
+			dockerApi:     &fakeDockerApi{},
+			expectedError: "does not exist. Please run this utility to create and initialize it first",
+		},
+		{
+			name:          "stopped container",
+			dockerApi:     &fakeDockerApi{containerId: "abcd", containerState: "exited"},
+			expectedError: "is not running. Please start it with: docker start",
+		},
+		{
+			name:          "container without automation",
+			dockerApi:     &fakeDockerApi{containerId: "abcd", containerState: "running"},
+			expectedError: "Please run this utility to initialize the container first",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.dockerApi.start(t)
+
+			exitCode, err := OpenShell(context.Background(), "")
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tt.expectedError)
+			require.Equal(t, -1, exitCode)
+		})
+	}
+}
+
+func TestTerminalType(t *testing.T) {
+	t.Setenv("TERM", "screen-256color")
+	require.Equal(t, "screen-256color", terminalType())
+
+	t.Setenv("TERM", "")
+	require.Equal(t, "xterm", terminalType())
+}
+
+func TestCopyExecOutput(t *testing.T) {
+	multiplexedOutput := &bytes.Buffer{}
+	_, err := stdcopy.NewStdWriter(multiplexedOutput, stdcopy.Stdout).Write([]byte("ubuntu@container:~$ ls\n"))
+	require.Nil(t, err)
+	_, err = stdcopy.NewStdWriter(multiplexedOutput, stdcopy.Stderr).Write([]byte("ls: cannot access 'missing'\n"))
+	require.Nil(t, err)
+
+	stdOut, stdErr := &bytes.Buffer{}, &bytes.Buffer{}
+	require.Nil(t, copyExecOutput(stdOut, stdErr, bytes.NewReader(multiplexedOutput.Bytes()), false))
+	require.Equal(t, "ubuntu@container:~$ ls\n", stdOut.String())
+	require.Equal(t, "ls: cannot access 'missing'\n", stdErr.String())
+
+	stdOut.Reset()
+	stdErr.Reset()
+	require.Nil(t, copyExecOutput(stdOut, stdErr, bytes.NewReader([]byte("raw terminal output\r\n")), true))
+	require.Equal(t, "raw terminal output\r\n", stdOut.String())
+	require.Empty(t, stdErr.String())
+}
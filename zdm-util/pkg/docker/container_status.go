@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	cerrdefs "github.com/containerd/errdefs"
+
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// ContainerStatus describes the state of the Ansible Control Host container and of its initialization
+type ContainerStatus struct {
+	Exists           bool     `json:"exists"`
+	ContainerId      string   `json:"containerId,omitempty"`
+	ContainerName    string   `json:"containerName"`
+	Profile          string   `json:"profile,omitempty"`
+	State            string   `json:"state,omitempty"`
+	ImageTag         string   `json:"imageTag,omitempty"`
+	ImageId          string   `json:"imageId,omitempty"`
+	ImageDigests     []string `json:"imageDigests,omitempty"`
+	RestartPolicy    string   `json:"restartPolicy,omitempty"`
+	SshKeys          []string `json:"sshKeys"`
+	InventoryName    string   `json:"inventoryName"`
+	InventoryPresent bool     `json:"inventoryPresent"`
+	AutomationCloned bool     `json:"automationCloned"`
+	InitCompleted    bool     `json:"initCompleted"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// RetrieveContainerStatus inspects the Ansible Control Host container without modifying it.
+// The container is the one of the specified profile.
+// The inventory name is needed to check whether the inventory was moved into the Ansible directory by the initialization script.
+// On error, the status returned holds what could be retrieved so far, so that it can still be reported.
+func RetrieveContainerStatus(ctx context.Context, profile string, inventoryName string) (*ContainerStatus, error) {
+	status := &ContainerStatus{
+		ContainerName: ContainerNameForProfile(profile),
+		Profile:       profile,
+		InventoryName: inventoryName,
+	}
+
+	orchestrator, err := createDockerOrchestrator(ctx, profile)
+	if err != nil {
+		return status, fmt.Errorf("unable to create a Docker client: %v", err)
+	}
+	defer orchestrator.CloseDockerClient()
+
+	containerId, _, err := orchestrator.retrieveExistingContainer(orchestrator.containerName)
+	if err != nil {
+		return status, fmt.Errorf("unable to check whether the container exists: %v", err)
+	}
+	if containerId == "" {
+		return status, nil
+	}
+	status.Exists = true
+	status.ContainerId = containerId
+
+	containerInfo, err := orchestrator.cli.ContainerInspect(orchestrator.ctx, containerId)
+	if err != nil {
+		return status, fmt.Errorf("unable to inspect the container %v: %v", containerId, err)
+	}
+	if containerInfo.State != nil {
+		status.State = containerInfo.State.Status
+	}
+	if containerInfo.Config != nil {
+		status.ImageTag = containerInfo.Config.Image
+	}
+	if containerInfo.HostConfig != nil {
+		status.RestartPolicy = string(containerInfo.HostConfig.RestartPolicy.Name)
+	}
+	status.ImageId = containerInfo.Image
+
+	imageInfo, err := orchestrator.cli.ImageInspect(orchestrator.ctx, containerInfo.Image)
+	if err != nil {
+		return status, fmt.Errorf("unable to inspect the image %v: %v", containerInfo.Image, err)
+	}
+	status.ImageDigests = imageInfo.RepoDigests
+
+	status.SshKeys, err = orchestrator.listFilesInContainerDirectory(containerId, sshKeyPathOnContainer)
+	if err != nil {
+		return status, fmt.Errorf("unable to list the SSH keys in %v: %v", sshKeyPathOnContainer, err)
+	}
+
+	status.AutomationCloned, err = orchestrator.pathExistsInContainer(containerId, automationRepoPathOnContainer, true)
+	if err != nil {
+		return status, fmt.Errorf("unable to check whether the automation repository was cloned: %v", err)
+	}
+
+	if inventoryName != "" {
+		status.InventoryPresent, err = orchestrator.pathExistsInContainer(containerId, path.Join(ansibleDirPathOnContainer, inventoryName), false)
+		if err != nil {
+			return status, fmt.Errorf("unable to check whether the Ansible inventory is present: %v", err)
+		}
+	}
+
+	status.InitCompleted, err = orchestrator.isContainerInitialized(containerId)
+	if err != nil {
+		return status, fmt.Errorf("unable to check whether the container was initialized: %v", err)
+	}
+
+	return status, nil
+}
+
+func (s *ContainerStatus) PrintStatus() {
+	if !s.Exists {
+		logger.Infof("The container %v does not exist \n", s.ContainerName)
+		return
+	}
+	logger.Infof("Container %v: \n", s.ContainerName)
+	if s.Profile != "" {
+		logger.Infof(" - Profile: %s \n", s.Profile)
+	}
+	logger.Infof(" - ID: %s \n", s.ContainerId)
+	logger.Infof(" - State: %s \n", s.State)
+	logger.Infof(" - Image: %s \n", s.ImageTag)
+	logger.Infof(" - Image ID: %s \n", s.ImageId)
+	logger.Infof(" - Image digests: %s \n", formatList(s.ImageDigests))
+	logger.Infof(" - Restart policy: %s \n", s.RestartPolicy)
+	logger.Infof(" - SSH keys in %s: %s \n", sshKeyPathOnContainer, formatList(s.SshKeys))
+	logger.Infof(" - Ansible inventory %s in %s: %s \n", s.InventoryName, ansibleDirPathOnContainer, formatPresence(s.InventoryPresent))
+	logger.Infof(" - Automation repository in %s: %s \n", automationRepoPathOnContainer, formatPresence(s.AutomationCloned))
+	logger.Infof(" - Initialization completed: %v \n", s.InitCompleted)
+}
+
+func formatList(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+func formatPresence(present bool) string {
+	if present {
+		return "present"
+	}
+	return "missing"
+}
+
+// pathExistsInContainer stats the path in the container, which also works when the container is not running
+func (o *DockerOrchestrator) pathExistsInContainer(containerId, containerPath string, expectDir bool) (bool, error) {
+	pathStat, err := o.cli.ContainerStatPath(o.ctx, containerId, containerPath)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return pathStat.Mode.IsDir() == expectDir, nil
+}
+
+// listFilesInContainerDirectory returns the names of the regular files directly contained in the specified directory.
+// The directory content is read as a tar archive, so the container does not need to be running.
+func (o *DockerOrchestrator) listFilesInContainerDirectory(containerId, containerDirPath string) ([]string, error) {
+	content, _, err := o.cli.CopyFromContainer(o.ctx, containerId, containerDirPath)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer CloseReadCloser(content)
+
+	fileNames := make([]string, 0)
+	tarReader := tar.NewReader(content)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// entries are relative to the parent of the directory, e.g. zdm-proxy-ssh-key-dir/my_key
+		if header.Typeflag == tar.TypeReg && path.Dir(header.Name) == path.Base(containerDirPath) {
+			fileNames = append(fileNames, path.Base(header.Name))
+		}
+	}
+	return fileNames, nil
+}
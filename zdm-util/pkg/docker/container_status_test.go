@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRetrieveContainerStatus_NoContainer(t *testing.T) {
+	(&fakeDockerApi{imageId: "sha256:1234"}).start(t)
+
+	status, err := RetrieveContainerStatus(context.Background(), "staging", "zdm_ansible_inventory")
+	require.Nil(t, err)
+	require.Equal(t, &ContainerStatus{
+		Exists:        false,
+		ContainerName: ContainerNameForProfile("staging"),
+		Profile:       "staging",
+		InventoryName: "zdm_ansible_inventory",
+	}, status)
+
+	statusDocument, err := json.Marshal(status)
+	require.Nil(t, err)
+	require.Contains(t, string(statusDocument), `"exists":false`)
+	require.NotContains(t, string(statusDocument), `"containerId"`)
+}
+
+func TestRetrieveContainerStatus_Error(t *testing.T) {
+	(&fakeDockerApi{imageId: "sha256:1234", containerId: "abcd", containerState: "running"}).start(t)
+
+	status, err := RetrieveContainerStatus(context.Background(), "", "zdm_ansible_inventory")
+	require.NotNil(t, err)
+	require.NotNil(t, status)
+	require.True(t, status.Exists)
+	require.Equal(t, "abcd", status.ContainerId)
+	require.Equal(t, ContainerNameForProfile(""), status.ContainerName)
+}
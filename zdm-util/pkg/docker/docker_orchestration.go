@@ -5,9 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -19,137 +20,459 @@ import (
 	"github.com/pkg/errors"
 
 	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+	"zdm-proxy-automation/zdm-util/pkg/output"
 	"zdm-proxy-automation/zdm-util/pkg/userinteraction"
 )
 
 const (
-	dockerImageName     = "datastax/zdm-ansible:2.x"
+	dockerImageName = "datastax/zdm-ansible:2.x"
+	// dockerContainerName is the name of the container of the default profile, see ContainerNameForProfile
 	dockerContainerName = "zdm-ansible-container"
-	// Note: the following two directories are on the container, not on the host
+	// profileLabel records on the container the configuration profile it was created for
+	profileLabel = "com.datastax.zdm-util.profile"
+	// initializationMarkerLabel records on the container that the completion of its initialization is recorded by a marker,
+	// see initializedMarkerPathOnContainer. The containers created by earlier versions of this utility have neither
+	initializationMarkerLabel = "com.datastax.zdm-util.initialization-marker"
+	// the container is restarted automatically, e.g. when the Docker daemon restarts, unless explicitly stopped
+	containerRestartPolicy = container.RestartPolicyUnlessStopped
+	containerUser          = "ubuntu"
+	// Note: the following paths are on the container, not on the host
+	containerHomeDir                = "/home/ubuntu"
+	initScriptPathOnContainer       = containerHomeDir + "/init_container_internal.sh"
 	sshKeyPathOnContainer           = "/home/ubuntu/zdm-proxy-ssh-key-dir"
 	ansibleInventoryPathOnContainer = "/home/ubuntu"
+	automationRepoPathOnContainer   = "/home/ubuntu/zdm-proxy-automation"
+	ansibleDirPathOnContainer       = automationRepoPathOnContainer + "/ansible"
+	// the marker is created once the initialization script has completed successfully
+	initializedMarkerPathOnContainer = "/home/ubuntu/.zdm_util_container_initialized"
+
+	initOutputTailMaxLines  = 20
+	execInspectPollInterval = 100 * time.Millisecond
+	rollbackTimeout         = 30 * time.Second
 )
 
-func ValidateDockerPrerequisites() error {
+const (
+	// names of the steps reported in machine-readable output
+	pingStep            = "ping"
+	imageCheckStep      = "image-check"
+	imagePullStep       = "image-pull"
+	containerLookupStep = "container-lookup"
+	containerRemoveStep = "container-remove"
+	containerCreateStep = "container-create"
+	containerStartStep  = "container-start"
+	copySshKeyStep      = "copy-ssh-key"
+	copyInventoryStep   = "copy-inventory"
+	containerInitStep   = "container-init"
+	// only performed when additional SSH keys are configured or the proxy addresses are specified as a CIDR range
+	copyAdditionalSshKeyStep = "copy-additional-ssh-key"
+	sshConfigStep            = "ssh-config"
+	// only performed when the passphrases of SSH keys have been entered
+	sshAgentStep = "ssh-agent"
+	// only planned in a dry run, for the files that the caller would have written on the host, see ContainerCreationOptions.HostFileWrites
+	hostFileWriteStep = "host-file-write"
+)
+
+// ContainerNameForProfile returns the name of the container of the configuration profile, so that the containers of several profiles
+// can coexist and be managed independently. The default profile keeps the original name, so that existing containers are still found
+func ContainerNameForProfile(profile string) string {
+	if profile == config.DefaultProfile {
+		return dockerContainerName
+	}
+	return dockerContainerName + "-" + profile
+}
+
+func ValidateDockerPrerequisites(ctx context.Context, reporter *output.Reporter) error {
 
-	orchestrator, err := createDockerOrchestrator()
+	orchestrator, err := createDockerOrchestrator(ctx, config.DefaultProfile)
 	if err != nil {
 		return fmt.Errorf("unable to create a Docker client: %v", err)
 	}
 	defer orchestrator.CloseDockerClient()
 
+	step := reporter.StartStep(pingStep)
 	err = orchestrator.pingServer()
+	step.Done(err, nil)
 	if err != nil {
 		return fmt.Errorf("unable to contact the Docker server due to: %v", err)
 	}
 	return nil
 }
 
-func CreateAndInitializeContainer(containerConfig *config.ContainerInitConfig, userInputReader *bufio.Reader) error {
+// ContainerCreationOptions holds the decisions that are otherwise taken by prompting the user
+type ContainerCreationOptions struct {
+	NonInteractive bool
+	// RecreateExistingContainer is only used in non-interactive mode, to decide whether an existing and initialized container must be recreated
+	RecreateExistingContainer bool
+	// DryRun only plans the actions, see PlanContainerCreation
+	DryRun bool
+	// HostFileWrites are the files that the caller would have written on the host before creating the container, had this not been a dry run.
+	// They are planned first, and are not expected to exist yet
+	HostFileWrites []HostFileWrite
+	// Reporter receives an event for each step. It can be nil
+	Reporter *output.Reporter
+	// SshKeyPassphrases are the passphrases of the SSH keys protected by one, by path of the key on the host.
+	// These keys are loaded into an SSH agent in the container, see loadSshKeysIntoAgent
+	SshKeyPassphrases map[string]string
+}
+
+// ContainerCreationResult summarizes the outcome of the container creation and initialization
+type ContainerCreationResult struct {
+	ContainerId             string            `json:"containerId,omitempty"`
+	ContainerName           string            `json:"containerName,omitempty"`
+	Image                   string            `json:"image,omitempty"`
+	ImageId                 string            `json:"imageId,omitempty"`
+	ReusedExistingContainer bool              `json:"reusedExistingContainer"`
+	CopiedFiles             []CopiedFile      `json:"copiedFiles"`
+	Configuration           map[string]string `json:"configuration,omitempty"`
+	Error                   string            `json:"error,omitempty"`
+}
+
+// HostFileWrite is a file written on the host, e.g. the generated Ansible inventory
+type HostFileWrite struct {
+	// Path is the absolute path of the file
+	Path        string
+	Description string
+}
+
+type CopiedFile struct {
+	PathOnHost      string `json:"pathOnHost"`
+	PathOnContainer string `json:"pathOnContainer"`
+}
+
+// CreateAndInitializeContainer creates, starts and initializes the container, reusing an existing one if so decided.
+// The result is always returned, also when an error occurs, and describes what was done up to that point.
+// If the context is cancelled, the resources created so far in this run are rolled back
+func CreateAndInitializeContainer(ctx context.Context, containerConfig *config.ContainerInitConfig, userInputReader *bufio.Reader, options ContainerCreationOptions) (result *ContainerCreationResult, err error) {
 
-	orchestrator, err := createDockerOrchestrator()
+	result = &ContainerCreationResult{
+		ContainerName: ContainerNameForProfile(containerConfig.Profile),
+		Image:         dockerImageName,
+		CopiedFiles:   make([]CopiedFile, 0),
+		Configuration: containerConfig.Properties(),
+	}
+
+	orchestrator, err := createDockerOrchestrator(ctx, containerConfig.Profile)
 	if err != nil {
-		return fmt.Errorf("unable to create a Docker client: %v", err)
+		return result, fmt.Errorf("unable to create a Docker client: %v", err)
 	}
 	defer orchestrator.CloseDockerClient()
+	orchestrator.reporter = options.Reporter
 
-	err = orchestrator.pullImageIfNotAlreadyPresent(dockerImageName)
+	rollback := &creationRollback{}
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			orchestrator.rollBack(rollback)
+		}
+	}()
+
+	result.ImageId, err = orchestrator.pullImageIfNotAlreadyPresent(dockerImageName)
 	if err != nil {
-		return fmt.Errorf("unable to check or pull the docker image: %v", err)
+		return result, fmt.Errorf("unable to check or pull the docker image: %v", err)
 	}
 
-	containerId, isContainerRunning, err := orchestrator.retrieveExistingContainer(dockerContainerName)
+	step := orchestrator.reporter.StartStep(containerLookupStep)
+	containerId, isContainerRunning, err := orchestrator.retrieveExistingContainer(orchestrator.containerName)
+	step.Done(err, output.Ids{"containerId": containerId})
 	if err != nil {
-		return fmt.Errorf("unable to check whether the container already exists: %v", err)
-	}
-
-	if containerId != "" {
-		if isContainerRunning {
-			fmt.Println()
-			fmt.Printf("The container %v already exists and is in running state. \n\n", dockerContainerName)
-			fmt.Printf("If you are happy to use the existing container, this utility will exit. \n")
-			fmt.Printf("Otherwise, this utility will destroy the existing container and recreate it from scratch. Note: in this case, all data and configuration in the container will be lost. \n\n")
-			ynUseExistingContainer, ynUseErr := userinteraction.YesNoPrompt("Do you wish to use this existing container?", false, false, userInputReader, userinteraction.DefaultMaxAttempts)
-			if ynUseErr != nil {
-				return fmt.Errorf("found existing container, but it is not clear whether you wish to use it or recreate it: %v", ynUseErr)
+		return result, fmt.Errorf("unable to check whether the container already exists: %v", err)
+	}
+
+	if containerId == "" {
+		logger.Infof("The container does not yet exist and will be created \n")
+	} else {
+		logger.Infof("Container found: name %v, id %v, running %v \n", orchestrator.containerName, containerId, isContainerRunning)
+		result.ContainerId = containerId
+		isContainerInitialized, initCheckErr := orchestrator.isContainerInitialized(containerId)
+		if initCheckErr != nil {
+			return result, fmt.Errorf("unable to check whether the existing container was initialized: %v", initCheckErr)
+		}
+		if !isContainerInitialized {
+			logger.Infof("The initialization of the existing container %v did not complete successfully, so it will be initialized again. \n", orchestrator.containerName)
+		}
+		if isContainerInitialized {
+			useExistingContainer, decisionErr := decideWhetherToUseExistingContainer(orchestrator.containerName, isContainerRunning, userInputReader, options)
+			if decisionErr != nil {
+				return result, decisionErr
 			}
-			if ynUseExistingContainer {
-				fmt.Printf("You decided to use the existing container. \n")
-				return nil
-			} else {
-				fmt.Println()
-				ynDestroyAndRecreateContainer, ynRecreateErr := userinteraction.YesNoPrompt("You decided to remove and recreate the container. All its data and configuration will be lost. Are you sure you want to proceed?",
-					true, false, userInputReader, userinteraction.DefaultMaxAttempts)
-				if ynRecreateErr != nil {
-					return fmt.Errorf("found existing container. You indicated that you do not wish to use it, but no clear confirmation was given about proceeding to destroy and recreate it: %v", ynRecreateErr)
-				}
-				if ynDestroyAndRecreateContainer {
-					err = orchestrator.removeExistingContainer(containerId)
+			if useExistingContainer {
+				result.ReusedExistingContainer = true
+				if !isContainerRunning {
+					step = orchestrator.reporter.StartStep(containerStartStep)
+					err = orchestrator.startContainer(containerId)
+					step.Done(err, output.Ids{"containerId": containerId})
 					if err != nil {
-						return fmt.Errorf("unable to remove the existing container prior to recreating it: %v", err)
+						return result, fmt.Errorf("unable to start the existing Docker container with id %v due to %v. \n", containerId, err)
 					}
-					containerId = ""
-					isContainerRunning = false
-					fmt.Printf("Container successfully removed \n")
-				} else {
-					fmt.Printf("You decided to use the existing container. \n")
-					return nil
+					logger.Infof("Container successfully started \n")
 				}
+				return result, nil
 			}
+
+			step = orchestrator.reporter.StartStep(containerRemoveStep)
+			err = orchestrator.removeExistingContainer(containerId)
+			step.Done(err, output.Ids{"containerId": containerId})
+			if err != nil {
+				return result, fmt.Errorf("unable to remove the existing container prior to recreating it: %v", err)
+			}
+			containerId = ""
+			result.ContainerId = ""
+			isContainerRunning = false
+			logger.Infof("Container successfully removed \n")
 		}
 	}
 
 	if containerId == "" {
-		containerId, err = orchestrator.createContainer(dockerImageName, dockerContainerName)
+		step = orchestrator.reporter.StartStep(containerCreateStep)
+		containerId, err = orchestrator.createContainer(dockerImageName, containerConfig.Profile)
+		step.Done(err, output.Ids{"containerId": containerId, "imageId": result.ImageId})
 		if err != nil {
-			return fmt.Errorf("unable to create the Docker container: %v. \n", err)
+			return result, fmt.Errorf("unable to create the Docker container: %v. \n", err)
 		}
-		fmt.Printf("Container successfully created with ID %v and name %v \n", containerId, dockerContainerName)
+		result.ContainerId = containerId
+		rollback.createdContainerId = containerId
+		logger.Infof("Container successfully created with ID %v and name %v \n", containerId, orchestrator.containerName)
 	}
 
+	step = orchestrator.reporter.StartStep(containerStartStep)
 	if !isContainerRunning {
-		if err = orchestrator.startContainer(containerId); err != nil {
-			return fmt.Errorf("unable to start the Docker container with id %v due to %v. \n", containerId, err)
+		err = orchestrator.startContainer(containerId)
+		step.Done(err, output.Ids{"containerId": containerId})
+		if err != nil {
+			return result, fmt.Errorf("unable to start the Docker container with id %v due to %v. \n", containerId, err)
+		}
+		logger.Infof("Container successfully started \n")
+	} else {
+		step.Skipped(output.Ids{"containerId": containerId})
+	}
+
+	rollback.containerId = containerId
+	for _, fileToCopy := range filesToCopy(containerConfig) {
+		// recorded before copying, as a cancelled copy may leave a partially extracted file behind. A file that was already in a pre-existing
+		// container, e.g. from its previous initialization, is never removed, and neither is one whose presence could not be checked
+		copiedPathOnContainer := path.Join(fileToCopy.PathOnContainer, filepath.Base(fileToCopy.PathOnHost))
+		if rollback.createdContainerId == "" {
+			exists, statErr := orchestrator.pathExistsInContainer(containerId, copiedPathOnContainer, false)
+			if statErr != nil || exists {
+				rollback.overwrittenPathsOnContainer = append(rollback.overwrittenPathsOnContainer, copiedPathOnContainer)
+			} else {
+				rollback.copiedPathsOnContainer = append(rollback.copiedPathsOnContainer, copiedPathOnContainer)
+			}
+		}
+		step = orchestrator.reporter.StartStep(fileToCopy.step)
+		err = orchestrator.copyFileToContainer(containerId, fileToCopy.PathOnHost, fileToCopy.PathOnContainer)
+		step.Done(err, output.Ids{"containerId": containerId})
+		if err != nil {
+			return result, fmt.Errorf("unable to copy the %v %v to the Docker container %v due to %v. \n", fileToCopy.description, fileToCopy.PathOnHost, orchestrator.containerName, err)
 		}
-		fmt.Printf("Container successfully started \n")
+		result.CopiedFiles = append(result.CopiedFiles, fileToCopy.CopiedFile)
+		logger.Infof("%v %v successfully copied to the Docker container %v \n", fileToCopy.description, fileToCopy.PathOnHost, orchestrator.containerName)
 	}
 
-	sshKeyPathOnHost := containerConfig.Properties[config.SshKeyPathOnHostPropertyName]
-	if err = orchestrator.copyFileToContainer(containerId, sshKeyPathOnHost, sshKeyPathOnContainer); err != nil {
-		return fmt.Errorf("unable to copy the SSH key %v to the Docker container %v due to %v. \n", sshKeyPathOnHost, dockerContainerName, err)
+	if needsSshConfig(containerConfig) {
+		step = orchestrator.reporter.StartStep(sshConfigStep)
+		err = orchestrator.writeSshConfig(containerId, containerConfig)
+		step.Done(err, output.Ids{"containerId": containerId})
+		if err != nil {
+			return result, fmt.Errorf("unable to configure SSH in the Docker container %v due to %v. \n", orchestrator.containerName, err)
+		}
+		logger.Infof("SSH configuration successfully written to %v \n", sshConfigPathOnContainer)
 	}
-	fmt.Printf("SSH key %v successfully copied to the Docker container %v \n", sshKeyPathOnHost, dockerContainerName)
 
-	ansibleInventoryPathOnHost := containerConfig.Properties[config.AnsibleInventoryPathOnHostPropertyName]
-	if err = orchestrator.copyFileToContainer(containerId, ansibleInventoryPathOnHost, ansibleInventoryPathOnContainer); err != nil {
-		return fmt.Errorf("unable to copy the Ansible inventory %v to the Docker container %v due to %v. \n", ansibleInventoryPathOnHost, dockerContainerName, err)
+	step = orchestrator.reporter.StartStep(containerInitStep)
+	err = orchestrator.initializeContainer(containerId, containerConfig)
+	step.Done(err, output.Ids{"containerId": containerId})
+	if err != nil {
+		return result, fmt.Errorf("unable to run the initialization script on the Docker container %v due to %w. \n", orchestrator.containerName, err)
 	}
-	fmt.Printf("Ansible inventory %v successfully copied to the Docker container %v \n", ansibleInventoryPathOnHost, dockerContainerName)
+	logger.Infof("Ansible container %v successfully initialized \n", orchestrator.containerName)
 
-	if err = orchestrator.initializeContainer(containerId, containerConfig); err != nil {
-		return fmt.Errorf("unable to run the initialization script on the Docker container %v due to %v. \n", dockerContainerName, err)
+	// the keys are loaded once the initialization script has copied them to the SSH directory and made them accessible to the user of the container
+	if len(options.SshKeyPassphrases) > 0 {
+		step = orchestrator.reporter.StartStep(sshAgentStep)
+		err = orchestrator.loadSshKeysIntoAgent(containerId, options.SshKeyPassphrases)
+		step.Done(err, output.Ids{"containerId": containerId})
+		if err != nil {
+			return result, fmt.Errorf("unable to load the SSH keys protected by a passphrase into an SSH agent in the Docker container %v due to %v. \n", orchestrator.containerName, err)
+		}
+		logger.Infof("SSH keys protected by a passphrase successfully loaded into the SSH agent listening on %v \n", sshAgentSocketPathOnContainer)
 	}
-	fmt.Printf("Ansible container %v successfully initialized \n", dockerContainerName)
 
-	return nil
+	return result, nil
+}
+
+// creationRollback records the resources created in the current run, so that they can be removed if the run is cancelled
+type creationRollback struct {
+	createdContainerId string
+	containerId        string
+	// files copied into a pre-existing container that were not there before
+	copiedPathsOnContainer []string
+	// files copied into a pre-existing container that replaced files already there, which cannot be restored
+	overwrittenPathsOnContainer []string
+}
+
+// rollbackTarget is the part of the orchestrator used to roll back a cancelled run
+type rollbackTarget interface {
+	removeExistingContainer(containerId string) error
+	runCommandWithoutOutput(containerId string, cmd ...string) error
+}
+
+// rollBack removes the resources created in the current run, see rollBackCreation.
+// The context of the orchestrator has already been cancelled, so a separate one is used
+func (o *DockerOrchestrator) rollBack(rollback *creationRollback) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	rollbackOrchestrator := &DockerOrchestrator{
+		cli: o.cli,
+		ctx: ctx,
+	}
+	rollBackCreation(rollbackOrchestrator, rollback)
+}
+
+// rollBackCreation removes the resources created in the current run. A container created in this run is removed altogether,
+// whereas a pre-existing container is left in place and only the files copied into it in this run that were not there before are removed.
+// The files that replaced existing ones are left in place, as the previous ones cannot be restored, and are reported
+func rollBackCreation(target rollbackTarget, rollback *creationRollback) {
+	logger.Infoln()
+	if rollback.createdContainerId != "" {
+		logger.Infof("Removing the container %v created in this run \n", rollback.createdContainerId)
+		if err := target.removeExistingContainer(rollback.createdContainerId); err != nil {
+			logger.Infof("The container %v could not be removed due to %v. Please remove it with: docker rm -f %v \n", rollback.createdContainerId, err, rollback.createdContainerId)
+			return
+		}
+		logger.Infof("Container successfully removed \n")
+		return
+	}
+
+	if rollback.containerId == "" {
+		return
+	}
+	if len(rollback.overwrittenPathsOnContainer) > 0 {
+		logger.Warnf("The following files were already in the existing container %v and may have been replaced in this run, so they were left in place: %v. "+
+			"Please check them, or run this utility again to complete the initialization \n", rollback.containerId, strings.Join(rollback.overwrittenPathsOnContainer, ", "))
+	}
+	if len(rollback.copiedPathsOnContainer) > 0 {
+		logger.Infof("Removing the files copied in this run to the existing container %v: %v \n", rollback.containerId, strings.Join(rollback.copiedPathsOnContainer, ", "))
+		cmd := append([]string{"rm", "-f"}, rollback.copiedPathsOnContainer...)
+		if err := target.runCommandWithoutOutput(rollback.containerId, cmd...); err != nil {
+			logger.Infof("The copied files could not be removed due to %v \n", err)
+			return
+		}
+		logger.Infof("Copied files successfully removed \n")
+	}
+}
+
+// fileCopy describes a file that is copied from the host to the container before running the initialization script
+type fileCopy struct {
+	CopiedFile
+	step        string
+	description string
+}
+
+func filesToCopy(containerConfig *config.ContainerInitConfig) []fileCopy {
+	files := []fileCopy{
+		{
+			CopiedFile: CopiedFile{
+				PathOnHost:      containerConfig.SshKeyPathOnHost,
+				PathOnContainer: sshKeyPathOnContainer,
+			},
+			step:        copySshKeyStep,
+			description: "SSH key",
+		},
+		{
+			CopiedFile: CopiedFile{
+				PathOnHost:      containerConfig.AnsibleInventoryPathOnHost,
+				PathOnContainer: ansibleInventoryPathOnContainer,
+			},
+			step:        copyInventoryStep,
+			description: "Ansible inventory",
+		},
+	}
+	// additional keys are copied straight to the SSH directory, as the initialization script would map any key in the SSH key directory to the proxies
+	for _, sshKeyPathOnHost := range additionalSshKeysToCopy(containerConfig) {
+		files = append(files, fileCopy{
+			CopiedFile: CopiedFile{
+				PathOnHost:      sshKeyPathOnHost,
+				PathOnContainer: sshDirPathOnContainer,
+			},
+			step:        copyAdditionalSshKeyStep,
+			description: "additional SSH key",
+		})
+	}
+	return files
+}
+
+// buildInitCommand returns the command that runs the initialization script in the container
+func buildInitCommand(containerConfig *config.ContainerInitConfig) []string {
+	ipPrefixArg := fmt.Sprintf("-p %s", initScriptHostPattern(containerConfig))
+	inventoryArg := fmt.Sprintf("-i %s", filepath.Base(containerConfig.AnsibleInventoryPathOnHost))
+	return []string{initScriptPathOnContainer, ipPrefixArg, inventoryArg}
+}
+
+// decideWhetherToUseExistingContainer determines whether an existing and initialized container should be used as it is, after starting it if it is stopped, or recreated.
+// In non-interactive mode the decision is taken from the options, otherwise the user is prompted
+func decideWhetherToUseExistingContainer(containerName string, isContainerRunning bool, userInputReader *bufio.Reader, options ContainerCreationOptions) (bool, error) {
+	containerState, useDescription := "is in running state", "this utility will exit"
+	if !isContainerRunning {
+		containerState, useDescription = "is stopped", "this utility will start it and exit"
+	}
+	if options.NonInteractive {
+		if options.RecreateExistingContainer {
+			logger.Infof("The container %v already exists and will be destroyed and recreated from scratch. \n", containerName)
+			return false, nil
+		}
+		logger.Infof("The container %v already exists and %v. It will be used as it is. \n", containerName, containerState)
+		return true, nil
+	}
+
+	logger.Infoln()
+	logger.Infof("The container %v already exists and %v. \n\n", containerName, containerState)
+	logger.Infof("If you are happy to use the existing container, %v. \n", useDescription)
+	logger.Infof("Otherwise, this utility will destroy the existing container and recreate it from scratch. Note: in this case, all data and configuration in the container will be lost. \n\n")
+	ynUseExistingContainer, ynUseErr := userinteraction.YesNoPrompt("Do you wish to use this existing container?", false, false, userInputReader, userinteraction.DefaultMaxAttempts)
+	if ynUseErr != nil {
+		return false, fmt.Errorf("found existing container, but it is not clear whether you wish to use it or recreate it: %v", ynUseErr)
+	}
+	if ynUseExistingContainer {
+		logger.Infof("You decided to use the existing container. \n")
+		return true, nil
+	}
+
+	logger.Infoln()
+	ynDestroyAndRecreateContainer, ynRecreateErr := userinteraction.YesNoPrompt("You decided to remove and recreate the container. All its data and configuration will be lost. Are you sure you want to proceed?",
+		true, false, userInputReader, userinteraction.DefaultMaxAttempts)
+	if ynRecreateErr != nil {
+		return false, fmt.Errorf("found existing container. You indicated that you do not wish to use it, but no clear confirmation was given about proceeding to destroy and recreate it: %v", ynRecreateErr)
+	}
+	if !ynDestroyAndRecreateContainer {
+		logger.Infof("You decided to use the existing container. \n")
+		return true, nil
+	}
+	return false, nil
 }
 
 // DockerOrchestrator naming:
 // IntelliJ points out that a struct's name should not start with its package name, but we feel that it should be called DockerOrchestrator for clarity
 type DockerOrchestrator struct {
-	cli *client.Client
-	ctx context.Context
+	cli      *client.Client
+	ctx      context.Context
+	reporter *output.Reporter
+	// containerName is the name of the container of the profile this orchestrator manages
+	containerName string
 }
 
-func createDockerOrchestrator() (*DockerOrchestrator, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// createDockerOrchestrator creates an orchestrator of the container of the specified profile, whose Docker API calls are all bound
+// to the specified context, so that they are interrupted when the context is cancelled
+func createDockerOrchestrator(ctx context.Context, profile string) (*DockerOrchestrator, error) {
+	cli, err := newDockerClient()
 	if err != nil {
 		return nil, err
 	}
 
 	return &DockerOrchestrator{
-		cli: cli,
-		ctx: context.Background(),
+		cli:           cli,
+		ctx:           ctx,
+		containerName: ContainerNameForProfile(profile),
 	}, nil
 }
 
@@ -177,7 +500,7 @@ func Retry(functionToBeRetried FunctionToBeRetried, retries int, delay time.Dura
 				return err
 			}
 
-			log.Printf("%s, retrying in %v", outputMessage, delay)
+			logger.Warnf("%s, retrying in %v \n", outputMessage, delay)
 
 			select {
 			case <-time.After(delay):
@@ -188,40 +511,70 @@ func Retry(functionToBeRetried FunctionToBeRetried, retries int, delay time.Dura
 	}
 }
 
-func (o *DockerOrchestrator) pullImageIfNotAlreadyPresent(imageName string) error {
+// pullImageIfNotAlreadyPresent pulls the image unless it is already present locally, and returns its ID
+func (o *DockerOrchestrator) pullImageIfNotAlreadyPresent(imageName string) (string, error) {
+	step := o.reporter.StartStep(imageCheckStep)
+	imageId, err := o.findImage(imageName)
+	step.Done(err, output.Ids{"imageId": imageId})
+	if err != nil {
+		return "", err
+	}
+
+	if imageId != "" {
+		logger.Infoln("Docker image is already present and ready to use, so it will not be pulled")
+		o.reporter.StartStep(imagePullStep).Skipped(output.Ids{"imageId": imageId})
+		return imageId, nil
+	}
+
+	step = o.reporter.StartStep(imagePullStep)
+	imageId, err = o.pullImage(imageName)
+	step.Done(err, output.Ids{"imageId": imageId})
+	return imageId, err
+}
+
+// findImage returns the ID of the image if it is present locally, or an empty string otherwise
+func (o *DockerOrchestrator) findImage(imageName string) (string, error) {
 	imageFilters := filters.NewArgs()
 	imageFilters.Add("reference", imageName)
 	imageSummaries, err := o.cli.ImageList(o.ctx, image.ListOptions{Filters: imageFilters})
-
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if len(imageSummaries) > 0 {
-		fmt.Printf("Found %v images \n", len(imageSummaries))
-		for _, imageSummary := range imageSummaries {
-			fmt.Printf("Found image with name %v and id %v \n", imageSummary.RepoTags, imageSummary.ID)
-		}
-		fmt.Println("Docker image is already present and ready to use, so it will not be pulled")
-		return nil
+	if len(imageSummaries) == 0 {
+		return "", nil
+	}
+	logger.Infof("Found %v images \n", len(imageSummaries))
+	for _, imageSummary := range imageSummaries {
+		logger.Infof("Found image with name %v and id %v \n", imageSummary.RepoTags, imageSummary.ID)
 	}
+	return imageSummaries[0].ID, nil
+}
 
+func (o *DockerOrchestrator) pullImage(imageName string) (string, error) {
 	imageReader, err := o.cli.ImagePull(o.ctx, imageName, image.PullOptions{})
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer CloseImageReader(imageReader)
 
-	_, err = io.Copy(os.Stdout, imageReader)
+	_, err = io.Copy(logger.Writer(logger.InfoLevel), imageReader)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+
+	imageInfo, err := o.cli.ImageInspect(o.ctx, imageName)
+	if err != nil {
+		return "", err
+	}
+	return imageInfo.ID, nil
 }
 
 func (o *DockerOrchestrator) retrieveExistingContainer(containerName string) (string, bool, error) {
+	// the name filter matches any container whose name contains the value as a regular expression,
+	// so it is anchored to avoid also matching the containers of other profiles, whose names start with the same prefix
 	containerFilters := filters.NewArgs()
-	containerFilters.Add("name", containerName)
+	containerFilters.Add("name", "^/"+regexp.QuoteMeta(containerName)+"$")
 	containerListOptions := container.ListOptions{
 		All:     true,
 		Latest:  true,
@@ -234,11 +587,8 @@ func (o *DockerOrchestrator) retrieveExistingContainer(containerName string) (st
 
 	switch len(containers) {
 	case 0:
-		fmt.Printf("The container does not yet exist and will be created \n")
 		return "", false, nil
 	case 1:
-
-		fmt.Printf("Container found: name %v, id %v, status %v \n", containers[0].Names, containers[0].ID, containers[0].State)
 		isRunning := strings.EqualFold(strings.TrimSpace(containers[0].State), "running")
 		return containers[0].ID, isRunning, nil
 	default:
@@ -246,6 +596,29 @@ func (o *DockerOrchestrator) retrieveExistingContainer(containerName string) (st
 	}
 }
 
+// retrieveContainerReadyForUse returns the ID of the container, checking that it is running and that the automation has been cloned into it
+func (o *DockerOrchestrator) retrieveContainerReadyForUse() (string, error) {
+	containerId, isContainerRunning, err := o.retrieveExistingContainer(o.containerName)
+	if err != nil {
+		return "", fmt.Errorf("unable to check whether the container exists: %v", err)
+	}
+	if containerId == "" {
+		return "", fmt.Errorf("the container %v does not exist. Please run this utility to create and initialize it first", o.containerName)
+	}
+	if !isContainerRunning {
+		return "", fmt.Errorf("the container %v is not running. Please start it with: docker start %v", o.containerName, o.containerName)
+	}
+
+	isAutomationCloned, err := o.pathExistsInContainer(containerId, ansibleDirPathOnContainer, true)
+	if err != nil {
+		return "", fmt.Errorf("unable to check whether the container was initialized: %v", err)
+	}
+	if !isAutomationCloned {
+		return "", fmt.Errorf("the directory %v does not exist in the container %v. Please run this utility to initialize the container first", ansibleDirPathOnContainer, o.containerName)
+	}
+	return containerId, nil
+}
+
 func (o *DockerOrchestrator) removeExistingContainer(containerId string) error {
 	containerRemoveOptions := container.RemoveOptions{
 		Force: true,
@@ -253,16 +626,18 @@ func (o *DockerOrchestrator) removeExistingContainer(containerId string) error {
 	return o.cli.ContainerRemove(o.ctx, containerId, containerRemoveOptions)
 }
 
-func (o *DockerOrchestrator) createContainer(imageName, containerName string) (string, error) {
+// createContainer creates the container of the profile managed by this orchestrator, labelled with the profile name
+func (o *DockerOrchestrator) createContainer(imageName, profile string) (string, error) {
 	containerCreationResponse, err := o.cli.ContainerCreate(o.ctx,
 		&container.Config{
-			Image: imageName,
-			Tty:   true,
+			Image:  imageName,
+			Tty:    true,
+			Labels: map[string]string{profileLabel: profile, initializationMarkerLabel: "true"},
 		}, &container.HostConfig{
 			RestartPolicy: container.RestartPolicy{
-				Name: "unless-stopped",
+				Name: containerRestartPolicy,
 			},
-		}, nil, nil, containerName)
+		}, nil, nil, o.containerName)
 	if err != nil {
 		return "", err
 	}
@@ -353,33 +728,113 @@ func (o *DockerOrchestrator) copyFileToContainer(containerId, srcPath, dstPath s
 	return o.cli.CopyToContainer(o.ctx, containerId, resolvedDstPath, content, options)
 }
 
+// initializeContainer runs the initialization script in the container and waits for it to complete.
+// The marker file is removed before running the script and only recreated if the script succeeds, so that a container
+// whose initialization failed is initialized again on the next run instead of being offered for reuse.
+// A marker file is used instead of a label because labels cannot be changed once the container has been created.
 func (o *DockerOrchestrator) initializeContainer(containerId string, containerConfig *config.ContainerInitConfig) error {
 
-	ipPrefixArg := fmt.Sprintf("-p %s", containerConfig.Properties[config.ProxyIpAddressPrefixPropertyName])
-	inventoryArg := fmt.Sprintf("-i %s", filepath.Base(containerConfig.Properties[config.AnsibleInventoryPathOnHostPropertyName]))
+	if _, err := o.cli.ContainerInspect(o.ctx, containerId); err != nil {
+		return err
+	}
 
-	execConfig := &container.ExecOptions{
-		User:         "ubuntu",
-		Privileged:   false,
-		Tty:          true,
-		Cmd:          []string{"/home/ubuntu/init_container_internal.sh", ipPrefixArg, inventoryArg},
-		WorkingDir:   "/home/ubuntu",
-		AttachStdout: true,
-		AttachStderr: true,
+	if err := o.runCommandWithoutOutput(containerId, "rm", "-f", initializedMarkerPathOnContainer); err != nil {
+		return fmt.Errorf("unable to reset the initialization marker: %v", err)
 	}
 
-	if _, err := o.cli.ContainerInspect(o.ctx, containerId); err != nil {
+	outputTail := newTailWriter(initOutputTailMaxLines)
+	exitCode, err := o.execInContainer(containerId, buildInitCommand(containerConfig), containerHomeDir, io.MultiWriter(logger.Writer(logger.InfoLevel), outputTail))
+	if err != nil {
 		return err
 	}
+	if exitCode != 0 {
+		return &ContainerInitializationError{
+			ExitCode:   exitCode,
+			OutputTail: outputTail.String(),
+		}
+	}
+
+	if err = o.runCommandWithoutOutput(containerId, "touch", initializedMarkerPathOnContainer); err != nil {
+		return fmt.Errorf("the initialization script succeeded but the initialization marker could not be created: %v", err)
+	}
+	return nil
+}
+
+// isContainerInitialized checks whether the initialization script completed successfully in the container, see wasInitializedWithoutMarker
+func (o *DockerOrchestrator) isContainerInitialized(containerId string) (bool, error) {
+	isMarkerPresent, err := o.pathExistsInContainer(containerId, initializedMarkerPathOnContainer, false)
+	if err != nil || isMarkerPresent {
+		return isMarkerPresent, err
+	}
+	containerInfo, err := o.cli.ContainerInspect(o.ctx, containerId)
+	if err != nil {
+		return false, err
+	}
+	var labels map[string]string
+	if containerInfo.Config != nil {
+		labels = containerInfo.Config.Labels
+	}
+	isAutomationCloned, err := o.pathExistsInContainer(containerId, automationRepoPathOnContainer, true)
+	if err != nil {
+		return false, err
+	}
+	return wasInitializedWithoutMarker(labels, isAutomationCloned), nil
+}
+
+// wasInitializedWithoutMarker returns whether a container without initialization marker was initialized by an earlier version of this utility,
+// which did not create the marker. The initialization script clones the automation repository towards its end, so a container of an earlier
+// version where it has been cloned is considered initialized, rather than being initialized again. A container with the label always has
+// the marker once initialized
+func wasInitializedWithoutMarker(labels map[string]string, isAutomationCloned bool) bool {
+	if _, found := labels[initializationMarkerLabel]; found {
+		return false
+	}
+	return isAutomationCloned
+}
 
-	response, err := o.cli.ContainerExecCreate(o.ctx, containerId, *execConfig)
+func (o *DockerOrchestrator) runCommandWithoutOutput(containerId string, cmd ...string) error {
+	return o.runCommandWithEnvWithoutOutput(containerId, nil, cmd...)
+}
+
+// runCommandWithEnvWithoutOutput is like runCommandWithoutOutput, setting the environment variables in the form NAME=value for the command
+func (o *DockerOrchestrator) runCommandWithEnvWithoutOutput(containerId string, env []string, cmd ...string) error {
+	exitCode, err := o.execInContainerWithEnv(containerId, cmd, env, containerHomeDir, io.Discard)
 	if err != nil {
 		return err
 	}
+	if exitCode != 0 {
+		return fmt.Errorf("command %v exited with code %d", cmd, exitCode)
+	}
+	return nil
+}
+
+// execInContainer runs the command in the container as user ubuntu, copies its output to the specified writer
+// and returns the exit code of the command once it has completed
+func (o *DockerOrchestrator) execInContainer(containerId string, cmd []string, workingDir string, output io.Writer) (int, error) {
+	return o.execInContainerWithEnv(containerId, cmd, nil, workingDir, output)
+}
+
+// execInContainerWithEnv is like execInContainer, setting the environment variables in the form NAME=value for the command
+func (o *DockerOrchestrator) execInContainerWithEnv(containerId string, cmd []string, env []string, workingDir string, output io.Writer) (int, error) {
+	execConfig := container.ExecOptions{
+		User:         containerUser,
+		Privileged:   false,
+		Tty:          true,
+		Cmd:          cmd,
+		Env:          env,
+		WorkingDir:   workingDir,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	response, err := o.cli.ContainerExecCreate(o.ctx, containerId, execConfig)
+	if err != nil {
+		return -1, err
+	}
 
 	execID := response.ID
 	if execID == "" {
-		return errors.New("exec ID empty")
+		return -1, errors.New("exec ID empty")
 	}
 
 	execStartCheck := container.ExecAttachOptions{
@@ -387,22 +842,49 @@ func (o *DockerOrchestrator) initializeContainer(containerId string, containerCo
 	}
 	resp, err := o.cli.ContainerExecAttach(o.ctx, execID, execStartCheck)
 	if err != nil {
-		return err
+		return -1, err
 	}
 	defer resp.Close()
 
-	_, err = io.Copy(os.Stdout, resp.Reader)
+	// the attached stream is not bound to the context, so it is closed explicitly to stop waiting for output on cancellation
+	stopClosingOnCancel := context.AfterFunc(o.ctx, resp.Close)
+	defer stopClosingOnCancel()
+
+	_, err = io.Copy(output, resp.Reader)
 	if err != nil {
-		return err
+		if o.ctx.Err() != nil {
+			return -1, o.ctx.Err()
+		}
+		return -1, err
+	}
+
+	return o.waitForExecExitCode(execID)
+}
+
+// waitForExecExitCode polls the exec until it is no longer running. The output stream can be closed slightly before
+// the exec is reported as completed
+func (o *DockerOrchestrator) waitForExecExitCode(execID string) (int, error) {
+	for {
+		execInspect, err := o.cli.ContainerExecInspect(o.ctx, execID)
+		if err != nil {
+			return -1, err
+		}
+		if !execInspect.Running {
+			return execInspect.ExitCode, nil
+		}
+		select {
+		case <-time.After(execInspectPollInterval):
+		case <-o.ctx.Done():
+			return -1, o.ctx.Err()
+		}
 	}
-	return nil
 }
 
 func (o *DockerOrchestrator) CloseDockerClient() {
 	if o.cli != nil {
 		err := o.cli.Close()
 		if err != nil {
-			fmt.Printf("Error closing Docker client: %v \n", err)
+			logger.Warnf("Error closing Docker client: %v \n", err)
 		}
 	}
 }
@@ -411,7 +893,7 @@ func CloseImageReader(reader io.ReadCloser) {
 	if reader != nil {
 		err := reader.Close()
 		if err != nil {
-			fmt.Printf("Error closing Docker image reader: %v \n", err)
+			logger.Warnf("Error closing Docker image reader: %v \n", err)
 		}
 	}
 }
@@ -420,7 +902,7 @@ func CloseReadCloser(rc io.ReadCloser) {
 	if rc != nil {
 		err := rc.Close()
 		if err != nil {
-			fmt.Printf("Error closing Docker image reader: %v \n", err)
+			logger.Warnf("Error closing Docker image reader: %v \n", err)
 		}
 	}
 }
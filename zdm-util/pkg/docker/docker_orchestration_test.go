@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestContainerNameForProfile(t *testing.T) {
+	tests := []struct {
+		name         string
+		profile      string
+		expectedName string
+	}{
+		{
+			name:         "default profile",
+			profile:      "",
+			expectedName: "zdm-ansible-container",
+		},
+		{
+			name:         "named profile",
+			profile:      "prod-eu",
+			expectedName: "zdm-ansible-container-prod-eu",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedName, ContainerNameForProfile(tt.profile))
+		})
+	}
+}
+
+// fakeRollbackTarget records the operations of a rollback instead of performing them on a container
+type fakeRollbackTarget struct {
+	removedContainerIds []string
+	commands            [][]string
+	err                 error
+}
+
+func (f *fakeRollbackTarget) removeExistingContainer(containerId string) error {
+	f.removedContainerIds = append(f.removedContainerIds, containerId)
+	return f.err
+}
+
+func (f *fakeRollbackTarget) runCommandWithoutOutput(containerId string, cmd ...string) error {
+	f.commands = append(f.commands, append([]string{containerId}, cmd...))
+	return f.err
+}
+
+func TestRollBackCreation(t *testing.T) {
+	tests := []struct {
+		name                        string
+		rollback                    *creationRollback
+		expectedRemovedContainerIds []string
+		expectedCommands            [][]string
+	}{
+		{
+			name:                        "container created in this run is removed",
+			rollback:                    &creationRollback{createdContainerId: "c1", containerId: "c1"},
+			expectedRemovedContainerIds: []string{"c1"},
+		},
+		{
+			name: "only new files are removed from a pre-existing container",
+			rollback: &creationRollback{
+				containerId:                 "c1",
+				copiedPathsOnContainer:      []string{"/home/ubuntu/zdm_ansible_inventory"},
+				overwrittenPathsOnContainer: []string{"/home/ubuntu/zdm-proxy-ssh-key-dir/proxy_key"},
+			},
+			expectedCommands: [][]string{{"c1", "rm", "-f", "/home/ubuntu/zdm_ansible_inventory"}},
+		},
+		{
+			name: "files already in a pre-existing container are left in place",
+			rollback: &creationRollback{
+				containerId:                 "c1",
+				overwrittenPathsOnContainer: []string{"/home/ubuntu/zdm-proxy-ssh-key-dir/proxy_key", "/home/ubuntu/zdm_ansible_inventory"},
+			},
+		},
+		{
+			name:     "nothing to roll back before the container is found or created",
+			rollback: &creationRollback{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeRollbackTarget{}
+			rollBackCreation(target, tt.rollback)
+			require.Equal(t, tt.expectedRemovedContainerIds, target.removedContainerIds)
+			require.Equal(t, tt.expectedCommands, target.commands)
+		})
+	}
+}
+
+func TestRollBackCreation_Failure(t *testing.T) {
+	target := &fakeRollbackTarget{err: errors.New("connection refused")}
+	rollBackCreation(target, &creationRollback{createdContainerId: "c1", containerId: "c1", copiedPathsOnContainer: []string{"/home/ubuntu/zdm_ansible_inventory"}})
+	require.Equal(t, []string{"c1"}, target.removedContainerIds)
+	require.Nil(t, target.commands)
+}
+
+func TestWasInitializedWithoutMarker(t *testing.T) {
+	tests := []struct {
+		name                string
+		labels              map[string]string
+		isAutomationCloned  bool
+		expectedInitialized bool
+	}{
+		{
+			name:                "container of an earlier version with the automation cloned",
+			labels:              nil,
+			isAutomationCloned:  true,
+			expectedInitialized: true,
+		},
+		{
+			name:                "container of an earlier version with a profile and the automation not cloned",
+			labels:              map[string]string{profileLabel: ""},
+			isAutomationCloned:  false,
+			expectedInitialized: false,
+		},
+		{
+			name:                "container recording its initialization with a marker",
+			labels:              map[string]string{profileLabel: "", initializationMarkerLabel: "true"},
+			isAutomationCloned:  true,
+			expectedInitialized: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedInitialized, wasInitializedWithoutMarker(tt.labels, tt.isAutomationCloned))
+		})
+	}
+}
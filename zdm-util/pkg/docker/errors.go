@@ -0,0 +1,64 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ContainerInitializationError is returned when the initialization script exits with a non-zero code
+type ContainerInitializationError struct {
+	ExitCode   int
+	OutputTail string
+}
+
+func (e *ContainerInitializationError) Error() string {
+	return fmt.Sprintf("the initialization script exited with code %d. Last lines of its output: \n%s", e.ExitCode, e.OutputTail)
+}
+
+// tailWriter retains the last lines written to it, so that they can be reported if a command fails
+type tailWriter struct {
+	lock     sync.Mutex
+	maxLines int
+	lines    []string
+	partial  string
+}
+
+func newTailWriter(maxLines int) *tailWriter {
+	return &tailWriter{
+		maxLines: maxLines,
+		lines:    make([]string, 0, maxLines),
+	}
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// the exec runs with a TTY, so lines may be terminated by \r\n, which may be split across writes.
+	// The partial line is kept as written, so that its trailing \r is replaced along with the \n that follows it
+	text := strings.ReplaceAll(w.partial+string(p), "\r\n", "\n")
+	newLines := strings.Split(text, "\n")
+	w.partial = newLines[len(newLines)-1]
+	for _, line := range newLines[:len(newLines)-1] {
+		w.lines = append(w.lines, line)
+		if len(w.lines) > w.maxLines {
+			w.lines = w.lines[1:]
+		}
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	lines := w.lines
+	if partial := strings.TrimSuffix(w.partial, "\r"); partial != "" {
+		lines = append(lines, partial)
+		if len(lines) > w.maxLines {
+			lines = lines[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTailWriter(t *testing.T) {
+	tests := []struct {
+		name         string
+		writes       []string
+		maxLines     int
+		expectedTail string
+	}{
+		{
+			name:         "fewer lines than the maximum",
+			writes:       []string{"line 1\nline 2\n"},
+			maxLines:     3,
+			expectedTail: "line 1\nline 2",
+		},
+		{
+			name:         "more lines than the maximum",
+			writes:       []string{"line 1\nline 2\n", "line 3\nline 4\n"},
+			maxLines:     2,
+			expectedTail: "line 3\nline 4",
+		},
+		{
+			name:         "lines split across writes, with carriage returns and no trailing newline",
+			writes:       []string{"line 1\r\nli", "ne 2\r\nline 3"},
+			maxLines:     2,
+			expectedTail: "line 2\nline 3",
+		},
+		{
+			name:         "carriage return and newline split across writes",
+			writes:       []string{"line 1\r", "\nline 2\r", "\n"},
+			maxLines:     2,
+			expectedTail: "line 1\nline 2",
+		},
+		{
+			name:         "last line ending with a carriage return",
+			writes:       []string{"line 1\r\nline 2\r"},
+			maxLines:     2,
+			expectedTail: "line 1\nline 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newTailWriter(tt.maxLines)
+			for _, s := range tt.writes {
+				_, err := w.Write([]byte(s))
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.expectedTail, w.String())
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+)
+
+const (
+	// jumphostAlias is the name the proxies refer to in their ProxyJump entry, as in the SSH configuration generated by the Terraform automation
+	jumphostAlias = "jumphost"
+	jumphostPort  = 22
+
+	proxyAliasPrefix = "zdm-proxy-"
+	monitoringAlias  = "zdm-monitoring"
+)
+
+// usesJumphost returns whether the proxies can only be reached through a jumphost, in which case the SSH configuration of the container
+// is generated from the Ansible inventory, see buildJumphostSshConfig
+func usesJumphost(containerConfig *config.ContainerInitConfig) bool {
+	return containerConfig.JumphostPublicIpAddress != ""
+}
+
+// buildJumphostSshConfig returns the SSH configuration to reach the hosts of the Ansible inventory through the jumphost. It is equivalent to
+// the configuration generated by the Terraform automation, see zdm_ssh_config_example: an entry for the jumphost, an entry for each proxy
+// and for the monitoring host that jumps through it, and defaults for all hosts that use the main SSH key and the remote user.
+// A host of the inventory that is the jumphost itself, e.g. the monitoring host in the Terraform automation, is reached directly
+// through the entry of the jumphost, which also matches its alias, rather than by jumping back to its own public address.
+// The user and port of a host are those of the inventory, if set, so that they match the ones Ansible connects with
+func buildJumphostSshConfig(containerConfig *config.ContainerInitConfig) (string, error) {
+	ansibleInventory, err := inventory.ParseFile(containerConfig.AnsibleInventoryPathOnHost)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the hosts of the Ansible inventory %v: %v", containerConfig.AnsibleInventoryPathOnHost, err)
+	}
+	isJumphost := func(host *inventory.Host) bool {
+		return containerConfig.JumphostPrivateIpAddress != "" &&
+			config.CanonicalHostAddress(host.Address()) == config.CanonicalHostAddress(containerConfig.JumphostPrivateIpAddress)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# jumphost\n")
+	jumphostPatterns := []string{jumphostAlias}
+	if containerConfig.JumphostPrivateIpAddress != "" {
+		jumphostPatterns = []string{containerConfig.JumphostPrivateIpAddress, jumphostAlias}
+	}
+	for i, host := range ansibleInventory.HostsOf(inventory.ProxiesGroupName) {
+		if isJumphost(host) {
+			jumphostPatterns = append(jumphostPatterns, fmt.Sprintf("%s%d", proxyAliasPrefix, i))
+		}
+	}
+	for _, host := range ansibleInventory.HostsOf(inventory.MonitoringGroupName) {
+		if isJumphost(host) {
+			jumphostPatterns = append(jumphostPatterns, monitoringAlias)
+		}
+	}
+	// the jumphost is often also a host of the inventory, e.g. the monitoring host in the Terraform automation, and has the same user and port
+	port := fmt.Sprint(jumphostPort)
+	if inventoryPort, found := inventoryHostVar(ansibleInventory, containerConfig.JumphostPrivateIpAddress, inventory.AnsiblePortVarName); found {
+		port = inventoryPort
+	}
+	sb.WriteString(fmt.Sprintf("Host %s\n  Hostname %s\n  Port %s\n", strings.Join(jumphostPatterns, " "), containerConfig.JumphostPublicIpAddress, port))
+	remoteUser := containerConfig.RemoteUserOrDefault()
+	if user, found := inventoryHostVar(ansibleInventory, containerConfig.JumphostPrivateIpAddress, inventory.AnsibleUserVarName); found && user != remoteUser {
+		sb.WriteString(fmt.Sprintf("  User %s\n", user))
+	}
+
+	sb.WriteString("# proxy instances\n")
+	for i, host := range ansibleInventory.HostsOf(inventory.ProxiesGroupName) {
+		if !isJumphost(host) {
+			writeJumphostSshConfigEntry(&sb, host, fmt.Sprintf("%s%d", proxyAliasPrefix, i), remoteUser)
+		}
+	}
+	for _, host := range ansibleInventory.HostsOf(inventory.MonitoringGroupName) {
+		if !isJumphost(host) {
+			sb.WriteString("# monitoring instance\n")
+			writeJumphostSshConfigEntry(&sb, host, monitoringAlias, remoteUser)
+		}
+	}
+
+	sb.WriteString("# defaults for all hosts\n")
+	sb.WriteString(fmt.Sprintf("Host *\n"+
+		"  User %s\n"+
+		"  IdentityFile %s\n"+
+		"  IdentitiesOnly yes\n"+
+		"  StrictHostKeyChecking no\n"+
+		"  GlobalKnownHostsFile /dev/null\n"+
+		"  UserKnownHostsFile /dev/null\n",
+		remoteUser, path.Join(sshDirPathOnContainer, filepath.Base(containerConfig.SshKeyPathOnHost))))
+	return sb.String(), nil
+}
+
+// writeJumphostSshConfigEntry writes the entry of a host of the inventory, which matches both the address Ansible connects to and the alias.
+// The user is only written if it differs from the remote user of the defaults for all hosts
+func writeJumphostSshConfigEntry(sb *strings.Builder, host *inventory.Host, alias string, remoteUser string) {
+	sb.WriteString(fmt.Sprintf("Host %s %s\n  Hostname %s\n", host.Address(), alias, host.Address()))
+	if user, found := host.Vars[inventory.AnsibleUserVarName]; found && user != remoteUser {
+		sb.WriteString(fmt.Sprintf("  User %s\n", user))
+	}
+	if port, found := host.Vars[inventory.AnsiblePortVarName]; found {
+		sb.WriteString(fmt.Sprintf("  Port %s\n", port))
+	}
+	sb.WriteString(fmt.Sprintf("  ProxyJump %s\n", jumphostAlias))
+}
+
+// inventoryHostVar returns the value of the variable set in the inventory for the host with the specified address, if any
+func inventoryHostVar(ansibleInventory *inventory.Inventory, address string, varName string) (string, bool) {
+	if address == "" {
+		return "", false
+	}
+	for _, group := range ansibleInventory.Groups {
+		for _, host := range group.Hosts {
+			if value, found := host.Vars[varName]; found && config.CanonicalHostAddress(host.Address()) == config.CanonicalHostAddress(address) {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
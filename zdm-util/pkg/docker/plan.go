@@ -0,0 +1,151 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// PlannedAction is an action that CreateAndInitializeContainer would perform
+type PlannedAction struct {
+	Step        string `json:"step"`
+	Description string `json:"description"`
+}
+
+// ContainerCreationPlan lists, in order, the actions that CreateAndInitializeContainer would perform with the same configuration and options.
+// Problems lists the reasons why the plan could not succeed
+type ContainerCreationPlan struct {
+	Actions  []PlannedAction `json:"actions"`
+	Problems []string        `json:"problems,omitempty"`
+}
+
+func (p *ContainerCreationPlan) addAction(step string, format string, args ...interface{}) {
+	p.Actions = append(p.Actions, PlannedAction{Step: step, Description: fmt.Sprintf(format, args...)})
+}
+
+func (p *ContainerCreationPlan) addProblem(format string, args ...interface{}) {
+	p.Problems = append(p.Problems, fmt.Sprintf(format, args...))
+}
+
+func (p *ContainerCreationPlan) CanSucceed() bool {
+	return len(p.Problems) == 0
+}
+
+func (p *ContainerCreationPlan) PrintPlan() {
+	logger.Infof("Planned actions: \n")
+	for i, action := range p.Actions {
+		logger.Infof(" %d. %s \n", i+1, action.Description)
+	}
+	if !p.CanSucceed() {
+		logger.Infoln()
+		logger.Infof("This plan cannot succeed due to the following problems: \n")
+		for _, problem := range p.Problems {
+			logger.Infof(" - %s \n", problem)
+		}
+	}
+}
+
+// PlanContainerCreation works out what CreateAndInitializeContainer would do, only performing read-only operations against the Docker server.
+// As no prompt is displayed, the decision about an existing container is taken from the options as in non-interactive mode
+func PlanContainerCreation(ctx context.Context, containerConfig *config.ContainerInitConfig, options ContainerCreationOptions) (*ContainerCreationPlan, error) {
+
+	plan := &ContainerCreationPlan{
+		Actions: make([]PlannedAction, 0),
+	}
+
+	orchestrator, err := createDockerOrchestrator(ctx, containerConfig.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a Docker client: %v", err)
+	}
+	defer orchestrator.CloseDockerClient()
+
+	if err = orchestrator.pingServer(); err != nil {
+		return nil, fmt.Errorf("unable to contact the Docker server due to: %v", err)
+	}
+
+	hostFileWrites := make(map[string]bool, len(options.HostFileWrites))
+	for _, hostFileWrite := range options.HostFileWrites {
+		plan.addAction(hostFileWriteStep, "%v", hostFileWrite.Description)
+		hostFileWrites[hostFileWrite.Path] = true
+	}
+
+	imageId, err := orchestrator.findImage(dockerImageName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check the docker image: %v", err)
+	}
+	if imageId != "" {
+		plan.addAction(imageCheckStep, "Use image %v already present with ID %v", dockerImageName, imageId)
+	} else {
+		plan.addAction(imagePullStep, "Pull image %v", dockerImageName)
+		if _, distributionErr := orchestrator.cli.DistributionInspect(orchestrator.ctx, dockerImageName, ""); distributionErr != nil {
+			plan.addProblem("image %v is not present and cannot be pulled: %v", dockerImageName, distributionErr)
+		}
+	}
+
+	containerId, isContainerRunning, err := orchestrator.retrieveExistingContainer(orchestrator.containerName)
+	if err != nil {
+		plan.addProblem("unable to check whether the container already exists: %v", err)
+		return plan, nil
+	}
+
+	if containerId != "" {
+		isContainerInitialized, initCheckErr := orchestrator.isContainerInitialized(containerId)
+		if initCheckErr != nil {
+			plan.addProblem("unable to check whether the existing container was initialized: %v", initCheckErr)
+			return plan, nil
+		}
+		if isContainerInitialized {
+			if !options.RecreateExistingContainer {
+				if isContainerRunning {
+					plan.addAction(containerLookupStep, "Use existing container %v with ID %v as it is, without any further action", orchestrator.containerName, containerId)
+				} else {
+					plan.addAction(containerStartStep, "Start existing container %v with ID %v and use it as it is, without any further action", orchestrator.containerName, containerId)
+				}
+				return plan, nil
+			}
+			plan.addAction(containerRemoveStep, "Remove existing container %v with ID %v, losing all its data and configuration", orchestrator.containerName, containerId)
+			containerId = ""
+			isContainerRunning = false
+		} else {
+			plan.addAction(containerLookupStep, "Reuse existing container %v with ID %v, whose initialization did not complete", orchestrator.containerName, containerId)
+		}
+	}
+
+	if containerId == "" {
+		plan.addAction(containerCreateStep, "Create container %v from image %v with restart policy %v", orchestrator.containerName, dockerImageName, containerRestartPolicy)
+	}
+	if !isContainerRunning {
+		plan.addAction(containerStartStep, "Start container %v", orchestrator.containerName)
+	}
+
+	for _, fileToCopy := range filesToCopy(containerConfig) {
+		plan.addAction(fileToCopy.step, "Copy %v %v to %v in the container", fileToCopy.description, fileToCopy.PathOnHost, fileToCopy.PathOnContainer)
+		if !hostFileWrites[fileToCopy.PathOnHost] && !config.ValidateFilePath(fileToCopy.PathOnHost) {
+			plan.addProblem("%v %v is not a readable file", fileToCopy.description, fileToCopy.PathOnHost)
+		}
+	}
+
+	if needsSshConfig(containerConfig) {
+		if sshConfig, err := buildSshConfig(containerConfig); err != nil {
+			plan.addProblem("the SSH configuration cannot be built: %v", err)
+		} else {
+			plan.addAction(sshConfigStep, "Write %v with the following content: \n%v", sshConfigPathOnContainer, sshConfig)
+		}
+	}
+
+	plan.addAction(containerInitStep, "Run %v as user %v in %v", strings.Join(buildInitCommand(containerConfig), " "), containerUser, containerHomeDir)
+
+	if len(options.SshKeyPassphrases) > 0 {
+		keyPaths := make([]string, 0, len(options.SshKeyPassphrases))
+		for _, key := range sshKeysToLoad(options.SshKeyPassphrases) {
+			keyPaths = append(keyPaths, key.pathOnContainer)
+		}
+		plan.addAction(sshAgentStep, "Start an SSH agent listening on %v and load the SSH keys protected by a passphrase: %v",
+			sshAgentSocketPathOnContainer, strings.Join(keyPaths, ", "))
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,192 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/testutils"
+)
+
+const fakeDockerApiVersion = "1.44"
+
+// fakeDockerApi answers the read-only Docker API calls made about the image and the container of a profile.
+// The container exists if containerId is set, and pathsInContainer maps the paths that exist in it to whether they are directories
+type fakeDockerApi struct {
+	imageId          string
+	canPullImage     bool
+	containerId      string
+	containerState   string
+	containerLabels  map[string]string
+	pathsInContainer map[string]bool
+}
+
+// start serves the API and points the Docker client created by the code under test to it
+func (f *fakeDockerApi) start(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(server.Close)
+	t.Setenv("DOCKER_HOST", "tcp://"+server.Listener.Addr().String())
+	t.Setenv("DOCKER_API_VERSION", fakeDockerApiVersion)
+	t.Setenv("DOCKER_TLS_VERIFY", "")
+	t.Setenv("DOCKER_CERT_PATH", "")
+}
+
+func (f *fakeDockerApi) handle(w http.ResponseWriter, r *http.Request) {
+	apiPath := strings.TrimPrefix(r.URL.Path, "/v"+fakeDockerApiVersion)
+	switch {
+	case apiPath == "/_ping":
+		w.Header().Set("Api-Version", fakeDockerApiVersion)
+		w.WriteHeader(http.StatusOK)
+	case apiPath == "/images/json":
+		images := make([]map[string]any, 0)
+		if f.imageId != "" {
+			images = append(images, map[string]any{"Id": f.imageId, "RepoTags": []string{dockerImageName}})
+		}
+		writeJson(w, http.StatusOK, images)
+	case strings.HasPrefix(apiPath, "/distribution/"):
+		if f.canPullImage {
+			writeJson(w, http.StatusOK, map[string]any{})
+		} else {
+			writeJson(w, http.StatusUnauthorized, map[string]any{"message": "pull access denied"})
+		}
+	case apiPath == "/containers/json":
+		containers := make([]map[string]any, 0)
+		if f.containerId != "" {
+			containers = append(containers, map[string]any{"Id": f.containerId, "State": f.containerState})
+		}
+		writeJson(w, http.StatusOK, containers)
+	case f.containerId != "" && apiPath == "/containers/"+f.containerId+"/json":
+		writeJson(w, http.StatusOK, map[string]any{
+			"Id":     f.containerId,
+			"State":  map[string]any{"Status": f.containerState},
+			"Config": map[string]any{"Image": dockerImageName, "Labels": f.containerLabels},
+		})
+	case f.containerId != "" && apiPath == "/containers/"+f.containerId+"/archive" && r.Method == http.MethodHead:
+		containerPath := r.URL.Query().Get("path")
+		isDir, found := f.pathsInContainer[containerPath]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		pathStat := container.PathStat{Name: path.Base(containerPath), Mode: 0644}
+		if isDir {
+			pathStat.Mode = os.ModeDir | 0755
+		}
+		encodedStat, _ := json.Marshal(pathStat)
+		w.Header().Set("X-Docker-Container-Path-Stat", base64.StdEncoding.EncodeToString(encodedStat))
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeJson(w, http.StatusNotFound, map[string]any{"message": "not found: " + r.URL.Path})
+	}
+}
+
+func writeJson(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func TestPlanContainerCreation(t *testing.T) {
+	initializedContainerPaths := map[string]bool{initializedMarkerPathOnContainer: false, automationRepoPathOnContainer: true}
+	tests := []struct {
+		name              string
+		dockerApi         *fakeDockerApi
+		options           ContainerCreationOptions
+		sshKeyPathOnHost  string
+		expectedSteps     []string
+		isSuccessExpected bool
+	}{
+		{
+			name:              "no image and no container",
+			dockerApi:         &fakeDockerApi{canPullImage: true},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imagePullStep, containerCreateStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: true,
+		},
+		{
+			name:              "image that cannot be pulled",
+			dockerApi:         &fakeDockerApi{},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imagePullStep, containerCreateStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: false,
+		},
+		{
+			name:              "ssh key that is not readable",
+			dockerApi:         &fakeDockerApi{imageId: "sha256:1234"},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/missing_ssh_key",
+			expectedSteps:     []string{imageCheckStep, containerCreateStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: false,
+		},
+		{
+			name:      "files written on the host before the container is created",
+			dockerApi: &fakeDockerApi{imageId: "sha256:1234"},
+			options: ContainerCreationOptions{HostFileWrites: []HostFileWrite{{
+				Path:        testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/missing_ssh_key"),
+				Description: "Write the SSH key",
+			}}},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/missing_ssh_key",
+			expectedSteps:     []string{hostFileWriteStep, imageCheckStep, containerCreateStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: true,
+		},
+		{
+			name: "running and initialized container is used as it is",
+			dockerApi: &fakeDockerApi{imageId: "sha256:1234", containerId: "abcd", containerState: "running",
+				containerLabels: map[string]string{initializationMarkerLabel: "true"}, pathsInContainer: initializedContainerPaths},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imageCheckStep, containerLookupStep},
+			isSuccessExpected: true,
+		},
+		{
+			name: "stopped and initialized container is started and used as it is",
+			dockerApi: &fakeDockerApi{imageId: "sha256:1234", containerId: "abcd", containerState: "exited",
+				containerLabels: map[string]string{initializationMarkerLabel: "true"}, pathsInContainer: initializedContainerPaths},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imageCheckStep, containerStartStep},
+			isSuccessExpected: true,
+		},
+		{
+			name: "running and initialized container is recreated",
+			dockerApi: &fakeDockerApi{imageId: "sha256:1234", containerId: "abcd", containerState: "running",
+				containerLabels: map[string]string{initializationMarkerLabel: "true"}, pathsInContainer: initializedContainerPaths},
+			options:           ContainerCreationOptions{RecreateExistingContainer: true},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imageCheckStep, containerRemoveStep, containerCreateStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: true,
+		},
+		{
+			name: "stopped container whose initialization did not complete is reused",
+			dockerApi: &fakeDockerApi{imageId: "sha256:1234", containerId: "abcd", containerState: "exited",
+				containerLabels: map[string]string{initializationMarkerLabel: "true"}},
+			sshKeyPathOnHost:  "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			expectedSteps:     []string{imageCheckStep, containerLookupStep, containerStartStep, copySshKeyStep, copyInventoryStep, containerInitStep},
+			isSuccessExpected: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.dockerApi.start(t)
+			containerConfig := &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests(tt.sshKeyPathOnHost),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			}
+
+			plan, err := PlanContainerCreation(context.Background(), containerConfig, tt.options)
+			require.Nil(t, err)
+			steps := make([]string, 0, len(plan.Actions))
+			for _, action := range plan.Actions {
+				steps = append(steps, action.Step)
+			}
+			require.Equal(t, tt.expectedSteps, steps)
+			require.Equal(t, tt.isSuccessExpected, plan.CanSucceed())
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+const playbookFileExtension = ".yml"
+
+// RunPlaybook runs the specified playbook with ansible-playbook in the Ansible directory of the container, streaming its output.
+// The playbook name can be specified with or without extension and must be one of the playbooks in the Ansible directory.
+// Each extra variable has the form key=value. The container is the one of the specified profile. It returns the exit code of ansible-playbook
+func RunPlaybook(ctx context.Context, profile string, playbookName string, inventoryName string, extraVars []string, limit string) (int, error) {
+
+	orchestrator, err := createDockerOrchestrator(ctx, profile)
+	if err != nil {
+		return -1, fmt.Errorf("unable to create a Docker client: %v", err)
+	}
+	defer orchestrator.CloseDockerClient()
+
+	containerId, err := orchestrator.retrieveContainerReadyForUse()
+	if err != nil {
+		return -1, err
+	}
+
+	playbookFileName, err := orchestrator.findPlaybook(containerId, playbookName)
+	if err != nil {
+		return -1, err
+	}
+
+	isInventoryPresent, err := orchestrator.pathExistsInContainer(containerId, ansibleDirPathOnContainer+"/"+inventoryName, false)
+	if err != nil {
+		return -1, fmt.Errorf("unable to check whether the Ansible inventory is present: %v", err)
+	}
+	if !isInventoryPresent {
+		return -1, fmt.Errorf("the Ansible inventory %v was not found in %v", inventoryName, ansibleDirPathOnContainer)
+	}
+
+	cmd := buildPlaybookCommand(playbookFileName, inventoryName, extraVars, limit)
+	logger.Infof("Running %v in container %v \n", strings.Join(cmd, " "), orchestrator.containerName)
+
+	exitCode, err := orchestrator.execInContainer(containerId, cmd, ansibleDirPathOnContainer, logger.Writer(logger.InfoLevel))
+	if err != nil && ctx.Err() != nil {
+		orchestrator.stopPlaybook(containerId, cmd)
+		return -1, fmt.Errorf("playbook interrupted: %v", err)
+	}
+	return exitCode, err
+}
+
+// stopPlaybook interrupts ansible-playbook in the container, as an exec cannot be stopped through the Docker API and the playbook would
+// otherwise carry on after this utility exits. The context of the orchestrator has already been cancelled, so a separate one is used
+func (o *DockerOrchestrator) stopPlaybook(containerId string, cmd []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	stopOrchestrator := &DockerOrchestrator{
+		cli:           o.cli,
+		ctx:           ctx,
+		containerName: o.containerName,
+	}
+	// pkill matches the full command line against an extended regular expression, which the quoted command is also valid as
+	err := stopOrchestrator.runCommandWithoutOutput(containerId, "pkill", "-INT", "-f", "^"+regexp.QuoteMeta(strings.Join(cmd, " ")))
+	if err != nil {
+		logger.Warnf("The playbook could not be stopped in the container %v, it may still be running: %v \n", o.containerName, err)
+		return
+	}
+	logger.Infof("The playbook was stopped in the container %v \n", o.containerName)
+}
+
+func buildPlaybookCommand(playbookFileName string, inventoryName string, extraVars []string, limit string) []string {
+	cmd := []string{"ansible-playbook", "-i", inventoryName, playbookFileName}
+	for _, extraVar := range extraVars {
+		cmd = append(cmd, "-e", extraVar)
+	}
+	if limit != "" {
+		cmd = append(cmd, "--limit", limit)
+	}
+	return cmd
+}
+
+// playbookFileNameOf returns the file name of the playbook, which must be located directly in the Ansible directory of the container
+func playbookFileNameOf(playbookName string) (string, error) {
+	if strings.ContainsAny(playbookName, "/\\") {
+		return "", fmt.Errorf("invalid playbook %v, it must be the name of a playbook in %v", playbookName, ansibleDirPathOnContainer)
+	}
+	if !strings.HasSuffix(playbookName, playbookFileExtension) {
+		return playbookName + playbookFileExtension, nil
+	}
+	return playbookName, nil
+}
+
+// findPlaybook returns the file name of the playbook after checking that it exists in the Ansible directory of the container.
+// The available playbooks are only listed if it does not, to help the user
+func (o *DockerOrchestrator) findPlaybook(containerId string, playbookName string) (string, error) {
+	playbookFileName, err := playbookFileNameOf(playbookName)
+	if err != nil {
+		return "", err
+	}
+	isPlaybookPresent, err := o.pathExistsInContainer(containerId, path.Join(ansibleDirPathOnContainer, playbookFileName), false)
+	if err != nil {
+		return "", fmt.Errorf("unable to check whether the playbook %v is present: %v", playbookName, err)
+	}
+	if isPlaybookPresent {
+		return playbookFileName, nil
+	}
+
+	availablePlaybooks, err := o.listPlaybooks(containerId)
+	if err != nil {
+		return "", fmt.Errorf("unknown playbook %v. The available playbooks could not be listed: %v", playbookName, err)
+	}
+	return "", fmt.Errorf("unknown playbook %v. Available playbooks are: %v", playbookName, strings.Join(availablePlaybooks, ", "))
+}
+
+// listPlaybooks returns the names of the playbooks located directly in the Ansible directory of the container.
+// Only that directory is listed, rather than copying it with all its subdirectories
+func (o *DockerOrchestrator) listPlaybooks(containerId string) ([]string, error) {
+	var listing strings.Builder
+	cmd := []string{"find", ansibleDirPathOnContainer, "-maxdepth", "1", "-type", "f", "-name", "*" + playbookFileExtension, "-printf", "%f\\n"}
+	exitCode, err := o.execInContainer(containerId, cmd, ansibleDirPathOnContainer, &listing)
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("command %v exited with code %d", cmd, exitCode)
+	}
+	return parsePlaybookListing(listing.String()), nil
+}
+
+// parsePlaybookListing returns the sorted file names listed one per line. The exec output has Windows line endings, as it is attached to a TTY
+func parsePlaybookListing(listing string) []string {
+	playbooks := strings.Fields(listing)
+	sort.Strings(playbooks)
+	return playbooks
+}
@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBuildPlaybookCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		extraVars   []string
+		limit       string
+		expectedCmd []string
+	}{
+		{
+			name:        "no extra vars, no limit",
+			expectedCmd: []string{"ansible-playbook", "-i", "zdm_ansible_inventory", "deploy_zdm_proxy.yml"},
+		},
+		{
+			name:      "extra vars and limit",
+			extraVars: []string{"pause_between_restarts=10", "zdm_proxy_image=datastax/zdm-proxy:2.3.0"},
+			limit:     "172.18.10.1",
+			expectedCmd: []string{"ansible-playbook", "-i", "zdm_ansible_inventory", "deploy_zdm_proxy.yml",
+				"-e", "pause_between_restarts=10", "-e", "zdm_proxy_image=datastax/zdm-proxy:2.3.0", "--limit", "172.18.10.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualCmd := buildPlaybookCommand("deploy_zdm_proxy.yml", "zdm_ansible_inventory", tt.extraVars, tt.limit)
+			require.Equal(t, tt.expectedCmd, actualCmd)
+		})
+	}
+}
+
+func TestPlaybookFileNameOf(t *testing.T) {
+	tests := []struct {
+		name             string
+		playbookName     string
+		expectedFileName string
+		isErrorExpected  bool
+	}{
+		{name: "name without extension", playbookName: "deploy_zdm_proxy", expectedFileName: "deploy_zdm_proxy.yml"},
+		{name: "name with extension", playbookName: "deploy_zdm_proxy.yml", expectedFileName: "deploy_zdm_proxy.yml"},
+		{name: "path in a subdirectory", playbookName: "roles/deploy.yml", isErrorExpected: true},
+		{name: "path out of the ansible directory", playbookName: "..\\deploy.yml", isErrorExpected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName, err := playbookFileNameOf(tt.playbookName)
+			if tt.isErrorExpected {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedFileName, fileName)
+		})
+	}
+}
+
+func TestFindPlaybook_Present(t *testing.T) {
+	dockerApi := &fakeDockerApi{containerId: "abcd", containerState: "running",
+		pathsInContainer: map[string]bool{ansibleDirPathOnContainer + "/deploy_zdm_proxy.yml": false}}
+	dockerApi.start(t)
+	orchestrator, err := createDockerOrchestrator(context.Background(), "")
+	require.Nil(t, err)
+	defer orchestrator.CloseDockerClient()
+
+	playbookFileName, err := orchestrator.findPlaybook("abcd", "deploy_zdm_proxy")
+	require.Nil(t, err)
+	require.Equal(t, "deploy_zdm_proxy.yml", playbookFileName)
+}
+
+func TestParsePlaybookListing(t *testing.T) {
+	require.Equal(t, []string{"deploy_zdm_proxy.yml", "rolling_restart_zdm_proxy.yml"},
+		parsePlaybookListing("rolling_restart_zdm_proxy.yml\r\ndeploy_zdm_proxy.yml\r\n"))
+	require.Empty(t, parsePlaybookListing(""))
+}
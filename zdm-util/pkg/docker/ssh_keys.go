@@ -0,0 +1,264 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+const (
+	sshDirPathOnContainer = containerHomeDir + "/.ssh"
+
+	sshConfigFileMode = 0600
+
+	// the SSH agent holding the keys protected by a passphrase listens on a fixed socket, so that the SSH configuration can refer to it
+	sshAgentSocketPathOnContainer = sshDirPathOnContainer + "/agent.sock"
+	// the askpass program passes the passphrase from the environment to ssh-add, which would otherwise read it from a terminal
+	sshAskPassPathOnContainer = sshDirPathOnContainer + "/zdm_util_askpass.sh"
+	sshAskPassFileMode        = 0755
+	sshKeyPassphraseEnvVar    = "ZDM_UTIL_SSH_KEY_PASSPHRASE"
+)
+
+// additionalSshKeysToCopy returns the additional SSH keys whose file must be copied to the container, leaving out
+// the main key, which is copied separately, and any key whose file is listed more than once
+func additionalSshKeysToCopy(containerConfig *config.ContainerInitConfig) []string {
+	pathsToCopy := make([]string, 0, len(containerConfig.AdditionalSshKeys))
+	for _, sshKey := range containerConfig.AdditionalSshKeys {
+		if sshKey.PathOnHost == containerConfig.SshKeyPathOnHost || containsPath(pathsToCopy, sshKey.PathOnHost) {
+			continue
+		}
+		pathsToCopy = append(pathsToCopy, sshKey.PathOnHost)
+	}
+	return pathsToCopy
+}
+
+func containsPath(paths []string, p string) bool {
+	for _, existingPath := range paths {
+		if existingPath == p {
+			return true
+		}
+	}
+	return false
+}
+
+// needsSshConfig returns whether the SSH configuration of the container must be written before running the initialization script,
+// which can only map the SSH keys to a single host pattern and cannot configure a jumphost
+func needsSshConfig(containerConfig *config.ContainerInitConfig) bool {
+	return len(containerConfig.AdditionalSshKeys) > 0 || config.IsCidr(containerConfig.ProxyIpAddressPrefix) || usesJumphost(containerConfig) ||
+		len(inventoryHostnames(containerConfig)) > 0
+}
+
+// inventoryHostnames returns the hosts of the proxies and monitoring groups of the Ansible inventory that are specified by hostname.
+// SSH matches host patterns against the name Ansible connects to, so these hosts are not matched by the proxy address prefix.
+// An inventory that cannot be read has no hostnames, as it is validated before the container is created
+func inventoryHostnames(containerConfig *config.ContainerInitConfig) []string {
+	if containerConfig.AnsibleInventoryPathOnHost == "" {
+		return nil
+	}
+	ansibleInventory, err := inventory.ParseFile(containerConfig.AnsibleInventoryPathOnHost)
+	if err != nil {
+		return nil
+	}
+	hostnames := make([]string, 0)
+	for _, groupName := range []string{inventory.ProxiesGroupName, inventory.MonitoringGroupName} {
+		for _, host := range ansibleInventory.HostsOf(groupName) {
+			if inventory.IsHostname(host.Address()) && !containsPath(hostnames, host.Address()) {
+				hostnames = append(hostnames, host.Address())
+			}
+		}
+	}
+	return hostnames
+}
+
+// buildSshConfig returns the content of the SSH configuration of the container, with an entry for each additional key and,
+// if the proxy addresses are specified as a CIDR range, an entry for the main key with the patterns of the range, followed by
+// the entries of the jumphost and of the hosts behind it, if any, see buildJumphostSshConfig. Without a jumphost, the hosts of the inventory
+// that are specified by hostname have an entry for the main key, as the patterns of the proxy address prefix only match IP addresses.
+// The initialization script then appends its own entries for the proxies, so the additional keys are tried first
+// when a host matches the patterns of both
+func buildSshConfig(containerConfig *config.ContainerInitConfig) (string, error) {
+	var sb strings.Builder
+	if len(containerConfig.AdditionalSshKeys) > 0 {
+		sb.WriteString("# additional SSH keys\n")
+		for _, sshKey := range containerConfig.AdditionalSshKeys {
+			hostPatterns := make([]string, 0, len(sshKey.HostPatterns))
+			for _, hostPattern := range sshKey.HostPatterns {
+				patterns, err := config.HostPatterns(hostPattern)
+				if err != nil {
+					return "", err
+				}
+				hostPatterns = append(hostPatterns, patterns...)
+			}
+			writeSshConfigEntry(&sb, hostPatterns, sshKey.PathOnHost)
+		}
+	}
+	if config.IsCidr(containerConfig.ProxyIpAddressPrefix) {
+		hostPatterns, err := config.HostPatterns(containerConfig.ProxyIpAddressPrefix)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("# proxy instances\n")
+		writeSshConfigEntry(&sb, hostPatterns, containerConfig.SshKeyPathOnHost)
+	}
+	if hostnames := inventoryHostnames(containerConfig); len(hostnames) > 0 && !usesJumphost(containerConfig) {
+		sb.WriteString("# hosts specified by hostname\n")
+		writeSshConfigEntry(&sb, hostnames, containerConfig.SshKeyPathOnHost)
+	}
+	if usesJumphost(containerConfig) {
+		jumphostSshConfig, err := buildJumphostSshConfig(containerConfig)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(jumphostSshConfig)
+	}
+	return sb.String(), nil
+}
+
+func writeSshConfigEntry(sb *strings.Builder, hostPatterns []string, sshKeyPathOnHost string) {
+	sb.WriteString(fmt.Sprintf("Host %s\n  IdentityFile %s\n", strings.Join(hostPatterns, " "), path.Join(sshDirPathOnContainer, filepath.Base(sshKeyPathOnHost))))
+}
+
+// initScriptHostPattern returns the host pattern passed to the initialization script. The script only accepts a single pattern,
+// so a CIDR range, which is translated into several patterns, is passed as its first pattern, and the complete set of patterns
+// is written to the SSH configuration beforehand, see buildSshConfig
+func initScriptHostPattern(containerConfig *config.ContainerInitConfig) string {
+	hostPatterns, err := config.HostPatterns(containerConfig.ProxyIpAddressPrefix)
+	if err != nil || len(hostPatterns) == 0 {
+		return containerConfig.ProxyIpAddressPrefix
+	}
+	return hostPatterns[0]
+}
+
+// writeSshConfig writes the SSH configuration of the container, see buildSshConfig. The additional keys must already have been copied
+// to the SSH directory of the container, and they are made only accessible to the user of the container together with the configuration
+func (o *DockerOrchestrator) writeSshConfig(containerId string, containerConfig *config.ContainerInitConfig) error {
+	sshConfig, err := buildSshConfig(containerConfig)
+	if err != nil {
+		return err
+	}
+	if err = o.writeFileToContainer(containerId, sshDirPathOnContainer, path.Base(sshConfigPathOnContainer), []byte(sshConfig), sshConfigFileMode); err != nil {
+		return fmt.Errorf("unable to write the SSH configuration: %v", err)
+	}
+
+	keyPathsOnContainer := make([]string, 0)
+	for _, keyPathOnHost := range additionalSshKeysToCopy(containerConfig) {
+		keyPathsOnContainer = append(keyPathsOnContainer, path.Join(sshDirPathOnContainer, filepath.Base(keyPathOnHost)))
+	}
+
+	// files copied to the container are owned by root
+	chownCmd := append([]string{"sudo", "chown", containerUser + ":" + containerUser, sshConfigPathOnContainer}, keyPathsOnContainer...)
+	if err := o.runCommandWithoutOutput(containerId, chownCmd...); err != nil {
+		return fmt.Errorf("unable to change the owner of the SSH configuration and keys: %v", err)
+	}
+	if len(keyPathsOnContainer) > 0 {
+		chmodCmd := append([]string{"chmod", "400"}, keyPathsOnContainer...)
+		if err := o.runCommandWithoutOutput(containerId, chmodCmd...); err != nil {
+			return fmt.Errorf("unable to change the permissions of the SSH keys: %v", err)
+		}
+	}
+	return nil
+}
+
+// writeFileToContainer creates or replaces a file with the specified content in a directory of the container
+func (o *DockerOrchestrator) writeFileToContainer(containerId string, dirPath string, fileName string, content []byte, mode int64) error {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	header := &tar.Header{
+		Name:    fileName,
+		Mode:    mode,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return o.cli.CopyToContainer(o.ctx, containerId, dirPath, &buf, container.CopyToContainerOptions{})
+}
+
+// sshKeyToLoad is an SSH key protected by a passphrase, which is loaded into the SSH agent of the container
+type sshKeyToLoad struct {
+	pathOnContainer string
+	passphrase      string
+}
+
+// sshKeysToLoad returns the SSH keys whose passphrase was entered, in order of path. Once the container has been initialized,
+// all keys are in its SSH directory, as the initialization script copies the main key there too
+func sshKeysToLoad(sshKeyPassphrases map[string]string) []sshKeyToLoad {
+	keys := make([]sshKeyToLoad, 0, len(sshKeyPassphrases))
+	for keyPathOnHost, passphrase := range sshKeyPassphrases {
+		keys = append(keys, sshKeyToLoad{
+			pathOnContainer: path.Join(sshDirPathOnContainer, filepath.Base(keyPathOnHost)),
+			passphrase:      passphrase,
+		})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].pathOnContainer < keys[j].pathOnContainer
+	})
+	return keys
+}
+
+// buildSshAgentConfig returns the entry appended to the SSH configuration of the container, so that SSH offers the keys held by the agent
+// to all hosts, in addition to the identity files of the other entries
+func buildSshAgentConfig() string {
+	return fmt.Sprintf("# SSH keys protected by a passphrase\nHost *\n  IdentityAgent %s\n", sshAgentSocketPathOnContainer)
+}
+
+// loadSshKeysIntoAgent starts an SSH agent in the container and adds the keys to it with their passphrases, so that Ansible can use them
+// without prompting. The agent does not survive a restart of the container, after which the keys must be loaded again with ssh-add.
+// The passphrases are only passed in the environment of ssh-add, through a temporary askpass program
+func (o *DockerOrchestrator) loadSshKeysIntoAgent(containerId string, sshKeyPassphrases map[string]string) error {
+	// a socket left behind by a previous agent, e.g. before a restart, would prevent the new agent from starting
+	startAgentCmd := fmt.Sprintf("rm -f %[1]s && ssh-agent -a %[1]s > /dev/null", sshAgentSocketPathOnContainer)
+	if err := o.runCommandWithoutOutput(containerId, "sh", "-c", startAgentCmd); err != nil {
+		return fmt.Errorf("unable to start the SSH agent: %v", err)
+	}
+
+	askPass := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$%s\"\n", sshKeyPassphraseEnvVar)
+	if err := o.writeFileToContainer(containerId, sshDirPathOnContainer, path.Base(sshAskPassPathOnContainer), []byte(askPass), sshAskPassFileMode); err != nil {
+		return fmt.Errorf("unable to write the askpass program: %v", err)
+	}
+	defer func() {
+		if err := o.runCommandWithoutOutput(containerId, "sudo", "rm", "-f", sshAskPassPathOnContainer); err != nil {
+			logger.Warnf("The askpass program %v could not be removed from the container due to %v \n", sshAskPassPathOnContainer, err)
+		}
+	}()
+
+	for _, key := range sshKeysToLoad(sshKeyPassphrases) {
+		env := []string{
+			"SSH_AUTH_SOCK=" + sshAgentSocketPathOnContainer,
+			"SSH_ASKPASS=" + sshAskPassPathOnContainer,
+			"SSH_ASKPASS_REQUIRE=force",
+			// older versions of ssh-add only use the askpass program if a display is set
+			"DISPLAY=none",
+			sshKeyPassphraseEnvVar + "=" + key.passphrase,
+		}
+		if err := o.runCommandWithEnvWithoutOutput(containerId, env, "ssh-add", key.pathOnContainer); err != nil {
+			return fmt.Errorf("unable to add the SSH key %v to the SSH agent: %v", key.pathOnContainer, err)
+		}
+	}
+
+	// the entry is only appended once, as the keys can be loaded again into an existing container
+	appendConfigCmd := fmt.Sprintf("grep -qF 'IdentityAgent %[1]s' %[2]s || printf '%%s' '%[3]s' >> %[2]s",
+		sshAgentSocketPathOnContainer, sshConfigPathOnContainer, buildSshAgentConfig())
+	if err := o.runCommandWithoutOutput(containerId, "sh", "-c", appendConfigCmd); err != nil {
+		return fmt.Errorf("unable to add the SSH agent to the SSH configuration: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package docker
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/testutils"
+)
+
+func TestBuildSshConfig(t *testing.T) {
+	sshConfig, err := buildSshConfig(&config.ContainerInitConfig{
+		SshKeyPathOnHost:     "/home/me/keys/proxy_key",
+		ProxyIpAddressPrefix: "172.18.*",
+		AdditionalSshKeys: []config.SshKey{
+			{PathOnHost: "/home/me/keys/monitoring_key", HostPatterns: []string{"172.18.10.5"}},
+			{PathOnHost: "/home/me/keys/other_key", HostPatterns: []string{"172.19.*", "172.20.*"}},
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, "# additional SSH keys\n"+
+		"Host 172.18.10.5\n"+
+		"  IdentityFile /home/ubuntu/.ssh/monitoring_key\n"+
+		"Host 172.19.* 172.20.*\n"+
+		"  IdentityFile /home/ubuntu/.ssh/other_key\n", sshConfig)
+}
+
+func TestBuildSshConfig_CidrRanges(t *testing.T) {
+	containerConfig := &config.ContainerInitConfig{
+		SshKeyPathOnHost:     "/home/me/keys/proxy_key",
+		ProxyIpAddressPrefix: "10.0.16.0/22",
+		AdditionalSshKeys: []config.SshKey{
+			{PathOnHost: "/home/me/keys/monitoring_key", HostPatterns: []string{"10.1.0.0/16", "172.19.*"}},
+		},
+	}
+	require.True(t, needsSshConfig(containerConfig))
+	sshConfig, err := buildSshConfig(containerConfig)
+	require.Nil(t, err)
+	require.Equal(t, "# additional SSH keys\n"+
+		"Host 10.1.* 172.19.*\n"+
+		"  IdentityFile /home/ubuntu/.ssh/monitoring_key\n"+
+		"# proxy instances\n"+
+		"Host 10.0.16.* 10.0.17.* 10.0.18.* 10.0.19.*\n"+
+		"  IdentityFile /home/ubuntu/.ssh/proxy_key\n", sshConfig)
+	require.Equal(t, "10.0.16.*", initScriptHostPattern(containerConfig))
+
+	containerConfig.AdditionalSshKeys = nil
+	containerConfig.ProxyIpAddressPrefix = "10.0.*"
+	require.False(t, needsSshConfig(containerConfig))
+	require.Equal(t, "10.0.*", initScriptHostPattern(containerConfig))
+}
+
+func TestBuildSshConfig_Jumphost(t *testing.T) {
+	containerConfig := &config.ContainerInitConfig{
+		SshKeyPathOnHost:           "/home/me/keys/proxy_key",
+		ProxyIpAddressPrefix:       "172.18.*",
+		AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+		JumphostPublicIpAddress:    "203.0.113.10",
+		JumphostPrivateIpAddress:   "172.18.1.10",
+	}
+	require.True(t, needsSshConfig(containerConfig))
+	sshConfig, err := buildSshConfig(containerConfig)
+	require.Nil(t, err)
+	require.Equal(t, "# jumphost\n"+
+		"Host 172.18.1.10 jumphost\n"+
+		"  Hostname 203.0.113.10\n"+
+		"  Port 22\n"+
+		"# proxy instances\n"+
+		"Host 172.18.10.32 zdm-proxy-0\n"+
+		"  Hostname 172.18.10.32\n"+
+		"  ProxyJump jumphost\n"+
+		"Host 172.18.11.47 zdm-proxy-1\n"+
+		"  Hostname 172.18.11.47\n"+
+		"  ProxyJump jumphost\n"+
+		"Host 172.18.12.8 zdm-proxy-2\n"+
+		"  Hostname 172.18.12.8\n"+
+		"  ProxyJump jumphost\n"+
+		"# monitoring instance\n"+
+		"Host 172.18.100.45 zdm-monitoring\n"+
+		"  Hostname 172.18.100.45\n"+
+		"  ProxyJump jumphost\n"+
+		"# defaults for all hosts\n"+
+		"Host *\n"+
+		"  User ubuntu\n"+
+		"  IdentityFile /home/ubuntu/.ssh/proxy_key\n"+
+		"  IdentitiesOnly yes\n"+
+		"  StrictHostKeyChecking no\n"+
+		"  GlobalKnownHostsFile /dev/null\n"+
+		"  UserKnownHostsFile /dev/null\n", sshConfig)
+
+	containerConfig.AnsibleInventoryPathOnHost = "/home/me/missing_inventory"
+	_, err = buildSshConfig(containerConfig)
+	require.NotNil(t, err)
+}
+
+func TestBuildSshConfig_Hostnames(t *testing.T) {
+	inventoryPath := filepath.Join(t.TempDir(), "inventory")
+	require.Nil(t, os.WriteFile(inventoryPath, []byte("[proxies]\n"+
+		"proxy-0.example.internal ansible_connection=ssh ansible_user=ubuntu\n"+
+		"172.18.11.47 ansible_connection=ssh ansible_user=ubuntu # proxy-1.example.internal\n"+
+		"Proxy-2.example.internal ansible_connection=ssh ansible_user=ubuntu\n"+
+		"[monitoring]\n"+
+		"monitoring.example.internal ansible_connection=ssh ansible_user=ubuntu\n"), 0644))
+	containerConfig := &config.ContainerInitConfig{
+		SshKeyPathOnHost:           "/home/me/keys/proxy_key",
+		ProxyIpAddressPrefix:       "172.18.*",
+		AnsibleInventoryPathOnHost: inventoryPath,
+	}
+	require.True(t, needsSshConfig(containerConfig))
+	sshConfig, err := buildSshConfig(containerConfig)
+	require.Nil(t, err)
+	require.Equal(t, "# hosts specified by hostname\n"+
+		"Host proxy-0.example.internal Proxy-2.example.internal monitoring.example.internal\n"+
+		"  IdentityFile /home/ubuntu/.ssh/proxy_key\n", sshConfig)
+
+	// the jumphost configuration already uses the main key for all hosts
+	containerConfig.JumphostPublicIpAddress = "203.0.113.10"
+	sshConfig, err = buildSshConfig(containerConfig)
+	require.Nil(t, err)
+	require.NotContains(t, sshConfig, "# hosts specified by hostname")
+	require.Contains(t, sshConfig, "Host proxy-0.example.internal zdm-proxy-0\n  Hostname proxy-0.example.internal\n  ProxyJump jumphost\n")
+
+	require.Nil(t, os.WriteFile(inventoryPath, []byte("[proxies]\n172.18.10.32\n"), 0644))
+	containerConfig.JumphostPublicIpAddress = ""
+	require.False(t, needsSshConfig(containerConfig))
+}
+
+func TestBuildJumphostSshConfig_MonitoringHostIsJumphost(t *testing.T) {
+	// as imported from the Terraform automation, where the jumphost is the monitoring instance
+	inventoryPath := filepath.Join(t.TempDir(), "inventory")
+	require.Nil(t, os.WriteFile(inventoryPath, []byte("[proxies]\n"+
+		"172.18.10.32 ansible_connection=ssh ansible_user=ubuntu\n"+
+		"172.18.11.47 ansible_connection=ssh ansible_user=ubuntu\n"+
+		"[monitoring]\n"+
+		"172.18.100.45 ansible_connection=ssh ansible_user=ubuntu\n"), 0644))
+	sshConfig, err := buildJumphostSshConfig(&config.ContainerInitConfig{
+		SshKeyPathOnHost:           "/home/me/keys/proxy_key",
+		AnsibleInventoryPathOnHost: inventoryPath,
+		JumphostPublicIpAddress:    "203.0.113.10",
+		JumphostPrivateIpAddress:   "172.18.100.45",
+	})
+	require.Nil(t, err)
+	require.Equal(t, "# jumphost\n"+
+		"Host 172.18.100.45 jumphost zdm-monitoring\n"+
+		"  Hostname 203.0.113.10\n"+
+		"  Port 22\n"+
+		"# proxy instances\n"+
+		"Host 172.18.10.32 zdm-proxy-0\n"+
+		"  Hostname 172.18.10.32\n"+
+		"  ProxyJump jumphost\n"+
+		"Host 172.18.11.47 zdm-proxy-1\n"+
+		"  Hostname 172.18.11.47\n"+
+		"  ProxyJump jumphost\n"+
+		"# defaults for all hosts\n"+
+		"Host *\n"+
+		"  User ubuntu\n"+
+		"  IdentityFile /home/ubuntu/.ssh/proxy_key\n"+
+		"  IdentitiesOnly yes\n"+
+		"  StrictHostKeyChecking no\n"+
+		"  GlobalKnownHostsFile /dev/null\n"+
+		"  UserKnownHostsFile /dev/null\n", sshConfig)
+}
+
+func TestBuildJumphostSshConfig_PortsAndNoPrivateIpAddress(t *testing.T) {
+	inventoryPath := filepath.Join(t.TempDir(), "inventory")
+	require.Nil(t, os.WriteFile(inventoryPath, []byte("[proxies]\n172.18.10.32:2222\n"), 0644))
+	sshConfig, err := buildJumphostSshConfig(&config.ContainerInitConfig{
+		SshKeyPathOnHost:           "/home/me/keys/proxy_key",
+		AnsibleInventoryPathOnHost: inventoryPath,
+		JumphostPublicIpAddress:    "203.0.113.10",
+	})
+	require.Nil(t, err)
+	require.Contains(t, sshConfig, "Host jumphost\n  Hostname 203.0.113.10\n  Port 22\n")
+	require.Contains(t, sshConfig, "Host 172.18.10.32 zdm-proxy-0\n  Hostname 172.18.10.32\n  Port 2222\n  ProxyJump jumphost\n")
+	require.NotContains(t, sshConfig, "# monitoring instance")
+}
+
+func TestBuildJumphostSshConfig_RemoteUsers(t *testing.T) {
+	inventoryPath := filepath.Join(t.TempDir(), "inventory")
+	require.Nil(t, os.WriteFile(inventoryPath, []byte("[proxies]\n"+
+		"172.18.10.32 ansible_connection=ssh ansible_user=rocky\n"+
+		"172.18.11.47 ansible_connection=ssh ansible_user=ec2-user\n"+
+		"[monitoring]\n"+
+		"172.18.100.45 ansible_connection=ssh ansible_user=centos ansible_port=2222\n"), 0644))
+	sshConfig, err := buildJumphostSshConfig(&config.ContainerInitConfig{
+		SshKeyPathOnHost:           "/home/me/keys/proxy_key",
+		AnsibleInventoryPathOnHost: inventoryPath,
+		JumphostPublicIpAddress:    "203.0.113.10",
+		JumphostPrivateIpAddress:   "172.18.100.45",
+		RemoteUser:                 "rocky",
+	})
+	require.Nil(t, err)
+	require.Contains(t, sshConfig, "Host 172.18.100.45 jumphost zdm-monitoring\n  Hostname 203.0.113.10\n  Port 2222\n  User centos\n")
+	require.Contains(t, sshConfig, "Host 172.18.10.32 zdm-proxy-0\n  Hostname 172.18.10.32\n  ProxyJump jumphost\n")
+	require.Contains(t, sshConfig, "Host 172.18.11.47 zdm-proxy-1\n  Hostname 172.18.11.47\n  User ec2-user\n  ProxyJump jumphost\n")
+	require.NotContains(t, sshConfig, "# monitoring instance")
+	require.Contains(t, sshConfig, "Host *\n  User rocky\n")
+}
+
+func TestAdditionalSshKeysToCopy(t *testing.T) {
+	containerConfig := &config.ContainerInitConfig{
+		SshKeyPathOnHost: "/home/me/keys/proxy_key",
+		AdditionalSshKeys: []config.SshKey{
+			{PathOnHost: "/home/me/keys/monitoring_key", HostPatterns: []string{"172.18.10.5"}},
+			{PathOnHost: "/home/me/keys/proxy_key", HostPatterns: []string{"172.19.*"}},
+			{PathOnHost: "/home/me/keys/monitoring_key", HostPatterns: []string{"172.20.10.5"}},
+		},
+	}
+	require.Equal(t, []string{"/home/me/keys/monitoring_key"}, additionalSshKeysToCopy(containerConfig))
+
+	files := filesToCopy(containerConfig)
+	require.Equal(t, 3, len(files))
+	require.Equal(t, copyAdditionalSshKeyStep, files[2].step)
+	require.Equal(t, sshDirPathOnContainer, files[2].PathOnContainer)
+}
+
+func TestSshKeysToLoad(t *testing.T) {
+	keys := sshKeysToLoad(map[string]string{
+		"/home/me/keys/proxy_key":      "proxy passphrase",
+		"/home/me/keys/monitoring_key": "monitoring passphrase",
+	})
+	require.Equal(t, []sshKeyToLoad{
+		{pathOnContainer: "/home/ubuntu/.ssh/monitoring_key", passphrase: "monitoring passphrase"},
+		{pathOnContainer: "/home/ubuntu/.ssh/proxy_key", passphrase: "proxy passphrase"},
+	}, keys)
+	require.Equal(t, "# SSH keys protected by a passphrase\n"+
+		"Host *\n"+
+		"  IdentityAgent /home/ubuntu/.ssh/agent.sock\n", buildSshAgentConfig())
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package docker
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// monitorTerminalResize resizes the TTY of the exec whenever the local terminal receives SIGWINCH.
+// It returns a function that stops the monitoring
+func (o *DockerOrchestrator) monitorTerminalResize(execID string, fd uintptr) func() {
+	resizeSignals := make(chan os.Signal, 1)
+	signal.Notify(resizeSignals, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-resizeSignals:
+				o.resizeExecToTerminal(execID, fd)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(resizeSignals)
+		close(done)
+	}
+}
@@ -0,0 +1,42 @@
+//go:build windows
+
+package docker
+
+import (
+	"time"
+
+	"github.com/moby/term"
+)
+
+const terminalResizePollInterval = 250 * time.Millisecond
+
+// monitorTerminalResize resizes the TTY of the exec whenever the size of the local console changes.
+// Windows has no equivalent of SIGWINCH, so the console size is polled. It returns a function that stops the monitoring
+func (o *DockerOrchestrator) monitorTerminalResize(execID string, fd uintptr) func() {
+	done := make(chan struct{})
+
+	go func() {
+		var previousHeight, previousWidth uint16
+		if winsize, err := term.GetWinsize(fd); err == nil {
+			previousHeight, previousWidth = winsize.Height, winsize.Width
+		}
+		ticker := time.NewTicker(terminalResizePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				winsize, err := term.GetWinsize(fd)
+				if err == nil && (winsize.Height != previousHeight || winsize.Width != previousWidth) {
+					previousHeight, previousWidth = winsize.Height, winsize.Width
+					o.resizeExecToTerminal(execID, fd)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
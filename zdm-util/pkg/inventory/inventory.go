@@ -0,0 +1,338 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ProxiesGroupName is the group of the hosts the playbooks deploy the proxies to
+	ProxiesGroupName = "proxies"
+	// MonitoringGroupName is the group of the host the playbooks deploy the monitoring stack to, if any
+	MonitoringGroupName = "monitoring"
+	// UngroupedGroupName holds the hosts listed before the first section, as in Ansible
+	UngroupedGroupName = "ungrouped"
+
+	MinNumberOfProxiesForProduction   = 3
+	MinNumberOfProxiesForLocalTesting = 1
+
+	// AnsibleHostVarName is the host variable that sets the address to connect to, when it differs from the name of the host
+	AnsibleHostVarName = "ansible_host"
+	// AnsiblePortVarName is the host variable that is set when a port is specified after the name of the host, e.g. 172.18.10.5:2222
+	AnsiblePortVarName = "ansible_port"
+	// AnsibleUserVarName is the host variable that sets the user to connect as
+	AnsibleUserVarName = "ansible_user"
+
+	varsSectionType     = "vars"
+	childrenSectionType = "children"
+)
+
+// sectionHeaderRegexp matches a section header, e.g. [proxies], [proxies:vars] or [all:children], followed by an optional comment.
+// It is the same as that of the INI inventory plugin of Ansible
+var sectionHeaderRegexp = regexp.MustCompile(`^\[([^:\]\s]+)(?::(\w+))?\]\s*(?:#.*)?$`)
+
+// hostRangeRegexp matches a name with a range of hosts, e.g. 172.18.10.[1:3] or proxy-[a:c]
+var hostRangeRegexp = regexp.MustCompile(`\[[^\]]*:[^\]]*\]`)
+
+// Host is a host of an inventory group, as declared on a line of the inventory
+type Host struct {
+	Name string `json:"name"`
+	// Vars are the variables declared on the same line as the host
+	Vars map[string]string `json:"vars,omitempty"`
+	Line int               `json:"line"`
+}
+
+// Address returns the address used to connect to the host, which is the name of the host unless ansible_host is set
+func (h *Host) Address() string {
+	if address, found := h.Vars[AnsibleHostVarName]; found {
+		return address
+	}
+	return h.Name
+}
+
+// Group is a group of an inventory, with the hosts, variables and child groups declared in its sections
+type Group struct {
+	Name     string            `json:"name"`
+	Hosts    []*Host           `json:"hosts,omitempty"`
+	Vars     map[string]string `json:"vars,omitempty"`
+	Children []string          `json:"children,omitempty"`
+	// Line is the line of the first section of the group, or 0 if the group is only referred to as a child of another group
+	Line int `json:"line"`
+}
+
+// Inventory is the model of an Ansible inventory in the INI format
+type Inventory struct {
+	// Groups are listed in order of first appearance
+	Groups []*Group `json:"groups"`
+}
+
+// Group returns the group with the specified name, or nil if the inventory does not declare it
+func (i *Inventory) Group(name string) *Group {
+	for _, group := range i.Groups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// HostsOf returns the hosts of the group and of its child groups, recursively, in order of declaration
+func (i *Inventory) HostsOf(groupName string) []*Host {
+	return i.collectHosts(groupName, make(map[string]bool))
+}
+
+func (i *Inventory) collectHosts(groupName string, visitedGroups map[string]bool) []*Host {
+	group := i.Group(groupName)
+	// a group can be a child of several groups, and children can form a cycle
+	if group == nil || visitedGroups[groupName] {
+		return nil
+	}
+	visitedGroups[groupName] = true
+	hosts := append([]*Host{}, group.Hosts...)
+	for _, child := range group.Children {
+		hosts = append(hosts, i.collectHosts(child, visitedGroups)...)
+	}
+	return hosts
+}
+
+func (i *Inventory) getOrAddGroup(name string, line int) *Group {
+	group := i.Group(name)
+	if group == nil {
+		group = &Group{Name: name, Vars: make(map[string]string)}
+		i.Groups = append(i.Groups, group)
+	}
+	if group.Line == 0 {
+		group.Line = line
+	}
+	return group
+}
+
+// Error is a problem found in an inventory. Line is 0 if the problem does not relate to a specific line
+type Error struct {
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Message)
+}
+
+// Errors lists all the problems found in an inventory, so that they can be fixed at once
+type Errors []*Error
+
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, inventoryError := range e {
+		messages = append(messages, inventoryError.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Parse builds the model of an inventory in the INI format. It returns an error for each line that cannot be parsed,
+// together with the model of the lines that could be
+func Parse(content string) (*Inventory, Errors) {
+	inventory := &Inventory{Groups: make([]*Group, 0)}
+	parseErrors := make(Errors, 0)
+	addError := func(line int, format string, args ...interface{}) {
+		parseErrors = append(parseErrors, &Error{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var currentGroup *Group
+	sectionType := ""
+	// the lines of a section whose header cannot be parsed are skipped, as it is not known which group they belong to
+	isSkippingSection := false
+	for i, rawLine := range strings.Split(content, "\n") {
+		lineNumber := i + 1
+		line := strings.TrimSpace(rawLine)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		// as in Ansible, a line starting with a bracket that does not end with one is a host, e.g. [fd00::5]:2222
+		match := sectionHeaderRegexp.FindStringSubmatch(line)
+		if match == nil && strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			addError(lineNumber, "malformed section header %v. Expected [group], [group:vars] or [group:children]", line)
+			isSkippingSection = true
+			continue
+		}
+		if match != nil {
+			if match[2] != "" && match[2] != varsSectionType && match[2] != childrenSectionType {
+				addError(lineNumber, "unknown section type %v in %v. Expected vars or children", match[2], line)
+				isSkippingSection = true
+				continue
+			}
+			currentGroup = inventory.getOrAddGroup(match[1], lineNumber)
+			sectionType = match[2]
+			isSkippingSection = false
+			continue
+		}
+
+		if isSkippingSection {
+			continue
+		}
+		if currentGroup == nil {
+			currentGroup = inventory.getOrAddGroup(UngroupedGroupName, lineNumber)
+		}
+
+		switch sectionType {
+		case varsSectionType:
+			name, value, found := strings.Cut(line, "=")
+			if !found || strings.TrimSpace(name) == "" {
+				addError(lineNumber, "expected a variable in the form name=value in section [%v:%v], but found %v", currentGroup.Name, varsSectionType, line)
+				continue
+			}
+			currentGroup.Vars[strings.TrimSpace(name)] = unquote(strings.TrimSpace(value))
+		case childrenSectionType:
+			fields, err := splitFields(line)
+			if err != nil {
+				addError(lineNumber, "%v", err)
+				continue
+			}
+			if len(fields) != 1 {
+				addError(lineNumber, "expected the name of a single group in section [%v:%v], but found %v", currentGroup.Name, childrenSectionType, line)
+				continue
+			}
+			inventory.getOrAddGroup(fields[0], 0)
+			currentGroup.Children = append(currentGroup.Children, fields[0])
+		default:
+			host, err := parseHostLine(line, lineNumber)
+			if err != nil {
+				addError(lineNumber, "%v", err)
+				continue
+			}
+			currentGroup.Hosts = append(currentGroup.Hosts, host)
+		}
+	}
+	return inventory, parseErrors
+}
+
+// ParseFile reads and parses the inventory file. It returns an error of type Errors if the file could be read but not parsed
+func ParseFile(path string) (*Inventory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	inventory, parseErrors := Parse(string(content))
+	if len(parseErrors) > 0 {
+		return inventory, parseErrors
+	}
+	return inventory, nil
+}
+
+// parseHostLine parses a host line, made of the name of the host optionally followed by a port, and of its variables,
+// e.g. 172.18.10.5 ansible_connection=ssh ansible_user=ubuntu
+func parseHostLine(line string, lineNumber int) (*Host, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 || fields[0] == "" {
+		return nil, fmt.Errorf("expected a host, but found %v", line)
+	}
+
+	host := &Host{Vars: make(map[string]string), Line: lineNumber}
+	host.Name, err = parseHostName(fields[0], host.Vars)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields[1:] {
+		name, value, found := strings.Cut(field, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("expected a variable of host %v in the form name=value, but found %v", host.Name, field)
+		}
+		host.Vars[name] = value
+	}
+	return host, nil
+}
+
+// parseHostName returns the name of the host, setting ansible_port in the variables if a port follows the name.
+// IPv6 addresses can be written without brackets if no port is specified, e.g. fd00::5 or [fd00::5]:2222
+func parseHostName(field string, vars map[string]string) (string, error) {
+	if net.ParseIP(field) != nil {
+		return field, nil
+	}
+
+	name, port := field, ""
+	closingIdx := strings.Index(field, "]")
+	isBracketedIp := strings.HasPrefix(field, "[") && closingIdx > 0 && net.ParseIP(field[1:closingIdx]) != nil
+	if !isBracketedIp && hostRangeRegexp.MatchString(field) {
+		return "", fmt.Errorf("host ranges such as %v are not supported by this utility. Please list each host on its own line", field)
+	}
+
+	if strings.HasPrefix(field, "[") {
+		if closingIdx < 0 {
+			return "", fmt.Errorf("missing closing bracket in host %v", field)
+		}
+		name = field[1:closingIdx]
+		if rest := field[closingIdx+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return "", fmt.Errorf("unexpected characters %v after host %v", rest, name)
+			}
+			port = rest[1:]
+		}
+	} else if strings.Count(field, ":") == 1 {
+		name, port, _ = strings.Cut(field, ":")
+	}
+
+	if port != "" {
+		if portNumber, err := strconv.Atoi(port); err != nil || portNumber < 1 || portNumber > 65535 {
+			return "", fmt.Errorf("invalid port %v of host %v", port, name)
+		}
+		vars[AnsiblePortVarName] = port
+	}
+	return name, nil
+}
+
+// splitFields splits the line into fields separated by white spaces, as the INI inventory plugin of Ansible does with shlex.
+// Quotes group white spaces into a field and are removed, and an unquoted # starts a comment
+func splitFields(line string) ([]string, error) {
+	fields := make([]string, 0)
+	var current strings.Builder
+	inField := false
+	var quote rune
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inField = true
+		case c == ' ' || c == '\t':
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		case c == '#' && !inField:
+			return fields, nil
+		default:
+			current.WriteRune(c)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing quote %c in %v", quote, line)
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return fields, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,243 @@
+package inventory
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/testutils"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name                string
+		content             string
+		expectedProxies     []string
+		expectedMonitoring  []string
+		expectedHostVars    map[string]string
+		expectedErrorsLines []int
+	}{
+		{
+			name: "proxies and monitoring",
+			content: "[proxies]\n" +
+				"172.18.10.32 ansible_connection=ssh ansible_user=ubuntu\n" +
+				"172.18.11.47\n" +
+				"\n" +
+				"# comment\n" +
+				"[monitoring] # comment\n" +
+				"172.18.100.45\n",
+			expectedProxies:    []string{"172.18.10.32", "172.18.11.47"},
+			expectedMonitoring: []string{"172.18.100.45"},
+			expectedHostVars:   map[string]string{"ansible_connection": "ssh", "ansible_user": "ubuntu"},
+		},
+		{
+			name: "hosts of child groups",
+			content: "[proxies:children]\n" +
+				"proxies_rack1\n" +
+				"proxies_rack2\n" +
+				"[proxies_rack1]\n" +
+				"172.18.10.32\n" +
+				"[proxies_rack2]\n" +
+				"172.18.11.47\n" +
+				"[proxies:vars]\n" +
+				"ansible_user = \"ubuntu\"\n",
+			expectedProxies: []string{"172.18.10.32", "172.18.11.47"},
+		},
+		{
+			name: "ports and IPv6 addresses",
+			content: "[proxies]\n" +
+				"172.18.10.32:2222\n" +
+				"fd00::5\n" +
+				"[fd00::6]:2222\n" +
+				"proxy1 ansible_host=172.18.10.33\n",
+			expectedProxies:  []string{"172.18.10.32", "fd00::5", "fd00::6", "172.18.10.33"},
+			expectedHostVars: map[string]string{"ansible_port": "2222"},
+		},
+		{
+			name: "quoted variables",
+			content: "[proxies]\n" +
+				"172.18.10.32 ansible_ssh_common_args='-o ProxyJump=\"jumphost\"' # comment\n",
+			expectedProxies:  []string{"172.18.10.32"},
+			expectedHostVars: map[string]string{"ansible_ssh_common_args": "-o ProxyJump=\"jumphost\""},
+		},
+		{
+			name: "lines that cannot be parsed",
+			content: "[proxies\n" +
+				"172.18.10.1\n" +
+				"[proxies]\n" +
+				"172.18.10.[1:3]\n" +
+				"172.18.10.32:99999\n" +
+				"172.18.10.33 ansible_user\n" +
+				"172.18.10.34 ansible_user='ubuntu\n" +
+				"172.18.10.35\n" +
+				"[proxies:hosts]\n" +
+				"172.18.10.36\n" +
+				"[monitoring:vars]\n" +
+				"ansible_user\n",
+			expectedProxies:     []string{"172.18.10.35"},
+			expectedErrorsLines: []int{1, 4, 5, 6, 7, 9, 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory, parseErrors := Parse(tt.content)
+			require.NotNil(t, inventory)
+
+			errorsLines := make([]int, 0)
+			for _, parseError := range parseErrors {
+				errorsLines = append(errorsLines, parseError.Line)
+			}
+			if tt.expectedErrorsLines == nil {
+				require.Empty(t, parseErrors)
+			} else {
+				require.Equal(t, tt.expectedErrorsLines, errorsLines, parseErrors.Error())
+			}
+
+			require.Equal(t, tt.expectedProxies, addressesOf(inventory.HostsOf(ProxiesGroupName)))
+			if tt.expectedMonitoring != nil {
+				require.Equal(t, tt.expectedMonitoring, addressesOf(inventory.HostsOf(MonitoringGroupName)))
+			}
+			if tt.expectedHostVars != nil {
+				require.Equal(t, tt.expectedHostVars, inventory.HostsOf(ProxiesGroupName)[0].Vars)
+			}
+		})
+	}
+}
+
+func TestParse_HostsBeforeFirstSection(t *testing.T) {
+	inventory, parseErrors := Parse("172.18.10.32\n[proxies]\n172.18.10.33\n")
+	require.Empty(t, parseErrors)
+	require.Equal(t, []string{"172.18.10.32"}, addressesOf(inventory.HostsOf(UngroupedGroupName)))
+	require.Equal(t, []string{"172.18.10.33"}, addressesOf(inventory.HostsOf(ProxiesGroupName)))
+}
+
+func TestParse_ChildrenCycle(t *testing.T) {
+	inventory, parseErrors := Parse("[proxies:children]\nrack1\n[rack1:children]\nproxies\n[rack1]\n172.18.10.32\n")
+	require.Empty(t, parseErrors)
+	require.Equal(t, []string{"172.18.10.32"}, addressesOf(inventory.HostsOf(ProxiesGroupName)))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name               string
+		content            string
+		minNumberOfProxies int
+		expectedErrors     []string
+	}{
+		{
+			name:               "valid inventory",
+			content:            "[proxies]\n172.18.10.32\n172.18.11.47\n172.18.12.8\n[monitoring]\n172.18.100.45\n",
+			minNumberOfProxies: MinNumberOfProxiesForProduction,
+			expectedErrors:     []string{},
+		},
+		{
+			name:               "single proxy for local testing",
+			content:            "[proxies]\n172.18.10.32\n",
+			minNumberOfProxies: MinNumberOfProxiesForLocalTesting,
+			expectedErrors:     []string{},
+		},
+		{
+			name:               "single proxy for production",
+			content:            "[proxies]\n172.18.10.32\n",
+			minNumberOfProxies: MinNumberOfProxiesForProduction,
+			expectedErrors:     []string{"line 1: the [proxies] group has 1 hosts, but at least 3 are required"},
+		},
+		{
+			name:               "missing proxies group",
+			content:            "[monitoring]\n172.18.100.45\n",
+			minNumberOfProxies: MinNumberOfProxiesForLocalTesting,
+			expectedErrors:     []string{"no [proxies] group was found. The playbooks deploy the proxies to the hosts of this group"},
+		},
+		{
+			name:               "monitoring group with two hosts",
+			content:            "[proxies]\n172.18.10.32\n[monitoring]\n172.18.100.45\n172.18.100.46\n",
+			minNumberOfProxies: MinNumberOfProxiesForLocalTesting,
+			expectedErrors:     []string{"line 3: the [monitoring] group has 2 hosts, but the playbooks only deploy the monitoring stack to one host"},
+		},
+		{
+			name:               "placeholder, invalid address and duplicates",
+			content:            "[proxies]\n<private_IP_address_of_proxy_instance_0>\n172.18.10.300\nfd00::5\nfd00:0::5\n",
+			minNumberOfProxies: MinNumberOfProxiesForLocalTesting,
+			expectedErrors: []string{
+				"line 2: <private_IP_address_of_proxy_instance_0> is a placeholder from the example inventory. Please replace it with the private IP address of the instance",
+				"line 3: invalid address 172.18.10.300 of a host of group [proxies], which is neither an IP address nor a hostname",
+				"line 5: host fd00:0::5 of group [proxies] is already listed at line 4",
+			},
+		},
+		{
+			name:               "hostnames",
+			content:            "[proxies]\nproxy-0.example.internal\nproxy_1.example.internal\n-proxy-2\nPROXY-0.example.internal\n[monitoring]\nmonitoring\n",
+			minNumberOfProxies: MinNumberOfProxiesForLocalTesting,
+			expectedErrors: []string{
+				"line 3: invalid address proxy_1.example.internal of a host of group [proxies], which is neither an IP address nor a hostname",
+				"line 4: invalid address -proxy-2 of a host of group [proxies], which is neither an IP address nor a hostname",
+				"line 5: host PROXY-0.example.internal of group [proxies] is already listed at line 2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inventory, parseErrors := Parse(tt.content)
+			require.Empty(t, parseErrors)
+
+			validationErrors := make([]string, 0)
+			for _, validationError := range inventory.Validate(tt.minNumberOfProxies) {
+				validationErrors = append(validationErrors, validationError.Error())
+			}
+			require.Equal(t, tt.expectedErrors, validationErrors)
+		})
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	require.Nil(t, CheckFile(testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"), MinNumberOfProxiesForProduction))
+	require.Nil(t, CheckFile(testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing"), MinNumberOfProxiesForLocalTesting))
+
+	inventoryPath := filepath.Join(t.TempDir(), "inventory")
+	err := os.WriteFile(inventoryPath, []byte("[proxies]\n172.18.10.300\n172.18.10.[1:3]\n"), 0644)
+	require.Nil(t, err)
+	err = CheckFile(inventoryPath, MinNumberOfProxiesForLocalTesting)
+	require.NotNil(t, err)
+	require.Equal(t, "line 2: invalid address 172.18.10.300 of a host of group [proxies], which is neither an IP address nor a hostname\n"+
+		"line 3: host ranges such as 172.18.10.[1:3] are not supported by this utility. Please list each host on its own line", err.Error())
+
+	err = CheckFile(filepath.Join(t.TempDir(), "missing"), MinNumberOfProxiesForLocalTesting)
+	require.NotNil(t, err)
+	_, isInventoryError := err.(Errors)
+	require.False(t, isInventoryError)
+}
+
+func addressesOf(hosts []*Host) []string {
+	addresses := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addresses = append(addresses, host.Address())
+	}
+	return addresses
+}
+
+func TestIsHostname(t *testing.T) {
+	tests := []struct {
+		value      string
+		isHostname bool
+	}{
+		{"proxy-0", true},
+		{"proxy-0.example.internal", true},
+		{"ip-172-18-10-32.ec2.internal", true},
+		{"0proxy.example.internal", true},
+		{"", false},
+		{"proxy-0.", false},
+		{"proxy-0-.example.internal", false},
+		{"proxy_0.example.internal", false},
+		{"172.18.10.300", false},
+		{"12345", false},
+		{strings.Repeat("a", 64) + ".example.internal", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			require.Equal(t, tt.isHostname, IsHostname(tt.value))
+		})
+	}
+}
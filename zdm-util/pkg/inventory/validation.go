@@ -0,0 +1,128 @@
+package inventory
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const maxHostnameLength = 253
+
+// hostnameLabelRegexp matches a label of a hostname as defined by RFC 1123: letters, digits and hyphens, not starting or ending with a hyphen
+var hostnameLabelRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// Validate checks that the inventory can be used by the playbooks: the proxies group must have at least the specified number of hosts,
+// the monitoring group is optional and can have at most one host, and the address of each of these hosts must be a valid IP address or hostname
+// and listed only once in its group. It returns an error for each problem found
+func (i *Inventory) Validate(minNumberOfProxies int) Errors {
+	validationErrors := make(Errors, 0)
+
+	proxiesGroup := i.Group(ProxiesGroupName)
+	if proxiesGroup == nil {
+		validationErrors = append(validationErrors, &Error{
+			Message: fmt.Sprintf("no [%v] group was found. The playbooks deploy the proxies to the hosts of this group", ProxiesGroupName),
+		})
+	} else {
+		proxyHosts := i.HostsOf(ProxiesGroupName)
+		if len(proxyHosts) < minNumberOfProxies {
+			validationErrors = append(validationErrors, &Error{
+				Line:    proxiesGroup.Line,
+				Message: fmt.Sprintf("the [%v] group has %v hosts, but at least %v are required", ProxiesGroupName, len(proxyHosts), minNumberOfProxies),
+			})
+		}
+		validationErrors = append(validationErrors, checkHosts(ProxiesGroupName, proxyHosts)...)
+	}
+
+	if monitoringGroup := i.Group(MonitoringGroupName); monitoringGroup != nil {
+		monitoringHosts := i.HostsOf(MonitoringGroupName)
+		if len(monitoringHosts) > 1 {
+			validationErrors = append(validationErrors, &Error{
+				Line:    monitoringGroup.Line,
+				Message: fmt.Sprintf("the [%v] group has %v hosts, but the playbooks only deploy the monitoring stack to one host", MonitoringGroupName, len(monitoringHosts)),
+			})
+		}
+		validationErrors = append(validationErrors, checkHosts(MonitoringGroupName, monitoringHosts)...)
+	}
+	return validationErrors
+}
+
+// checkHosts returns an error for each host of the group whose address is neither an IP address nor a hostname, or is listed more than once
+func checkHosts(groupName string, hosts []*Host) Errors {
+	validationErrors := make(Errors, 0)
+	linesByAddress := make(map[string]int)
+	for _, host := range hosts {
+		address := host.Address()
+		if isPlaceholder(address) {
+			validationErrors = append(validationErrors, &Error{
+				Line:    host.Line,
+				Message: fmt.Sprintf("%v is a placeholder from the example inventory. Please replace it with the private IP address of the instance", address),
+			})
+			continue
+		}
+		ip := net.ParseIP(address)
+		if ip == nil && !IsHostname(address) {
+			validationErrors = append(validationErrors, &Error{
+				Line:    host.Line,
+				Message: fmt.Sprintf("invalid address %v of a host of group [%v], which is neither an IP address nor a hostname", address, groupName),
+			})
+			continue
+		}
+		// the same address can be written in different forms, e.g. fd00::5 and fd00:0::5, and hostnames are case-insensitive
+		canonicalAddress := strings.ToLower(address)
+		if ip != nil {
+			canonicalAddress = ip.String()
+		}
+		if otherLine, found := linesByAddress[canonicalAddress]; found {
+			validationErrors = append(validationErrors, &Error{
+				Line:    host.Line,
+				Message: fmt.Sprintf("host %v of group [%v] is already listed at line %v", address, groupName, otherLine),
+			})
+			continue
+		}
+		linesByAddress[canonicalAddress] = host.Line
+	}
+	return validationErrors
+}
+
+// IsHostname returns whether the value is a hostname as defined by RFC 1123, e.g. proxy-0 or proxy-0.example.internal.
+// As in RFC 3696, the last label cannot be all-numeric, so that a malformed IPv4 address such as 172.18.10.300 is not taken for a hostname
+func IsHostname(value string) bool {
+	if value == "" || len(value) > maxHostnameLength {
+		return false
+	}
+	labels := strings.Split(value, ".")
+	for _, label := range labels {
+		if !hostnameLabelRegexp.MatchString(label) {
+			return false
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+// isPlaceholder returns whether the address is a placeholder of the example inventory, e.g. <private_IP_address_of_proxy_instance_0>
+func isPlaceholder(address string) bool {
+	return strings.HasPrefix(address, "<") && strings.HasSuffix(address, ">")
+}
+
+// CheckFile parses the inventory file and validates it, see Validate. It returns an error of type Errors listing all the problems found
+// in order of line, or another error if the file cannot be read
+func CheckFile(path string, minNumberOfProxies int) error {
+	inventory, err := ParseFile(path)
+	if inventory == nil {
+		return err
+	}
+	problems := make(Errors, 0)
+	if parseErrors, ok := err.(Errors); ok {
+		problems = append(problems, parseErrors...)
+	}
+	problems = append(problems, inventory.Validate(minNumberOfProxies)...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TranscriptFileNamePrefix = "zdm_util_transcript_"
+	transcriptFileTimeLayout = "20060102_150405"
+	transcriptLineTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+	redactedValue            = "<redacted>"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	// inputLevel marks the answers to prompts, which are only written to the transcript as the terminal already echoes them
+	inputLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARN"
+	case ErrorLevel:
+		return "ERROR"
+	case inputLevel:
+		return "INPUT"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// consolePrefix is prepended to warnings and errors printed to the console, so that they stand out from the other messages
+func (l Level) consolePrefix() string {
+	switch l {
+	case WarnLevel:
+		return "WARNING: "
+	case ErrorLevel:
+		return "ERROR: "
+	default:
+		return ""
+	}
+}
+
+var (
+	// secretAssignmentRegex matches key=value and key: value pairs whose key suggests that the value is a secret
+	secretAssignmentRegex = regexp.MustCompile(`(?i)([\w.-]*(?:password|passwd|passphrase|secret|token|api_?key|private_?key|credential)[\w.-]*["']?\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`)
+	privateKeyBlockRegex  = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// RedactSecrets replaces the values of secret settings and any private key material in the specified text
+func RedactSecrets(text string) string {
+	text = privateKeyBlockRegex.ReplaceAllString(text, redactedValue)
+	return secretAssignmentRegex.ReplaceAllString(text, "${1}"+redactedValue)
+}
+
+// Logger writes messages to the console, filtered by level, and to the transcript file if one has been started.
+// Messages are written to the console as they are, whereas each transcript line is prefixed with a timestamp and the level
+type Logger struct {
+	mu           sync.Mutex
+	consoleLevel Level
+	// console is resolved on every write, so that the default console follows os.Stdout if it is redirected after the logger is created
+	console    func() io.Writer
+	transcript io.WriteCloser
+	// transcriptAtLineStart is true when the next transcript write starts a new line, and therefore needs a prefix
+	transcriptAtLineStart bool
+	now                   func() time.Time
+}
+
+func newLogger(console func() io.Writer) *Logger {
+	return &Logger{
+		consoleLevel:          InfoLevel,
+		console:               console,
+		transcriptAtLineStart: true,
+		now:                   time.Now,
+	}
+}
+
+var defaultLogger = newLogger(func() io.Writer { return os.Stdout })
+
+// SetVerbose enables or disables the debug messages on the console. The transcript always includes them
+func SetVerbose(verbose bool) {
+	defaultLogger.setVerbose(verbose)
+}
+
+// SetConsole makes the console messages go to the specified writer instead of os.Stdout, e.g. to keep stdout for machine-readable output
+func SetConsole(console io.Writer) {
+	defaultLogger.setConsole(func() io.Writer { return console })
+}
+
+// IsVerbose returns true if debug messages are printed to the console
+func IsVerbose() bool {
+	return defaultLogger.isVerbose()
+}
+
+// StartTranscript creates a timestamped transcript file in the specified directory, to which all subsequent messages are written.
+// It returns the path of the file
+func StartTranscript(dirPath string) (string, error) {
+	transcriptFilePath := filepath.Join(dirPath, TranscriptFileNamePrefix+time.Now().Format(transcriptFileTimeLayout)+".log")
+	transcriptFile, err := os.OpenFile(transcriptFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return "", fmt.Errorf("could not create the transcript file %v: %v", transcriptFilePath, err)
+	}
+	defaultLogger.setTranscript(transcriptFile)
+	return transcriptFilePath, nil
+}
+
+// CloseTranscript closes the transcript file, if one was started
+func CloseTranscript() {
+	defaultLogger.setTranscript(nil)
+}
+
+func Debugf(format string, args ...any) {
+	defaultLogger.log(DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func Infof(format string, args ...any) {
+	defaultLogger.log(InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func Warnf(format string, args ...any) {
+	defaultLogger.log(WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func Errorf(format string, args ...any) {
+	defaultLogger.log(ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// Info logs its operands like fmt.Print
+func Info(args ...any) {
+	defaultLogger.log(InfoLevel, fmt.Sprint(args...))
+}
+
+// Infoln logs its operands like fmt.Println
+func Infoln(args ...any) {
+	defaultLogger.log(InfoLevel, fmt.Sprintln(args...))
+}
+
+// Input records the answer to a prompt in the transcript. It is not printed to the console, where the user typed it
+func Input(value string) {
+	defaultLogger.log(inputLevel, value+"\n")
+}
+
+// Writer returns a writer that logs everything written to it at the specified level, to stream the output of external processes
+func Writer(level Level) io.Writer {
+	return &levelWriter{logger: defaultLogger, level: level}
+}
+
+type levelWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.logger.log(w.level, string(p))
+	return len(p), nil
+}
+
+func (l *Logger) setVerbose(verbose bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if verbose {
+		l.consoleLevel = DebugLevel
+	} else {
+		l.consoleLevel = InfoLevel
+	}
+}
+
+func (l *Logger) setConsole(console func() io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.console = console
+}
+
+func (l *Logger) isVerbose() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.consoleLevel == DebugLevel
+}
+
+func (l *Logger) setTranscript(transcript io.WriteCloser) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.transcript != nil {
+		if !l.transcriptAtLineStart {
+			_, _ = io.WriteString(l.transcript, "\n")
+		}
+		_ = l.transcript.Close()
+	}
+	l.transcript = transcript
+	l.transcriptAtLineStart = true
+}
+
+func (l *Logger) log(level Level, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level >= l.consoleLevel && level != inputLevel {
+		_, _ = io.WriteString(l.console(), addConsolePrefix(level, message))
+	}
+	if l.transcript != nil {
+		l.writeToTranscript(level, RedactSecrets(message))
+	}
+}
+
+// addConsolePrefix adds the level prefix after any leading line breaks, so that it is on the same line as the message
+func addConsolePrefix(level Level, message string) string {
+	prefix := level.consolePrefix()
+	if prefix == "" {
+		return message
+	}
+	trimmedMessage := strings.TrimLeft(message, "\n")
+	return message[:len(message)-len(trimmedMessage)] + prefix + trimmedMessage
+}
+
+func (l *Logger) writeToTranscript(level Level, message string) {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(message, "\n") {
+		if line == "" {
+			continue
+		}
+		if l.transcriptAtLineStart {
+			sb.WriteString(fmt.Sprintf("%v %-5v ", l.now().Format(transcriptLineTimeLayout), level))
+		}
+		sb.WriteString(line)
+		l.transcriptAtLineStart = strings.HasSuffix(line, "\n")
+	}
+	_, _ = io.WriteString(l.transcript, sb.String())
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type Format string
+
+const (
+	TextFormat Format = "text"
+	JsonFormat Format = "json"
+)
+
+type StepStatus string
+
+const (
+	StepSucceeded StepStatus = "succeeded"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+const (
+	stepEventType   = "step"
+	resultEventType = "result"
+)
+
+// Ids holds the identifiers of the resources involved in a step, e.g. containerId or imageId
+type Ids map[string]string
+
+// StepEvent is emitted when a step completes
+type StepEvent struct {
+	Event      string     `json:"event"`
+	Step       string     `json:"step"`
+	Status     StepStatus `json:"status"`
+	DurationMs int64      `json:"durationMs"`
+	Ids        Ids        `json:"ids,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// resultEvent wraps the final result, so that it can be told apart from the step events
+type resultEvent struct {
+	Event  string      `json:"event"`
+	Result interface{} `json:"result"`
+}
+
+// Reporter emits machine-readable events, one JSON document per line, when the JSON format is selected.
+// In text format it does nothing, as the human-readable messages are printed by each operation.
+// All methods can be safely called on a nil Reporter
+type Reporter struct {
+	format  Format
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewReporter(format Format, w io.Writer) *Reporter {
+	return &Reporter{
+		format:  format,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// ParseFormat validates the output format specified by the user
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case TextFormat, JsonFormat:
+		return Format(s), nil
+	default:
+		return "", fmt.Errorf("invalid output format %v. Valid formats are: %v, %v", s, TextFormat, JsonFormat)
+	}
+}
+
+func (r *Reporter) IsJson() bool {
+	return r != nil && r.format == JsonFormat
+}
+
+// Step measures the duration of a step and reports its outcome
+type Step struct {
+	reporter  *Reporter
+	name      string
+	startTime time.Time
+}
+
+func (r *Reporter) StartStep(name string) *Step {
+	return &Step{
+		reporter:  r,
+		name:      name,
+		startTime: time.Now(),
+	}
+}
+
+// Done reports the step as failed if err is not nil, otherwise as succeeded
+func (s *Step) Done(err error, ids Ids) {
+	status := StepSucceeded
+	if err != nil {
+		status = StepFailed
+	}
+	s.report(status, ids, err)
+}
+
+func (s *Step) Skipped(ids Ids) {
+	s.report(StepSkipped, ids, nil)
+}
+
+func (s *Step) report(status StepStatus, ids Ids, err error) {
+	event := &StepEvent{
+		Event:      stepEventType,
+		Step:       s.name,
+		Status:     status,
+		DurationMs: time.Since(s.startTime).Milliseconds(),
+		Ids:        ids,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.reporter.emit(event)
+}
+
+// Result emits the final result of the operation
+func (r *Reporter) Result(result interface{}) {
+	r.emit(&resultEvent{
+		Event:  resultEventType,
+		Result: result,
+	})
+}
+
+func (r *Reporter) emit(event interface{}) {
+	if !r.IsJson() {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_ = r.encoder.Encode(event)
+}
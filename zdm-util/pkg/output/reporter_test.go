@@ -0,0 +1,62 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestReporter_JsonFormat(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewReporter(JsonFormat, &buffer)
+
+	reporter.StartStep("container-create").Done(nil, Ids{"containerId": "abc"})
+	reporter.StartStep("container-start").Skipped(nil)
+	reporter.StartStep("container-init").Done(errors.New("exit code 1"), nil)
+	reporter.Result(map[string]string{"containerId": "abc"})
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Equal(t, 4, len(lines))
+
+	expectedSteps := []StepEvent{
+		{Event: stepEventType, Step: "container-create", Status: StepSucceeded, Ids: Ids{"containerId": "abc"}},
+		{Event: stepEventType, Step: "container-start", Status: StepSkipped},
+		{Event: stepEventType, Step: "container-init", Status: StepFailed, Error: "exit code 1"},
+	}
+	for i, expectedStep := range expectedSteps {
+		var actualStep StepEvent
+		require.Nil(t, json.Unmarshal([]byte(lines[i]), &actualStep))
+		actualStep.DurationMs = 0
+		require.Equal(t, expectedStep, actualStep)
+	}
+
+	var actualResult map[string]interface{}
+	require.Nil(t, json.Unmarshal([]byte(lines[3]), &actualResult))
+	require.Equal(t, resultEventType, actualResult["event"])
+	require.Equal(t, map[string]interface{}{"containerId": "abc"}, actualResult["result"])
+}
+
+func TestReporter_TextFormatAndNilReporterEmitNothing(t *testing.T) {
+	var buffer bytes.Buffer
+	reporter := NewReporter(TextFormat, &buffer)
+	reporter.StartStep("ping").Done(nil, nil)
+	reporter.Result("done")
+	require.Equal(t, 0, buffer.Len())
+
+	var nilReporter *Reporter
+	nilReporter.StartStep("ping").Done(nil, nil)
+	nilReporter.Result("done")
+	require.False(t, nilReporter.IsJson())
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("json")
+	require.Nil(t, err)
+	require.Equal(t, JsonFormat, format)
+
+	_, err = ParseFormat("yaml")
+	require.NotNil(t, err)
+}
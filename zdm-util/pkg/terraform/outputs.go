@@ -0,0 +1,154 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+)
+
+// names of the outputs of the deployment roots of the Terraform automation, see terraform/aws/*-deployment-root-aws/outputs.tf
+const (
+	ProxyPrivateIpsOutputName     = "zdm_proxy_instance_private_ips"
+	MonitoringPrivateIpOutputName = "zdm_monitoring_private_ip"
+	JumphostPublicIpOutputName    = "zdm_jumphost_public_ip"
+	KeypairNameOutputName         = "zdm_keypair_name"
+	PublicKeyLocalPathOutputName  = "zdm_public_key_local_path"
+	LinuxUserOutputName           = "zdm_linux_user"
+)
+
+const (
+	terraformOutputCommand = "terraform output -json"
+
+	// defaultPublicKeyLocalPath is the default directory of the key pair in the Terraform automation
+	defaultPublicKeyLocalPath = "~/.ssh"
+)
+
+// Outputs are the details of the infrastructure provisioned by the Terraform automation, as read from the output of terraform output -json.
+// As in the SSH configuration generated by the Terraform automation, the monitoring instance is also the jumphost
+type Outputs struct {
+	ProxyPrivateIpAddresses    []string `json:"proxyPrivateIpAddresses"`
+	MonitoringPrivateIpAddress string   `json:"monitoringPrivateIpAddress,omitempty"`
+	JumphostPublicIpAddress    string   `json:"jumphostPublicIpAddress,omitempty"`
+	// SshKeyPath is the path of the private key of the key pair of the instances, which is only known if the key pair name is output
+	SshKeyPath string `json:"sshKeyPath,omitempty"`
+	// RemoteUser is the default user of the Linux distribution of the instances, e.g. ubuntu or rocky
+	RemoteUser string `json:"remoteUser,omitempty"`
+}
+
+// output is an output of terraform output -json, e.g. {"sensitive": false, "type": "string", "value": "172.18.10.5"}
+type output struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// ReadOutputsFile reads the output of terraform output -json from the file, see ParseOutputs
+func ReadOutputsFile(filePath string) (*Outputs, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the Terraform output file %v: %v", filePath, err)
+	}
+	return ParseOutputs(content)
+}
+
+// ParseOutputs reads the infrastructure details from the output of terraform output -json, run in a deployment root of the Terraform automation.
+// The private IP addresses of the proxies are required, whereas the other outputs are optional
+func ParseOutputs(content []byte) (*Outputs, error) {
+	var rawOutputs map[string]output
+	if err := json.Unmarshal(content, &rawOutputs); err != nil {
+		return nil, fmt.Errorf("the Terraform output is not valid JSON, please provide the output of %v: %v", terraformOutputCommand, err)
+	}
+
+	outputs := &Outputs{}
+	if err := unmarshalOutput(rawOutputs, ProxyPrivateIpsOutputName, &outputs.ProxyPrivateIpAddresses); err != nil {
+		return nil, err
+	}
+	if len(outputs.ProxyPrivateIpAddresses) == 0 {
+		return nil, fmt.Errorf("the Terraform output has no %v, please provide the output of %v in the deployment root that provisioned the proxies",
+			ProxyPrivateIpsOutputName, terraformOutputCommand)
+	}
+	if err := unmarshalOutput(rawOutputs, MonitoringPrivateIpOutputName, &outputs.MonitoringPrivateIpAddress); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOutput(rawOutputs, JumphostPublicIpOutputName, &outputs.JumphostPublicIpAddress); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOutput(rawOutputs, LinuxUserOutputName, &outputs.RemoteUser); err != nil {
+		return nil, err
+	}
+
+	var keypairName, publicKeyLocalPath string
+	if err := unmarshalOutput(rawOutputs, KeypairNameOutputName, &keypairName); err != nil {
+		return nil, err
+	}
+	if err := unmarshalOutput(rawOutputs, PublicKeyLocalPathOutputName, &publicKeyLocalPath); err != nil {
+		return nil, err
+	}
+	if keypairName != "" {
+		if publicKeyLocalPath == "" {
+			publicKeyLocalPath = defaultPublicKeyLocalPath
+		}
+		// the same path as the IdentityFile of the SSH configuration generated by the Terraform automation
+		outputs.SshKeyPath = path.Join(publicKeyLocalPath, keypairName)
+	}
+	return outputs, nil
+}
+
+// unmarshalOutput sets the value of the output with the specified name, leaving it unchanged if the output is missing or null
+func unmarshalOutput(rawOutputs map[string]output, name string, value interface{}) error {
+	rawOutput, found := rawOutputs[name]
+	if !found || len(rawOutput.Value) == 0 || string(rawOutput.Value) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(rawOutput.Value, value); err != nil {
+		return fmt.Errorf("invalid value %v of the Terraform output %v: %v", string(rawOutput.Value), name, err)
+	}
+	return nil
+}
+
+// ProxyIpAddressPrefix returns the longest prefix common to the private IP addresses of the proxies, e.g. 172.18.* for 172.18.10.5 and 172.18.11.6,
+// or an empty string if they have no common prefix or are not IPv4 addresses, which are the only ones provisioned by the Terraform automation
+func (o *Outputs) ProxyIpAddressPrefix() string {
+	var commonOctets []string
+	for i, ipAddress := range o.ProxyPrivateIpAddresses {
+		ip := net.ParseIP(ipAddress).To4()
+		if ip == nil {
+			return ""
+		}
+		octets := strings.Split(ip.String(), ".")
+		if i == 0 {
+			// the prefix is made of at most three octets followed by an asterisk
+			commonOctets = octets[:3]
+			continue
+		}
+		numberOfCommonOctets := 0
+		for numberOfCommonOctets < len(commonOctets) && commonOctets[numberOfCommonOctets] == octets[numberOfCommonOctets] {
+			numberOfCommonOctets++
+		}
+		commonOctets = commonOctets[:numberOfCommonOctets]
+	}
+	if len(commonOctets) == 0 {
+		return ""
+	}
+	return strings.Join(commonOctets, ".") + ".*"
+}
+
+// PropertyLayer returns the configuration properties that can be derived from the outputs. The Ansible inventory is not part of it,
+// as it is generated from the addresses of the proxies and of the monitoring instance
+func (o *Outputs) PropertyLayer() config.PropertyLayer {
+	properties := map[string]string{
+		config.SshKeyPathOnHostPropertyName:     o.SshKeyPath,
+		config.ProxyIpAddressPrefixPropertyName: o.ProxyIpAddressPrefix(),
+		config.RemoteUserPropertyName:           o.RemoteUser,
+	}
+	if o.JumphostPublicIpAddress != "" {
+		properties[config.JumphostPublicIpAddressPropertyName] = o.JumphostPublicIpAddress
+		properties[config.JumphostPrivateIpAddressPropertyName] = o.MonitoringPrivateIpAddress
+	}
+	return config.PropertyLayer{
+		Source:     config.TerraformSource,
+		Properties: properties,
+	}
+}
@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+)
+
+func TestParseOutputs(t *testing.T) {
+	tests := []struct {
+		name                 string
+		content              string
+		expectedOutputs      *Outputs
+		expectedErrorMessage string
+	}{
+		{
+			name: "all outputs",
+			content: `{
+  "zdm_jumphost_public_ip": {"sensitive": false, "type": "string", "value": "203.0.113.10"},
+  "zdm_keypair_name": {"sensitive": false, "type": "string", "value": "zdm-key"},
+  "zdm_linux_user": {"sensitive": false, "type": "string", "value": "rocky"},
+  "zdm_monitoring_private_ip": {"sensitive": false, "type": "string", "value": "172.18.100.45"},
+  "zdm_monitoring_public_ip": {"sensitive": false, "type": "string", "value": "203.0.113.10"},
+  "zdm_proxy_instance_private_ips": {"sensitive": false, "type": ["tuple", ["string", "string", "string"]], "value": ["172.18.10.32", "172.18.11.47", "172.18.12.8"]},
+  "zdm_public_key_local_path": {"sensitive": false, "type": "string", "value": "/home/me/keys"},
+  "zdm_vpc_id": {"sensitive": false, "type": "string", "value": "vpc-0123456789"}
+}`,
+			expectedOutputs: &Outputs{
+				ProxyPrivateIpAddresses:    []string{"172.18.10.32", "172.18.11.47", "172.18.12.8"},
+				MonitoringPrivateIpAddress: "172.18.100.45",
+				JumphostPublicIpAddress:    "203.0.113.10",
+				SshKeyPath:                 "/home/me/keys/zdm-key",
+				RemoteUser:                 "rocky",
+			},
+		},
+		{
+			name:    "only the proxies, key pair in the default directory",
+			content: `{"zdm_proxy_instance_private_ips": {"value": ["172.18.10.32"]}, "zdm_keypair_name": {"value": "zdm-key"}, "zdm_jumphost_public_ip": {"value": null}}`,
+			expectedOutputs: &Outputs{
+				ProxyPrivateIpAddresses: []string{"172.18.10.32"},
+				SshKeyPath:              "~/.ssh/zdm-key",
+			},
+		},
+		{
+			name:                 "no proxies",
+			content:              `{"zdm_proxy_instance_private_ips": {"value": []}, "zdm_vpc_id": {"value": "vpc-0123456789"}}`,
+			expectedErrorMessage: "the Terraform output has no zdm_proxy_instance_private_ips, please provide the output of terraform output -json in the deployment root that provisioned the proxies",
+		},
+		{
+			name:                 "invalid value",
+			content:              `{"zdm_proxy_instance_private_ips": {"value": "172.18.10.32"}}`,
+			expectedErrorMessage: "invalid value \"172.18.10.32\" of the Terraform output zdm_proxy_instance_private_ips: json: cannot unmarshal string into Go value of type []string",
+		},
+		{
+			name:                 "output of terraform output without -json",
+			content:              `zdm_vpc_id = "vpc-0123456789"`,
+			expectedErrorMessage: "the Terraform output is not valid JSON, please provide the output of terraform output -json: invalid character 'z' looking for beginning of value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, err := ParseOutputs([]byte(tt.content))
+			if tt.expectedErrorMessage != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.expectedErrorMessage, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedOutputs, outputs)
+		})
+	}
+}
+
+func TestOutputs_ProxyIpAddressPrefix(t *testing.T) {
+	tests := []struct {
+		name             string
+		ipAddresses      []string
+		expectedIpPrefix string
+	}{
+		{"single proxy", []string{"172.18.10.32"}, "172.18.10.*"},
+		{"proxies in different subnets", []string{"172.18.10.32", "172.18.11.47", "172.18.12.8"}, "172.18.*"},
+		{"proxies in different networks", []string{"10.0.1.5", "172.18.11.47"}, ""},
+		{"IPv6 addresses", []string{"fd00::5", "fd00::6"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs := &Outputs{ProxyPrivateIpAddresses: tt.ipAddresses}
+			require.Equal(t, tt.expectedIpPrefix, outputs.ProxyIpAddressPrefix())
+		})
+	}
+}
+
+func TestOutputs_PropertyLayer(t *testing.T) {
+	outputs := &Outputs{
+		ProxyPrivateIpAddresses:    []string{"172.18.10.32", "172.18.11.47"},
+		MonitoringPrivateIpAddress: "172.18.100.45",
+		JumphostPublicIpAddress:    "203.0.113.10",
+		SshKeyPath:                 "/home/me/keys/zdm-key",
+		RemoteUser:                 "centos",
+	}
+	containerConfig := config.NewEmptyContainerInitConfig()
+	containerConfig.ApplyLayers(outputs.PropertyLayer())
+	require.Equal(t, map[string]string{
+		config.SshKeyPathOnHostPropertyName:         "/home/me/keys/zdm-key",
+		config.ProxyIpAddressPrefixPropertyName:     "172.18.*",
+		config.JumphostPublicIpAddressPropertyName:  "203.0.113.10",
+		config.JumphostPrivateIpAddressPropertyName: "172.18.100.45",
+		config.RemoteUserPropertyName:               "centos",
+	}, containerConfig.Properties())
+	require.Equal(t, config.TerraformSource, containerConfig.Sources[config.JumphostPublicIpAddressPropertyName])
+
+	outputs.JumphostPublicIpAddress = ""
+	require.NotContains(t, outputs.PropertyLayer().Properties, config.JumphostPrivateIpAddressPropertyName)
+}
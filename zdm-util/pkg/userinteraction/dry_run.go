@@ -0,0 +1,68 @@
+package userinteraction
+
+import (
+	"fmt"
+	"path/filepath"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// PlannedFileWrite is a file that would have been written on the host, had this not been a dry run
+type PlannedFileWrite struct {
+	// Path is the absolute path of the file
+	Path        string
+	Description string
+}
+
+// SetDryRun chooses whether the files that the configuration requires, i.e. the generated Ansible inventory and the configuration file,
+// are only planned rather than written, see PlannedFileWrites. By default, they are written
+func (o *InteractionOrchestrator) SetDryRun(dryRun bool) {
+	o.dryRun = dryRun
+}
+
+// PlannedFileWrites returns, in order, the files that would have been written while creating the configuration in a dry run
+func (o *InteractionOrchestrator) PlannedFileWrites() []PlannedFileWrite {
+	return o.plannedFileWrites
+}
+
+func (o *InteractionOrchestrator) planFileWrite(filePath string, format string, args ...interface{}) {
+	absolutePath, err := filepath.Abs(filePath)
+	if err != nil {
+		absolutePath = filePath
+	}
+	o.plannedFileWrites = append(o.plannedFileWrites, PlannedFileWrite{Path: absolutePath, Description: fmt.Sprintf(format, args...)})
+}
+
+// writeInventoryFile creates the Ansible inventory file with the provided addresses, see populateInventoryFile, unless this is a dry run
+func (o *InteractionOrchestrator) writeInventoryFile(filePath string, proxyIpAddresses []string, monitoringIpAddress string, hostnames map[string]string) error {
+	if !o.dryRun {
+		return populateInventoryFile(filePath, proxyIpAddresses, monitoringIpAddress, o.containerConfig, hostnames)
+	}
+	monitoringDescription := "no monitoring host"
+	if monitoringIpAddress != "" {
+		monitoringDescription = "monitoring host " + monitoringIpAddress
+	}
+	o.planFileWrite(filePath, "Write the Ansible inventory %v with proxies %v and %v", filePath, proxyIpAddresses, monitoringDescription)
+	logger.Infof("Dry run: the Ansible inventory file %v is not written \n", filePath)
+	return nil
+}
+
+// persistConfiguration saves the current configuration to the configuration file, see persistCurrentConfigToFile, unless this is a dry run.
+// A failure is not an error, as the configuration can still be used without being persisted
+func (o *InteractionOrchestrator) persistConfiguration() {
+	if o.dryRun {
+		profileDescription := ""
+		if o.containerConfig.Profile != config.DefaultProfile {
+			profileDescription = ", in profile " + o.containerConfig.Profile
+		}
+		o.planFileWrite(DefaultConfigurationFilePath, "Write the configuration to the configuration file %v%v", DefaultConfigurationFilePath, profileDescription)
+		logger.Infof("Dry run: the configuration file %v is not written \n", DefaultConfigurationFilePath)
+		return
+	}
+	err := persistCurrentConfigToFile(o.containerConfig, o.configurationFileFormat())
+	if err != nil {
+		logger.Infof("The configuration file %v could not be created due to %v. This utility will continue without persisting its configuration. \n", DefaultConfigurationFilePath, err)
+	} else {
+		logger.Infof("Configuration successfully written to file %v \n", DefaultConfigurationFilePath)
+	}
+}
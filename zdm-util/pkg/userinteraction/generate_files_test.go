@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
 	"zdm-proxy-automation/zdm-util/pkg/testutils"
 )
 
@@ -51,13 +52,75 @@ func TestPopulateInventoryFile(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := populateInventoryFile(testInventoryFilePath, tt.proxyIpAddresses, tt.monitoringIpAddress)
+			err := populateInventoryFile(testInventoryFilePath, tt.proxyIpAddresses, tt.monitoringIpAddress, config.NewEmptyContainerInitConfig(), nil)
 			require.Nil(t, err, "Error while populating the inventory file")
 			compareGeneratedInventoryFileAndCleanUpForTests(testInventoryFilePath, tt.proxyIpAddresses, tt.monitoringIpAddress, t)
 		})
 	}
 }
 
+func TestPopulateInventoryFile_Ipv6(t *testing.T) {
+	err := populateInventoryFile(testInventoryFilePath, []string{"FD00:10:0:0:0:0:0:32", "fd00:10::58", "fd00:0010:0:0:1:0:0:47"}, "fd00:10:0:0:0:0:100:45", config.NewEmptyContainerInitConfig(), nil)
+	require.Nil(t, err, "Error while populating the inventory file")
+	compareGeneratedInventoryFileAndCleanUpForTests(testInventoryFilePath, []string{"fd00:10::32", "fd00:10::58", "fd00:10::1:0:0:47"}, "fd00:10::100:45", t)
+}
+
+func TestPopulateInventoryFile_RemoteUserAndHostOverrides(t *testing.T) {
+	containerConfig := &config.ContainerInitConfig{
+		RemoteUser:    "rocky",
+		HostOverrides: config.ParseHostOverrides("monitoring=centos,proxies=:2222,172.18.11.58=ec2-user:22"),
+	}
+	err := populateInventoryFile(testInventoryFilePath, []string{"172.18.10.32", "172.18.11.58"}, "172.18.100.45", containerConfig, nil)
+	require.Nil(t, err, "Error while populating the inventory file")
+	defer os.Remove(testInventoryFilePath)
+
+	content, err := os.ReadFile(testInventoryFilePath)
+	require.Nil(t, err)
+	require.Equal(t, InventoryHeadingForProxyGroup+"\n"+
+		"172.18.10.32 ansible_connection=ssh ansible_user=rocky ansible_port=2222\n"+
+		"172.18.11.58 ansible_connection=ssh ansible_user=ec2-user ansible_port=22\n"+
+		"\n"+
+		InventoryHeadingForMonitoringGroup+"\n"+
+		"172.18.100.45 ansible_connection=ssh ansible_user=centos\n", string(content))
+}
+
+func TestPopulateInventoryFile_Hostnames(t *testing.T) {
+	containerConfig := &config.ContainerInitConfig{
+		HostOverrides: config.ParseHostOverrides("proxy-1.example.internal=rocky,Monitoring.example.internal=:2222"),
+	}
+	hostnames := map[string]string{"172.18.11.58": "proxy-1.example.internal"}
+	err := populateInventoryFile(testInventoryFilePath, []string{"proxy-0.example.internal", "172.18.11.58"}, "monitoring.example.internal", containerConfig, hostnames)
+	require.Nil(t, err, "Error while populating the inventory file")
+	defer os.Remove(testInventoryFilePath)
+
+	content, err := os.ReadFile(testInventoryFilePath)
+	require.Nil(t, err)
+	require.Equal(t, InventoryHeadingForProxyGroup+"\n"+
+		"proxy-0.example.internal ansible_connection=ssh ansible_user=ubuntu\n"+
+		"172.18.11.58 ansible_connection=ssh ansible_user=rocky # proxy-1.example.internal\n"+
+		"\n"+
+		InventoryHeadingForMonitoringGroup+"\n"+
+		"monitoring.example.internal ansible_connection=ssh ansible_user=ubuntu ansible_port=2222\n", string(content))
+
+	ansibleInventory, err := inventory.ParseFile(testInventoryFilePath)
+	require.Nil(t, err)
+	require.Equal(t, "172.18.11.58", ansibleInventory.HostsOf(inventory.ProxiesGroupName)[1].Address())
+}
+
+func TestResolveHostnames(t *testing.T) {
+	addresses, hostnames, err := resolveHostnames([]string{"172.18.10.32", "localhost", ""}, "127.0.0.*")
+	require.Nil(t, err)
+	require.Equal(t, []string{"172.18.10.32", "127.0.0.1", ""}, addresses)
+	require.Equal(t, map[string]string{"127.0.0.1": "localhost"}, hostnames)
+
+	_, _, err = resolveHostnames([]string{"proxy-0.example.invalid"}, "172.18.*")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "the hostname proxy-0.example.invalid could not be resolved")
+
+	require.True(t, containsHostname([]string{"172.18.10.32", "localhost"}))
+	require.False(t, containsHostname([]string{"172.18.10.32", "fd00::5", ""}))
+}
+
 func compareGeneratedInventoryFileAndCleanUpForTests(filePath string, proxyIpAddresses []string, monitoringAddress string, t *testing.T) {
 	testutils.CheckFileExistsForTests(filePath, t)
 
@@ -72,8 +135,7 @@ func compareGeneratedInventoryFileAndCleanUpForTests(filePath string, proxyIpAdd
 	expectedIndexOfLastProxyAddress := len(proxyIpAddresses)
 	expectedIndexOfMonitoringGroupHeading := expectedIndexOfLastProxyAddress + 1
 	expectedIndexOfMonitoringAddress := expectedIndexOfMonitoringGroupHeading + 1
-	inventoryAddressLineSuffix, err := getInventoryAddressLineSuffix()
-	require.Nil(t, err, "Error retrieving the inventory address line suffix")
+	inventoryAddressLineSuffix := "ansible_connection=ssh ansible_user=" + config.DefaultRemoteUser
 
 	scanner := bufio.NewScanner(file)
 
@@ -114,54 +176,44 @@ func TestPersistCurrentConfigToFile(t *testing.T) {
 		{
 			name: "all properties set",
 			configToPersist: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           "/home/my_path/my_key",
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: "/home/my_path/my_inventory",
-				},
+				SshKeyPathOnHost:           "/home/my_path/my_key",
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: "/home/my_path/my_inventory",
 			},
 		},
 		{
 			name: "all properties set, reverse order",
 			configToPersist: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.AnsibleInventoryPathOnHostPropertyName: "/home/my_path/my_inventory",
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.SshKeyPathOnHostPropertyName:           "/home/my_path/my_key",
-				},
+				AnsibleInventoryPathOnHost: "/home/my_path/my_inventory",
+				ProxyIpAddressPrefix:       "172.18.*",
+				SshKeyPathOnHost:           "/home/my_path/my_key",
 			},
 		},
 		{
 			name: "only ssh key",
 			configToPersist: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName: "/home/my_path/my_key",
-				},
+				SshKeyPathOnHost: "/home/my_path/my_key",
 			},
 		},
 		{
 			name: "only ssh key and proxy ip address prefix",
 			configToPersist: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:     "/home/my_path/my_key",
-					config.ProxyIpAddressPrefixPropertyName: "172.18.*",
-				},
+				SshKeyPathOnHost:     "/home/my_path/my_key",
+				ProxyIpAddressPrefix: "172.18.*",
 			},
 		},
 		{
 			name: "only ssh key and proxy ip address prefix, reverse order",
 			configToPersist: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.ProxyIpAddressPrefixPropertyName: "172.18.*",
-					config.SshKeyPathOnHostPropertyName:     "/home/my_path/my_key",
-				},
+				ProxyIpAddressPrefix: "172.18.*",
+				SshKeyPathOnHost:     "/home/my_path/my_key",
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := persistCurrentConfigToFile(tt.configToPersist)
+			err := persistCurrentConfigToFile(tt.configToPersist, config.LegacyFileFormat)
 			require.Nil(t, err, "Error while persisting the configuration to file")
 			compareGeneratedConfigurationFileAndCleanUpForTests(DefaultConfigurationFilePath, tt.configToPersist, t)
 		})
@@ -179,7 +231,7 @@ func compareGeneratedConfigurationFileAndCleanUpForTests(filePath string, config
 
 	// build a map to track whether each expected property has been found, regardless of order
 	foundProperties := make(map[string]bool, 0)
-	for k, _ := range configToPersist.Properties {
+	for k, _ := range configToPersist.Properties() {
 		foundProperties[k] = false
 	}
 
@@ -196,16 +248,18 @@ func compareGeneratedConfigurationFileAndCleanUpForTests(filePath string, config
 			// match prefix to property name
 			// check line format
 			switch propName {
+			case config.ConfigVersionKey:
+				require.Equal(t, fmt.Sprintf("%v: %v", config.ConfigVersionKey, config.CurrentConfigVersion), line)
 			case config.SshKeyPathOnHostPropertyName:
-				expectedLine := fmt.Sprintf("%v: %v", config.SshKeyPathOnHostPropertyName, configToPersist.Properties[config.SshKeyPathOnHostPropertyName])
+				expectedLine := fmt.Sprintf("%v: %v", config.SshKeyPathOnHostPropertyName, configToPersist.SshKeyPathOnHost)
 				require.Equal(t, expectedLine, line)
 				foundProperties[config.SshKeyPathOnHostPropertyName] = true
 			case config.ProxyIpAddressPrefixPropertyName:
-				expectedLine := fmt.Sprintf("%v: %v", config.ProxyIpAddressPrefixPropertyName, configToPersist.Properties[config.ProxyIpAddressPrefixPropertyName])
+				expectedLine := fmt.Sprintf("%v: %v", config.ProxyIpAddressPrefixPropertyName, configToPersist.ProxyIpAddressPrefix)
 				require.Equal(t, expectedLine, line)
 				foundProperties[config.ProxyIpAddressPrefixPropertyName] = true
 			case config.AnsibleInventoryPathOnHostPropertyName:
-				expectedLine := fmt.Sprintf("%v: %v", config.AnsibleInventoryPathOnHostPropertyName, configToPersist.Properties[config.AnsibleInventoryPathOnHostPropertyName])
+				expectedLine := fmt.Sprintf("%v: %v", config.AnsibleInventoryPathOnHostPropertyName, configToPersist.AnsibleInventoryPathOnHost)
 				require.Equal(t, expectedLine, line)
 				foundProperties[config.AnsibleInventoryPathOnHostPropertyName] = true
 			default:
@@ -225,3 +279,33 @@ func compareGeneratedConfigurationFileAndCleanUpForTests(filePath string, config
 	}
 	require.False(t, missing, "Missing properties in the file: %v", missingKeys)
 }
+
+func TestPersistCurrentConfigToFile_KeepsOtherProfiles(t *testing.T) {
+	existingContent := "ssh_key_path_on_host: /home/my_path/my_key\n\n[staging]\nproxy_ip_address_prefix: 10.0.*\n"
+	err := os.WriteFile(DefaultConfigurationFilePath, []byte(existingContent), 0644)
+	require.Nil(t, err, "Error while writing the existing configuration file")
+	defer func() {
+		require.Nil(t, os.Remove(DefaultConfigurationFilePath))
+	}()
+
+	configToPersist := &config.ContainerInitConfig{
+		SshKeyPathOnHost:     "/home/my_path/prod_key",
+		ProxyIpAddressPrefix: "172.18.*",
+		Profile:              "prod",
+	}
+	err = persistCurrentConfigToFile(configToPersist, "")
+	require.Nil(t, err, "Error while persisting the configuration to file")
+
+	configFile, err := config.ReadConfigFile(DefaultConfigurationFilePath)
+	require.Nil(t, err)
+	require.Equal(t, config.LegacyFileFormat, configFile.Format)
+	require.Equal(t, []string{"prod", "staging"}, configFile.ProfileNames())
+	require.Equal(t, map[string]string{config.SshKeyPathOnHostPropertyName: "/home/my_path/my_key"}, configFile.Profiles[config.DefaultProfile])
+	require.Equal(t, map[string]string{config.ProxyIpAddressPrefixPropertyName: "10.0.*"}, configFile.Profiles["staging"])
+	require.Equal(t, configToPersist.Properties(), configFile.Profiles["prod"])
+}
+
+func TestInventoryFileNameForProfile(t *testing.T) {
+	require.Equal(t, DefaultAnsibleInventoryFileName, InventoryFileNameForProfile(config.DefaultProfile))
+	require.Equal(t, DefaultAnsibleInventoryFileName+"_prod", InventoryFileNameForProfile("prod"))
+}
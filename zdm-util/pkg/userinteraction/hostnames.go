@@ -0,0 +1,85 @@
+package userinteraction
+
+import (
+	"fmt"
+	"net"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// containsHostname returns whether any of the addresses is a hostname rather than an IP address
+func containsHostname(addresses []string) bool {
+	for _, address := range addresses {
+		if address != "" && net.ParseIP(address) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveHostnames resolves the hostnames among the addresses, which are returned in the same order with each hostname replaced by the IP address
+// it resolves to, together with the hostname of each of these IP addresses, so that it can be recorded in the inventory. IP addresses and empty
+// addresses are returned as they are. When a hostname resolves to several IP addresses, the first one matched by the proxy address prefix
+// is preferred, as the SSH configuration of the container gives access to the hosts it matches with the SSH key of the proxies
+func resolveHostnames(addresses []string, ipAddressPrefix string) ([]string, map[string]string, error) {
+	resolvedAddresses := make([]string, 0, len(addresses))
+	hostnames := make(map[string]string)
+	for _, address := range addresses {
+		if address == "" || net.ParseIP(address) != nil {
+			resolvedAddresses = append(resolvedAddresses, address)
+			continue
+		}
+		ipAddresses, err := net.LookupHost(address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("the hostname %v could not be resolved: %v", address, err)
+		}
+		if len(ipAddresses) == 0 {
+			return nil, nil, fmt.Errorf("the hostname %v could not be resolved: no addresses found", address)
+		}
+
+		ipAddress := ipAddresses[0]
+		isMatchedByPrefix := false
+		for _, candidate := range ipAddresses {
+			if config.MatchesIpAddressPrefix(ipAddressPrefix, candidate) {
+				ipAddress, isMatchedByPrefix = candidate, true
+				break
+			}
+		}
+		if !isMatchedByPrefix && ipAddressPrefix != "" {
+			logger.Warnf("The hostname %v resolves to %v, which is not matched by the proxy address prefix %v. "+
+				"Please make sure that the host can be accessed with an additional SSH key or through a jumphost \n", address, ipAddress, ipAddressPrefix)
+		}
+		ipAddress = config.CanonicalIPAddress(ipAddress)
+		logger.Infof("Hostname %v resolved to %v \n", address, ipAddress)
+		hostnames[ipAddress] = address
+		resolvedAddresses = append(resolvedAddresses, ipAddress)
+	}
+	return resolvedAddresses, hostnames, nil
+}
+
+// promptForHostnameResolution asks whether the hostnames among the addresses of the hosts should be resolved, to check that they exist.
+// The inventory then uses the IP addresses they resolve to, together with the hostnames, otherwise it uses the hostnames as they are,
+// which are resolved when Ansible connects to the hosts, e.g. if they can only be resolved in the network of the hosts.
+// It returns the addresses of the proxies and of the monitoring host to write in the inventory, and the hostnames of the resolved IP addresses
+func (o *InteractionOrchestrator) promptForHostnameResolution(proxyAddresses []string, monitoringAddress string) ([]string, string, map[string]string, error) {
+	addresses := append(append(make([]string, 0, len(proxyAddresses)+1), proxyAddresses...), monitoringAddress)
+	if !containsHostname(addresses) {
+		return proxyAddresses, monitoringAddress, nil, nil
+	}
+
+	ynResolve, err := YesNoPrompt("Some of your hosts are specified by hostname. Do you want to resolve their hostnames now, to check that they exist "+
+		"and write the IP addresses they resolve to in the inventory?", true, true, o.userInputReader, DefaultMaxAttempts)
+	if err != nil || !ynResolve {
+		logger.Infoln()
+		logger.Infoln("The hostnames will be written as they are in the inventory, and resolved when Ansible connects to the hosts. ")
+		return proxyAddresses, monitoringAddress, nil, nil
+	}
+
+	resolvedAddresses, hostnames, err := resolveHostnames(addresses, o.containerConfig.ProxyIpAddressPrefix)
+	if err != nil {
+		logger.Infoln()
+		logger.Infof("The inventory could not be generated, as %v. Please check the hostname, or do not resolve the hostnames if they can only be resolved in the network of the hosts. \n", err)
+		return nil, "", nil, fmt.Errorf("missing required configuration")
+	}
+	return resolvedAddresses[:len(proxyAddresses)], resolvedAddresses[len(proxyAddresses)], hostnames, nil
+}
@@ -0,0 +1,196 @@
+package userinteraction
+
+import (
+	"fmt"
+	"strings"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+const (
+	// names of the settings that are only used to replace user input, as they are not part of the configuration file
+	NonInteractiveSettingName         = "non_interactive"
+	ProxyIpAddressesSettingName       = "proxy_ip_addresses"
+	MonitoringIpAddressSettingName    = "monitoring_ip_address"
+	LocalTestingDeploymentSettingName = "local_testing_deployment"
+	RecreateContainerSettingName      = "recreate_container"
+	ResolveHostnamesSettingName       = "resolve_hostnames"
+)
+
+// NonInteractiveSettings holds the values that replace user input when this utility runs without prompting.
+// Each value has already been resolved from the corresponding command-line flag or environment variable by the caller.
+// The configuration properties are not part of these settings, as they are provided as property layers, see SetPropertyLayers
+type NonInteractiveSettings struct {
+	ProxyIpAddresses       []string
+	MonitoringIpAddress    string
+	LocalTestingDeployment bool
+	// GenerateInventory generates the inventory from the proxy and monitoring addresses even if the configuration refers to an existing one,
+	// e.g. when they are imported from Terraform. The configuration file is then updated to refer to the generated inventory
+	GenerateInventory bool
+	// ResolveHostnames resolves the hostnames among the proxy and monitoring addresses before generating the inventory,
+	// otherwise they are written as they are and resolved when Ansible connects to the hosts
+	ResolveHostnames bool
+}
+
+// MissingConfigurationError lists every required value that is missing or not valid, and could not be resolved without prompting
+type MissingConfigurationError struct {
+	Problems config.ValidationErrors
+}
+
+func (e *MissingConfigurationError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		problems = append(problems, problem.Error())
+	}
+	return "missing required configuration: \n - " + strings.Join(problems, "\n - ")
+}
+
+func NewNonInteractiveOrchestrator(settings *NonInteractiveSettings) *InteractionOrchestrator {
+	return &InteractionOrchestrator{
+		containerConfig:        nil,
+		userInputReader:        nil,
+		nonInteractiveSettings: settings,
+	}
+}
+
+func (o *InteractionOrchestrator) isNonInteractive() bool {
+	return o.nonInteractiveSettings != nil
+}
+
+// createContainerConfigurationNonInteractively builds the configuration from the configuration file and the non-interactive settings.
+// Instead of prompting for missing or invalid values, it returns a MissingConfigurationError listing all of them
+func (o *InteractionOrchestrator) createContainerConfigurationNonInteractively(customConfigFilePath string) (*config.ContainerInitConfig, error) {
+
+	configFilePath := customConfigFilePath
+	if configFilePath == "" && config.ValidatePathOfWritableFileSilently(DefaultConfigurationFilePath) {
+		logger.Infof("Using existing configuration file %v \n", DefaultConfigurationFilePath)
+		configFilePath = DefaultConfigurationFilePath
+	}
+
+	if configFilePath != "" {
+		o.containerConfig = populateConfigFromConfigurationFile(configFilePath, o.profile)
+		logger.Infoln()
+	} else {
+		o.containerConfig = config.NewEmptyContainerInitConfig()
+		o.containerConfig.Profile = o.profile
+	}
+	isConfigFromFileComplete := len(o.containerConfig.Validate()) == 0
+
+	o.containerConfig.ApplyLayers(o.propertyLayers...)
+
+	// a missing inventory is not a problem if it can be generated from the settings
+	isInventoryProvided := o.containerConfig.AnsibleInventoryPathOnHost != "" && !o.nonInteractiveSettings.GenerateInventory
+	problems := make(config.ValidationErrors, 0)
+	if o.strictConfig {
+		problems = append(problems, o.containerConfig.CheckUnknownProperties()...)
+	} else {
+		o.containerConfig.WarnAboutUnknownProperties()
+	}
+	isInventoryValid := true
+	for _, fieldError := range o.containerConfig.Validate() {
+		if fieldError.Field != config.AnsibleInventoryPathOnHostPropertyName || isInventoryProvided {
+			problems = append(problems, fieldError)
+		}
+		if fieldError.Field == config.AnsibleInventoryPathOnHostPropertyName {
+			isInventoryValid = false
+		}
+	}
+	if !isInventoryProvided {
+		problems = append(problems, o.validateInventorySettings()...)
+	} else if isInventoryValid && !o.nonInteractiveSettings.LocalTestingDeployment {
+		// Validate() only checks the inventory against the minimum number of proxies of local testing deployments, so unless the
+		// deployment is for local testing, the inventory is checked again against the minimum number of proxies of production deployments
+		if fieldError := config.CheckAnsibleInventory(o.containerConfig.AnsibleInventoryPathOnHost, inventory.MinNumberOfProxiesForProduction); fieldError != nil {
+			fieldError.Field = config.AnsibleInventoryPathOnHostPropertyName
+			fieldError.Source = o.containerConfig.Sources[config.AnsibleInventoryPathOnHostPropertyName]
+			fieldError.Hint = fmt.Sprintf("Set -%v for local testing and evaluation deployments, which only require one proxy", config.FlagNameForProperty(LocalTestingDeploymentSettingName))
+			problems = append(problems, fieldError)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &MissingConfigurationError{Problems: problems}
+	}
+
+	if !isInventoryProvided {
+		if err := o.generateInventoryNonInteractively(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isConfigFromFileComplete || o.nonInteractiveSettings.GenerateInventory {
+		o.persistConfiguration()
+	}
+
+	if err := o.inspectSshKeys(); err != nil {
+		return nil, err
+	}
+	return o.containerConfig, nil
+}
+
+// validateInventorySettings checks that the proxy and monitoring addresses in the settings can be used to generate an inventory
+func (o *InteractionOrchestrator) validateInventorySettings() config.ValidationErrors {
+	settings := o.nonInteractiveSettings
+	if len(settings.ProxyIpAddresses) == 0 {
+		return config.ValidationErrors{{
+			Field:  config.AnsibleInventoryPathOnHostPropertyName,
+			Reason: "not provided",
+			Hint: fmt.Sprintf("Specify an existing inventory with flag -%v, environment variable %v or in the configuration file, "+
+				"or the proxy addresses to generate one with flag -%v or environment variable %v",
+				config.FlagNameForProperty(config.AnsibleInventoryPathOnHostPropertyName), config.EnvVarNameForProperty(config.AnsibleInventoryPathOnHostPropertyName),
+				config.FlagNameForProperty(ProxyIpAddressesSettingName), config.EnvVarNameForProperty(ProxyIpAddressesSettingName)),
+		}}
+	}
+
+	problems := make(config.ValidationErrors, 0)
+	minNumberOfProxies := inventory.MinNumberOfProxiesForProduction
+	if settings.LocalTestingDeployment {
+		minNumberOfProxies = inventory.MinNumberOfProxiesForLocalTesting
+	}
+	if len(settings.ProxyIpAddresses) < minNumberOfProxies {
+		problems = append(problems, &config.FieldError{
+			Field:  ProxyIpAddressesSettingName,
+			Value:  strings.Join(settings.ProxyIpAddresses, ","),
+			Reason: fmt.Sprintf("%v specified, but a minimum of %v is required", len(settings.ProxyIpAddresses), minNumberOfProxies),
+			Hint:   fmt.Sprintf("Set -%v for local testing and evaluation deployments, which only require one proxy", config.FlagNameForProperty(LocalTestingDeploymentSettingName)),
+		})
+	}
+
+	for _, proxyIpAddress := range settings.ProxyIpAddresses {
+		if fieldError := config.CheckHostAddress(proxyIpAddress); fieldError != nil {
+			fieldError.Field = ProxyIpAddressesSettingName
+			problems = append(problems, fieldError)
+		}
+	}
+	if settings.MonitoringIpAddress != "" {
+		if fieldError := config.CheckHostAddress(settings.MonitoringIpAddress); fieldError != nil {
+			fieldError.Field = MonitoringIpAddressSettingName
+			problems = append(problems, fieldError)
+		}
+	}
+	return problems
+}
+
+// generateInventoryNonInteractively creates the inventory file from the proxy and monitoring addresses in the settings, resolving their hostnames if requested
+func (o *InteractionOrchestrator) generateInventoryNonInteractively() error {
+	settings := o.nonInteractiveSettings
+	proxyAddresses, monitoringAddress := settings.ProxyIpAddresses, settings.MonitoringIpAddress
+	var hostnames map[string]string
+	if settings.ResolveHostnames {
+		resolvedAddresses, resolvedHostnames, err := resolveHostnames(append(append([]string{}, proxyAddresses...), monitoringAddress),
+			o.containerConfig.ProxyIpAddressPrefix)
+		if err != nil {
+			return fmt.Errorf("%v. Please check the hostname, or unset -%v if the hostnames can only be resolved in the network of the hosts",
+				err, config.FlagNameForProperty(ResolveHostnamesSettingName))
+		}
+		proxyAddresses, monitoringAddress, hostnames = resolvedAddresses[:len(proxyAddresses)], resolvedAddresses[len(proxyAddresses)], resolvedHostnames
+	}
+
+	inventoryFileName := InventoryFileNameForProfile(o.profile)
+	if err := o.writeInventoryFile(inventoryFileName, proxyAddresses, monitoringAddress, hostnames); err != nil {
+		return fmt.Errorf("the creation of a new Ansible inventory file with name %v in the current directory failed, due to %v", inventoryFileName, err)
+	}
+
+	return o.containerConfig.SetPropertyFromSource(config.AnsibleInventoryPathOnHostPropertyName, inventoryFileName, config.GeneratedSource)
+}
@@ -0,0 +1,75 @@
+package userinteraction
+
+import (
+	"fmt"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
+)
+
+// SshKeyPassphrases returns the passphrases entered for the SSH keys protected by one, by path of the key on the host.
+// These keys are loaded into an SSH agent in the container, so that Ansible can use them without prompting
+func (o *InteractionOrchestrator) SshKeyPassphrases() map[string]string {
+	return o.sshKeyPassphrases
+}
+
+// inspectSshKeys warns about the SSH keys that can be accessed by other users, and handles the keys protected by a passphrase,
+// which Ansible cannot enter when connecting to the hosts. In interactive mode, the passphrase of each of these keys can be entered
+// so that the key is loaded into an SSH agent in the container, see SshKeyPassphrases. Otherwise the key is copied as it is
+func (o *InteractionOrchestrator) inspectSshKeys() error {
+	o.sshKeyPassphrases = make(map[string]string)
+	for _, sshKeyPath := range o.containerConfig.SshKeyPaths() {
+		if warning := config.SshKeyPermissionsWarning(sshKeyPath); warning != "" {
+			logger.Warnf("%v \n", warning)
+		}
+
+		// keys that are not valid have already been reported when validating the configuration
+		keyInfo, fieldError := config.InspectSshKey(sshKeyPath)
+		if fieldError != nil {
+			continue
+		}
+		logger.Debugf("SSH key %v is of type %v, encrypted %v \n", sshKeyPath, keyInfo.Type, keyInfo.Encrypted)
+		if !keyInfo.Encrypted {
+			continue
+		}
+
+		if o.isNonInteractive() {
+			logger.Warnf("SSH key %v is protected by a passphrase, which cannot be entered in non-interactive mode, so Ansible will not be able to use it. "+
+				"Please run this utility interactively to enter the passphrase, or remove the passphrase with: ssh-keygen -p -f %v \n", sshKeyPath, sshKeyPath)
+			continue
+		}
+		passphrase, err := o.promptForSshKeyPassphrase(sshKeyPath)
+		if err != nil {
+			return err
+		}
+		if passphrase != "" {
+			o.sshKeyPassphrases[sshKeyPath] = passphrase
+		}
+	}
+	return nil
+}
+
+// promptForSshKeyPassphrase offers to enter the passphrase of the SSH key, and checks it against the key.
+// It returns an empty passphrase if the user chooses to copy the key as it is
+func (o *InteractionOrchestrator) promptForSshKeyPassphrase(sshKeyPath string) (string, error) {
+	logger.Infoln()
+	ynEnterPassphrase, err := YesNoPrompt(fmt.Sprintf("SSH key %v is protected by a passphrase, which Ansible cannot enter when connecting to the hosts. "+
+		"Do you wish to enter the passphrase now, so that the key is loaded into an SSH agent in the container?", sshKeyPath),
+		true, true, o.userInputReader, DefaultMaxAttempts)
+	if err != nil {
+		return "", fmt.Errorf("no indication was given about whether to enter the passphrase of SSH key %v: %v", sshKeyPath, err)
+	}
+	if !ynEnterPassphrase {
+		logger.Warnf("SSH key %v will be copied to the container as it is, and must be loaded into an SSH agent in the container before running the playbooks. \n", sshKeyPath)
+		return "", nil
+	}
+
+	for remainingAttempts := DefaultMaxAttempts; remainingAttempts > 0; remainingAttempts-- {
+		passphrase := PassphrasePrompt(fmt.Sprintf("Please enter the passphrase of SSH key %v", sshKeyPath), o.userInputReader)
+		fieldError := config.CheckSshKeyPassphrase(sshKeyPath, passphrase)
+		if fieldError == nil {
+			return passphrase, nil
+		}
+		logger.Infof("%v \n", fieldError.Message())
+	}
+	return "", fmt.Errorf("the passphrase of SSH key %v was not provided or was not correct", sshKeyPath)
+}
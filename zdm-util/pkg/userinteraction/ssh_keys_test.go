@@ -0,0 +1,68 @@
+package userinteraction
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/testutils"
+)
+
+func TestInspectSshKeys(t *testing.T) {
+	sshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key")
+	encryptedSshKeyPath := testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_encrypted_ssh_key")
+
+	tests := []struct {
+		name                      string
+		userInputValues           []string
+		nonInteractive            bool
+		expectedSshKeyPassphrases map[string]string
+		expectedErrorMessage      string
+	}{
+		{
+			name:                      "passphrase entered on second attempt",
+			userInputValues:           []string{"y", "wrong passphrase", "dummy passphrase"},
+			expectedSshKeyPassphrases: map[string]string{encryptedSshKeyPath: "dummy passphrase"},
+		},
+		{
+			name:                      "key copied as it is",
+			userInputValues:           []string{"n"},
+			expectedSshKeyPassphrases: map[string]string{},
+		},
+		{
+			name:                 "attempts to enter the passphrase exhausted",
+			userInputValues:      []string{"y", "wrong passphrase 1", "wrong passphrase 2", "wrong passphrase 3", "wrong passphrase 4", "wrong passphrase 5"},
+			expectedErrorMessage: "the passphrase of SSH key " + encryptedSshKeyPath + " was not provided or was not correct",
+		},
+		{
+			name:                      "no prompt in non-interactive mode",
+			nonInteractive:            true,
+			expectedSshKeyPassphrases: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var interactionOrchestrator *InteractionOrchestrator
+			if tt.nonInteractive {
+				interactionOrchestrator = NewNonInteractiveOrchestrator(&NonInteractiveSettings{})
+			} else {
+				userInput := strings.Join(tt.userInputValues, "\n") + "\n"
+				interactionOrchestrator = NewInteractionOrchestrator(bufio.NewReader(strings.NewReader(userInput)))
+			}
+			interactionOrchestrator.containerConfig = &config.ContainerInitConfig{
+				SshKeyPathOnHost:  sshKeyPath,
+				AdditionalSshKeys: []config.SshKey{{PathOnHost: encryptedSshKeyPath, HostPatterns: []string{"172.18.10.5"}}},
+			}
+
+			err := interactionOrchestrator.inspectSshKeys()
+			if tt.expectedErrorMessage != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.expectedErrorMessage, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedSshKeyPassphrases, interactionOrchestrator.SshKeyPassphrases())
+		})
+	}
+}
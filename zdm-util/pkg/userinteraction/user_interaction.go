@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
 	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/inventory"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
 )
 
 const (
@@ -24,26 +27,99 @@ const (
 type InteractionOrchestrator struct {
 	containerConfig *config.ContainerInitConfig
 	userInputReader *bufio.Reader
+	// nonInteractiveSettings is only set when running in non-interactive mode
+	nonInteractiveSettings *NonInteractiveSettings
+	// configFileFormat is the format in which the configuration file is written, if chosen explicitly
+	configFileFormat config.FileFormat
+	// propertyLayers provide values that take precedence over the configuration file, in order of precedence
+	propertyLayers []config.PropertyLayer
+	// profile is the profile of the configuration file that is read and written
+	profile string
+	// strictConfig makes unknown properties of the configuration file an error, instead of ignoring them with a warning
+	strictConfig bool
+	// sshKeyPassphrases holds the passphrases entered for the SSH keys protected by one, see SshKeyPassphrases
+	sshKeyPassphrases map[string]string
+	// dryRun only plans the files that would be written, see SetDryRun
+	dryRun            bool
+	plannedFileWrites []PlannedFileWrite
 }
 
 func NewInteractionOrchestrator(reader *bufio.Reader) *InteractionOrchestrator {
 	return &InteractionOrchestrator{
-		containerConfig: nil,
-		userInputReader: reader,
+		containerConfig:        nil,
+		userInputReader:        reader,
+		nonInteractiveSettings: nil,
 	}
 }
 
+// SetConfigurationFileFormat chooses the format in which the configuration file is written.
+// By default, the format of the configuration file that was read is kept, and new files are written in the legacy format
+func (o *InteractionOrchestrator) SetConfigurationFileFormat(format config.FileFormat) {
+	o.configFileFormat = format
+}
+
+// SetProfile selects the profile of the configuration file that is read and written, see config.ConfigFile.
+// By default, the default profile is used
+func (o *InteractionOrchestrator) SetProfile(profile string) {
+	o.profile = profile
+}
+
+// SetStrictConfig chooses whether unknown properties of the configuration file are reported as errors, see config.ContainerInitConfig.CheckUnknownProperties.
+// By default, they are ignored with a warning
+func (o *InteractionOrchestrator) SetStrictConfig(strictConfig bool) {
+	o.strictConfig = strictConfig
+}
+
+// InventoryFileNameForProfile returns the name of the Ansible inventory file generated in the current directory for the profile,
+// so that generating the inventory of a profile does not overwrite that of another profile
+func InventoryFileNameForProfile(profile string) string {
+	if profile == config.DefaultProfile {
+		return DefaultAnsibleInventoryFileName
+	}
+	return DefaultAnsibleInventoryFileName + "_" + profile
+}
+
+// SetPropertyLayers sets the sources of property values that take precedence over the configuration file, e.g. flags and environment variables.
+// The layers must be passed in order of precedence. Any property still missing is then prompted for, unless running in non-interactive mode
+func (o *InteractionOrchestrator) SetPropertyLayers(layers ...config.PropertyLayer) {
+	o.propertyLayers = layers
+}
+
+// configurationFileFormat returns the format in which the configuration file is written, or an empty format
+// to keep that of the existing configuration file, see persistCurrentConfigToFile
+func (o *InteractionOrchestrator) configurationFileFormat() config.FileFormat {
+	if o.configFileFormat != "" {
+		return o.configFileFormat
+	}
+	if o.containerConfig != nil && o.containerConfig.FileFormat != "" {
+		return o.containerConfig.FileFormat
+	}
+	return ""
+}
+
 func (o *InteractionOrchestrator) CreateContainerConfiguration(customConfigFilePath string) (*config.ContainerInitConfig, error) {
 
 	printUtilityGeneralPreamble()
 	var err error
 
+	if o.isNonInteractive() {
+		return o.createContainerConfigurationNonInteractively(customConfigFilePath)
+	}
+
 	o.containerConfig, err = o.loadConfigurationFromExistingFile(customConfigFilePath)
 	if err != nil {
 		return nil, err
 	}
+	o.containerConfig.ApplyLayers(o.propertyLayers...)
+	for _, fieldError := range discardInvalidProperties(o.containerConfig) {
+		if config.IsRequiredProperty(fieldError.Field) {
+			logger.Warnf("%v. You will be prompted for this value. \n", fieldError)
+		} else {
+			logger.Warnf("%v. This value is being ignored. \n", fieldError)
+		}
+	}
 
-	fmt.Println()
+	logger.Infoln()
 
 	if !o.containerConfig.IsFullyPopulated() {
 		printInteractivePreamble()
@@ -52,27 +128,38 @@ func (o *InteractionOrchestrator) CreateContainerConfiguration(customConfigFileP
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println()
+		logger.Infoln()
 
 		err = o.promptForProxyPrivateIpAddressPrefix()
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println()
+		logger.Infoln()
 
 		err = o.promptForAnsibleInventory()
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println()
+		logger.Infoln()
+
+		err = o.promptForAdditionalSshKeys()
+		if err != nil {
+			return nil, err
+		}
+		logger.Infoln()
 
-		err = persistCurrentConfigToFile(o.containerConfig)
+		err = o.promptForJumphost()
 		if err != nil {
-			fmt.Printf("The configuration file %v could not be created due to %v. This utility will continue without persisting its configuration. \n", DefaultConfigurationFilePath, err)
+			return nil, err
 		}
-		fmt.Printf("Configuration successfully written to file %v \n", DefaultConfigurationFilePath)
+		logger.Infoln()
+
+		o.persistConfiguration()
 	}
 
+	if err = o.inspectSshKeys(); err != nil {
+		return nil, err
+	}
 	return o.containerConfig, nil
 }
 
@@ -82,7 +169,7 @@ func printUtilityGeneralPreamble() {
 *** This utility creates and initializes the Ansible Control Host container *** 
 ******************************************************************************* `
 
-	fmt.Println(generalPreamble)
+	logger.Infoln(generalPreamble)
 }
 
 func (o *InteractionOrchestrator) loadConfigurationFromExistingFile(customConfigFilePath string) (*config.ContainerInitConfig, error) {
@@ -106,82 +193,105 @@ func (o *InteractionOrchestrator) loadConfigurationFromExistingFile(customConfig
 	}
 
 	if existingConfigFilePath != "" {
-		containerConfig = populateConfigFromConfigurationFile(existingConfigFilePath)
+		containerConfig = populateConfigFromConfigurationFile(existingConfigFilePath, o.profile)
+		if unknownProperties := containerConfig.CheckUnknownProperties(); o.strictConfig && len(unknownProperties) > 0 {
+			return nil, fmt.Errorf("the configuration file %v has unknown properties: \n%v", existingConfigFilePath, unknownProperties)
+		}
+		containerConfig.WarnAboutUnknownProperties()
+		for _, fieldError := range discardInvalidProperties(containerConfig) {
+			logger.Infof("%v \n", fieldError.Message())
+		}
 		if containerConfig.IsEmpty() {
-			fmt.Println()
-			fmt.Printf("No configuration properties were specified.\n")
+			logger.Infoln()
+			logger.Infof("No configuration properties were specified.\n")
 		}
 		if !containerConfig.IsFullyPopulated() && !containerConfig.IsEmpty() {
-			fmt.Println()
-			fmt.Println("The configuration file was incomplete or not fully valid. ")
+			logger.Infoln()
+			logger.Infoln("The configuration file was incomplete or not fully valid. ")
 		}
 	} else {
 		containerConfig = config.NewEmptyContainerInitConfig()
+		containerConfig.Profile = o.profile
 	}
 	return containerConfig, nil
 }
 
-func populateConfigFromConfigurationFile(existingConfigurationFilePath string) *config.ContainerInitConfig {
-	containerConfig, err := config.NewContainerInitConfigFromFile(existingConfigurationFilePath)
+func populateConfigFromConfigurationFile(existingConfigurationFilePath string, profile string) *config.ContainerInitConfig {
+	containerConfig, err := config.NewContainerInitConfigFromFile(existingConfigurationFilePath, profile)
 	if err != nil {
-		fmt.Printf("There was an error with the provided configuration file: %v. This utility will now switch to using interactive input instead.\n", err)
-		return config.NewEmptyContainerInitConfig()
+		logger.Infof("There was an error with the provided configuration file: %v. This utility will now switch to using interactive input instead.\n", err)
+		containerConfig = config.NewEmptyContainerInitConfig()
+		containerConfig.Profile = profile
+		return containerConfig
 	}
-	fmt.Printf("Configuration file parsed. ")
+	logger.Infof("Configuration file parsed. ")
 	return containerConfig
 }
 
+// discardInvalidProperties removes from the configuration every property whose value is not valid, so that it is prompted for.
+// It returns the validation errors of the removed properties
+func discardInvalidProperties(containerConfig *config.ContainerInitConfig) config.ValidationErrors {
+	discarded := make(config.ValidationErrors, 0)
+	for _, fieldError := range containerConfig.Validate() {
+		if _, found := containerConfig.Property(fieldError.Field); found {
+			containerConfig.UnsetProperty(fieldError.Field)
+			discarded = append(discarded, fieldError)
+		}
+	}
+	return discarded
+}
+
 func printInteractivePreamble() {
-	fmt.Printf("***** Running this utility in interactive mode. ***** \n")
-	fmt.Printf("The results will be saved to a configuration file called %v and located in the current execution directory. This file can be passed to this utility if it needs to be run again. \n", DefaultConfigurationFilePath)
+	logger.Infof("***** Running this utility in interactive mode. ***** \n")
+	logger.Infof("The results will be saved to a configuration file called %v and located in the current execution directory. This file can be passed to this utility if it needs to be run again. \n", DefaultConfigurationFilePath)
 }
 
 func (o *InteractionOrchestrator) promptForSshKeyPath() error {
-	if _, found := o.containerConfig.Properties[config.SshKeyPathOnHostPropertyName]; !found {
+	if o.containerConfig.SshKeyPathOnHost == "" {
 
 		sshKeyPathOnHost := StringPrompt("Please enter the path and name of the SSH private key to access the proxy hosts",
-			RequiredParameterNoDefaultMessage+ProvideValueMessage, false, DefaultMaxAttempts, config.ValidateFilePath, o.userInputReader)
+			RequiredParameterNoDefaultMessage+ProvideValueMessage, false, DefaultMaxAttempts, config.ValidateSshPrivateKey, o.userInputReader)
 
 		if sshKeyPathOnHost == "" {
-			fmt.Println()
-			fmt.Println("The SSH private key to access the proxy hosts was not provided or was not valid. " + RequiredParameterNoDefaultMessage)
+			logger.Infoln()
+			logger.Infoln("The SSH private key to access the proxy hosts was not provided or was not valid. " + RequiredParameterNoDefaultMessage)
 			err := fmt.Errorf("missing required configuration")
 			return err
 		}
 
-		if absoluteSshKeyPathOnHost, ok := config.ConvertToAbsolutePath(sshKeyPathOnHost); ok {
-			o.containerConfig.AddProperty(config.SshKeyPathOnHostPropertyName, absoluteSshKeyPathOnHost)
+		if err := o.containerConfig.SetPropertyFromSource(config.SshKeyPathOnHostPropertyName, sshKeyPathOnHost, config.PromptSource); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (o *InteractionOrchestrator) promptForProxyPrivateIpAddressPrefix() error {
-	if _, found := o.containerConfig.Properties[config.ProxyIpAddressPrefixPropertyName]; !found {
+	if o.containerConfig.ProxyIpAddressPrefix == "" {
 
-		proxyPrivateIpAddressPrefix := StringPrompt("Please enter the common prefix of the private IP addresses of the proxy hosts (examples: 172.* or 172.18.* or 172.18.10.*)",
+		proxyPrivateIpAddressPrefix := StringPrompt("Please enter the common prefix of the private IP addresses of the proxy hosts (examples: 172.* or 172.18.* or 172.18.10.* or fd00:10:*), or their CIDR range (examples: 10.0.16.0/20 or fd00:10::/32)",
 			RequiredParameterNoDefaultMessage+ProvideValueMessage,
 			false, DefaultMaxAttempts, config.ValidateIpAddressPrefix, o.userInputReader)
 
 		if proxyPrivateIpAddressPrefix == "" {
-			fmt.Println()
-			fmt.Println("The common prefix of the private IP addresses of the proxy hosts was not provided or was not valid. " + RequiredParameterNoDefaultMessage)
+			logger.Infoln()
+			logger.Infoln("The common prefix of the private IP addresses of the proxy hosts was not provided or was not valid. " + RequiredParameterNoDefaultMessage)
 			return fmt.Errorf("missing required configuration")
 		}
-		o.containerConfig.AddProperty(config.ProxyIpAddressPrefixPropertyName, proxyPrivateIpAddressPrefix)
+		return o.containerConfig.SetPropertyFromSource(config.ProxyIpAddressPrefixPropertyName, proxyPrivateIpAddressPrefix, config.PromptSource)
 	}
 	return nil
 }
 
 func (o *InteractionOrchestrator) promptForAnsibleInventory() error {
-	if _, found := o.containerConfig.Properties[config.AnsibleInventoryPathOnHostPropertyName]; !found {
+	if o.containerConfig.AnsibleInventoryPathOnHost == "" {
 		ansibleInventoryPathOnHost := ""
 		ynInventory, ynErr := YesNoPrompt("Do you have an existing Ansible inventory file?", false, false, o.userInputReader, DefaultMaxAttempts)
 		if ynErr != nil {
 			return fmt.Errorf("no indication was given about whether an Ansible inventory file exists or should be created interactively: %v", ynErr)
 		}
 		if ynInventory {
-			fmt.Println()
+			logger.Infoln()
 
 			userHomeDir, err := getUserHomeDirectory()
 			if err != nil {
@@ -189,83 +299,266 @@ func (o *InteractionOrchestrator) promptForAnsibleInventory() error {
 			}
 
 			ansibleInventoryPathOnHost = StringPrompt("Please enter the path and name of your Ansible inventory file. Simply press ENTER if your inventory is " + userHomeDir + DefaultAnsibleInventoryFileName,
-				"", true, DefaultMaxAttempts, config.ValidateFilePath, o.userInputReader)
+				"", true, DefaultMaxAttempts, config.ValidateAnsibleInventory, o.userInputReader)
 
 			if ansibleInventoryPathOnHost == "" {
-				if config.ValidateFilePath(userHomeDir + DefaultAnsibleInventoryFileName) {
+				if config.ValidateAnsibleInventory(userHomeDir + DefaultAnsibleInventoryFileName) {
 					ansibleInventoryPathOnHost = userHomeDir + DefaultAnsibleInventoryFileName
 				} else {
-					fmt.Printf("The Ansible inventory file path %v  is not valid. \n", DefaultAnsibleInventoryFileName)
+					logger.Infof("The Ansible inventory file path %v  is not valid. \n", DefaultAnsibleInventoryFileName)
 					return fmt.Errorf("missing required configuration")
 				}
 
 			}
+			if err = o.confirmNumberOfProxiesInInventory(ansibleInventoryPathOnHost); err != nil {
+				return err
+			}
 		}
 		if ansibleInventoryPathOnHost == "" {
-			fmt.Printf("This utility will create a new inventory file and populate it interactively, prompting you for the necessary values.\n")
+			logger.Infof("This utility will create a new inventory file and populate it interactively, prompting you for the necessary values.\n")
 			proxyIpsAddresses, monitoringIpAddress, err := o.promptForInventoryFileValues()
 
 			if err != nil {
 				return err
 			}
 
-			err = populateInventoryFile(DefaultAnsibleInventoryFileName, proxyIpsAddresses, monitoringIpAddress)
+			proxyIpsAddresses, monitoringIpAddress, hostnames, err := o.promptForHostnameResolution(proxyIpsAddresses, monitoringIpAddress)
+			if err != nil {
+				return err
+			}
+			logger.Infoln()
+
+			if err = o.promptForRemoteUser(); err != nil {
+				return err
+			}
+			logger.Infoln()
+
+			if err = o.promptForHostOverrides(); err != nil {
+				return err
+			}
+			logger.Infoln()
+
+			inventoryFileName := InventoryFileNameForProfile(o.profile)
+			err = o.writeInventoryFile(inventoryFileName, proxyIpsAddresses, monitoringIpAddress, hostnames)
 			if err != nil {
-				fmt.Printf("The creation of a new Ansible inventory file with name %v in the current directory failed, due to %v \n", DefaultAnsibleInventoryFileName, err)
+				logger.Infof("The creation of a new Ansible inventory file with name %v in the current directory failed, due to %v \n", inventoryFileName, err)
 				return fmt.Errorf("missing required configuration")
 			}
 
-			fmt.Println()
+			logger.Infoln()
 
-			ansibleInventoryPathOnHost = DefaultAnsibleInventoryFileName
+			ansibleInventoryPathOnHost = inventoryFileName
 		}
 
-		if absoluteAnsibleInventoryPathOnHost, ok := config.ConvertToAbsolutePath(ansibleInventoryPathOnHost); ok {
-			o.containerConfig.AddProperty(config.AnsibleInventoryPathOnHostPropertyName, absoluteAnsibleInventoryPathOnHost)
+		return o.containerConfig.SetPropertyFromSource(config.AnsibleInventoryPathOnHostPropertyName, ansibleInventoryPathOnHost, config.PromptSource)
+	}
+	return nil
+}
+
+// confirmNumberOfProxiesInInventory asks whether the deployment is for local testing and evaluation if the existing inventory has fewer proxies
+// than required for production deployments. The inventory has already been validated with the minimum number of proxies of local testing deployments
+func (o *InteractionOrchestrator) confirmNumberOfProxiesInInventory(inventoryPath string) error {
+	absPath, _ := config.ConvertToAbsolutePath(inventoryPath)
+	existingInventory, err := inventory.ParseFile(absPath)
+	if err != nil {
+		return err
+	}
+	numberOfProxies := len(existingInventory.HostsOf(inventory.ProxiesGroupName))
+	if numberOfProxies >= inventory.MinNumberOfProxiesForProduction {
+		return nil
+	}
+
+	ynDemoEnv, err := YesNoPrompt(fmt.Sprintf("The Ansible inventory has %v proxy hosts, whereas at least %v are required for general testing and production deployments. "+
+		"Is this proxy deployment for local testing and evaluation?", numberOfProxies, inventory.MinNumberOfProxiesForProduction), true, false, o.userInputReader, DefaultMaxAttempts)
+	if err != nil || !ynDemoEnv {
+		logger.Infoln()
+		logger.Infof("Please add the missing proxy hosts to the [%v] group of the Ansible inventory %v \n", inventory.ProxiesGroupName, inventoryPath)
+		return fmt.Errorf("missing required configuration")
+	}
+	return nil
+}
+
+// promptForAdditionalSshKeys asks for the keys to access any host that cannot be accessed with the SSH key of the proxies,
+// e.g. the monitoring host or proxies in a different subnet, together with the patterns of the hosts that each key gives access to
+func (o *InteractionOrchestrator) promptForAdditionalSshKeys() error {
+	if len(o.containerConfig.AdditionalSshKeys) > 0 {
+		return nil
+	}
+
+	ynAdditionalKeys, err := YesNoPrompt("Do any of your hosts, for example the monitoring host or proxies in a different subnet, require an SSH key other than the one you specified?",
+		true, false, o.userInputReader, DefaultMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("no indication was given about whether additional SSH keys are needed: %v", err)
+	}
+	if !ynAdditionalKeys {
+		return nil
+	}
+
+	logger.Infoln()
+	logger.Infoln("Please enter one key at a time, followed by the hosts it gives access to. When you have finished, simply press ENTER. ")
+	sshKeys := make([]config.SshKey, 0)
+	for {
+		sshKeyPath := StringPrompt("Please enter the path and name of the additional SSH private key", "", true, DefaultMaxAttempts, config.ValidateSshPrivateKey, o.userInputReader)
+		if sshKeyPath == "" {
+			break
+		}
+		hostPatterns := StringPrompt("Please enter the hosts that this key gives access to, separated by spaces (examples: 172.19.* or 172.18.10.5 172.18.10.6)",
+			RequiredParameterNoDefaultMessage+ProvideValueMessage, false, DefaultMaxAttempts, config.ValidateHostPatterns, o.userInputReader)
+		if hostPatterns == "" {
+			logger.Infoln()
+			logger.Infof("The hosts that the SSH key %v gives access to were not provided or were not valid. \n", sshKeyPath)
+			return fmt.Errorf("missing required configuration")
+		}
+		sshKeys = append(sshKeys, config.SshKey{PathOnHost: sshKeyPath, HostPatterns: strings.Fields(hostPatterns)})
+	}
+
+	if err = o.containerConfig.SetPropertyFromSource(config.AdditionalSshKeysPropertyName, config.FormatSshKeys(sshKeys), config.PromptSource); err != nil {
+		return err
+	}
+	if problems := o.containerConfig.ValidateProperty(config.AdditionalSshKeysPropertyName); len(problems) > 0 {
+		for _, problem := range problems {
+			logger.Infof("%v \n", problem.Message())
 		}
+		return fmt.Errorf("invalid additional SSH keys")
+	}
+	return nil
+}
+
+// promptForJumphost asks whether the proxies can only be reached through a jumphost and, if so, for its public and private IP addresses
+// or hostnames, from which the SSH configuration of the container is generated
+func (o *InteractionOrchestrator) promptForJumphost() error {
+	if o.containerConfig.JumphostPublicIpAddress != "" {
+		return nil
+	}
+
+	ynJumphost, err := YesNoPrompt("Are your proxy instances in a private network that can only be reached through a jumphost?",
+		true, false, o.userInputReader, DefaultMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("no indication was given about whether a jumphost is needed: %v", err)
+	}
+	if !ynJumphost {
+		return nil
+	}
+
+	publicIpAddress := StringPrompt("Please enter the public IP address or hostname of the jumphost",
+		RequiredParameterNoDefaultMessage+ProvideValueMessage, false, DefaultMaxAttempts, config.ValidateHostAddress, o.userInputReader)
+	if publicIpAddress == "" {
+		logger.Infoln()
+		logger.Infof("The public IP address or hostname of the jumphost was not provided or was not valid. \n")
+		return fmt.Errorf("missing required configuration")
+	}
+	if err = o.containerConfig.SetPropertyFromSource(config.JumphostPublicIpAddressPropertyName, publicIpAddress, config.PromptSource); err != nil {
+		return err
+	}
+
+	if o.containerConfig.JumphostPrivateIpAddress != "" {
+		return nil
+	}
+	privateIpAddress := StringPrompt("Please enter the private IP address or hostname of the jumphost, or simply press ENTER if it does not have one",
+		"", true, DefaultMaxAttempts, config.ValidateHostAddress, o.userInputReader)
+	if privateIpAddress != "" {
+		return o.containerConfig.SetPropertyFromSource(config.JumphostPrivateIpAddressPropertyName, privateIpAddress, config.PromptSource)
 	}
 	return nil
 }
 
 // promptForInventoryFileValues asks the user to provide:
-//  - the IP addresses of their proxy instances (requesting the appropriate minimum based on the type of deployment)
-//  - the IP address of their monitoring instance (optional)
+//  - the IP addresses or hostnames of their proxy instances (requesting the appropriate minimum based on the type of deployment)
+//  - the IP address or hostname of their monitoring instance (optional)
 func (o *InteractionOrchestrator) promptForInventoryFileValues() ([]string, string, error) {
 	ynDemoEnv, err := YesNoPrompt("Is this proxy deployment for local testing and evaluation?", true, false, o.userInputReader, DefaultMaxAttempts)
 	if err != nil {
-		fmt.Printf("\nNo valid answer was given, considering this a production deployment.\n")
+		logger.Infof("\nNo valid answer was given, considering this a production deployment.\n")
 		ynDemoEnv = false
 	}
 
-	fmt.Printf("\nYou will now be prompted for the private IP addresses of all your proxy instances. ")
+	logger.Infof("\nYou will now be prompted for the private IP addresses or hostnames of all your proxy instances. ")
 
 	var minNumberOfProxies int
 	if ynDemoEnv {
-		fmt.Printf("At least one proxy instance is required for local testing and evaluation purposes. \n")
+		logger.Infof("At least one proxy instance is required for local testing and evaluation purposes. \n")
 		minNumberOfProxies = 1
 	} else {
-		fmt.Printf("At least three proxy instances are required for general testing and production deployments. \n")
+		logger.Infof("At least three proxy instances are required for general testing and production deployments. \n")
 		minNumberOfProxies = 3
 	}
 
-	fmt.Println()
-	fmt.Println("Please enter one address at a time and press ENTER. When you have finished, simply press ENTER. ")
-	proxyIpsAddresses := StringPromptLoopingForMultipleValues("Proxy private IP address", config.ValidateIPAddress, o.userInputReader)
+	logger.Infoln()
+	logger.Infoln("Please enter one address at a time and press ENTER. When you have finished, simply press ENTER. ")
+	proxyIpsAddresses := StringPromptLoopingForMultipleValues("Proxy private IP address or hostname", config.ValidateHostAddress, o.userInputReader)
 	if len(proxyIpsAddresses) < minNumberOfProxies {
-		fmt.Printf("A minimum of %v private IP addresses must be specified\n", minNumberOfProxies)
+		logger.Infof("A minimum of %v private IP addresses or hostnames must be specified\n", minNumberOfProxies)
 		return nil, "", fmt.Errorf("missing required configuration")
 	}
-	fmt.Println()
-	monitoringIpAddress := StringPrompt("Please enter the private IP address of your monitoring instance. Simply press ENTER to leave it empty",
-		"", true, DefaultMaxAttempts, config.ValidateIPAddress, o.userInputReader)
-	fmt.Println()
+	logger.Infoln()
+	monitoringIpAddress := StringPrompt("Please enter the private IP address or hostname of your monitoring instance. Simply press ENTER to leave it empty",
+		"", true, DefaultMaxAttempts, config.ValidateHostAddress, o.userInputReader)
+	logger.Infoln()
 
 	return proxyIpsAddresses, monitoringIpAddress, nil
 }
 
-// populateInventoryFile creates a new Ansible inventory file populating it with the provided addresses
-func populateInventoryFile(filePath string, proxyIpAddresses []string, monitoringIpAddress string) error {
-	fmt.Println("All inventory values obtained, now creating the Ansible inventory file")
+// promptForRemoteUser asks for the user that Ansible connects as on the hosts of the generated inventory, which is the default user of their
+// Linux distribution rather than the user running this utility. The default users of the known distributions are listed as hints
+func (o *InteractionOrchestrator) promptForRemoteUser() error {
+	if o.containerConfig.RemoteUser != "" {
+		return nil
+	}
+
+	logger.Infoln("Ansible connects to your hosts as the default user of their Linux distribution, which depends on the image they were created from: ")
+	for _, hint := range config.RemoteUserHints {
+		logger.Infof(" - %v for %v (%v OS family) \n", hint.User, hint.Distributions, hint.OsFamily)
+	}
+	logger.Infoln("On hosts of the RedHat and Rocky OS families, the playbooks install the dependencies with the install_*-centos.yml tasks. ")
+	remoteUser := StringPrompt(fmt.Sprintf("Please enter the user that Ansible connects as on your hosts. Simply press ENTER to use %v", config.DefaultRemoteUser),
+		"", true, DefaultMaxAttempts, config.ValidateRemoteUser, o.userInputReader)
+	if remoteUser == "" {
+		remoteUser = config.DefaultRemoteUser
+	}
+	return o.containerConfig.SetPropertyFromSource(config.RemoteUserPropertyName, remoteUser, config.PromptSource)
+}
+
+// promptForHostOverrides asks for the groups or hosts of the generated inventory that have a different user or SSH port,
+// e.g. a monitoring host created from another image than the proxies
+func (o *InteractionOrchestrator) promptForHostOverrides() error {
+	if len(o.containerConfig.HostOverrides) > 0 {
+		return nil
+	}
+
+	ynHostOverrides, err := YesNoPrompt("Do any of your hosts, for example the monitoring host, have a different user or SSH port?",
+		true, false, o.userInputReader, DefaultMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("no indication was given about whether any host has a different user or SSH port: %v", err)
+	}
+	if !ynHostOverrides {
+		return nil
+	}
+
+	logger.Infoln()
+	logger.Infof("Please enter one override at a time, for the %v or %v group or for the IP address of a host, in the form <group or host>=<user>[:<port>] "+
+		"(examples: monitoring=centos or 172.18.10.5=rocky:2222 or proxies=:2222). When you have finished, simply press ENTER. \n",
+		inventory.ProxiesGroupName, inventory.MonitoringGroupName)
+	hostOverrides := StringPromptLoopingForMultipleValues("Group or host override", config.ValidateHostOverride, o.userInputReader)
+
+	if err = o.containerConfig.SetPropertyFromSource(config.HostOverridesPropertyName, strings.Join(hostOverrides, ","), config.PromptSource); err != nil {
+		return err
+	}
+	if problems := o.containerConfig.ValidateProperty(config.HostOverridesPropertyName); len(problems) > 0 {
+		for _, problem := range problems {
+			logger.Infof("%v \n", problem.Message())
+		}
+		return fmt.Errorf("invalid host overrides")
+	}
+	return nil
+}
+
+// populateInventoryFile creates a new Ansible inventory file populating it with the provided addresses, which are IP addresses or hostnames.
+// IP addresses are written in their canonical form, and IPv6 addresses without brackets, which Ansible only requires when followed by a port.
+// The hostname an IP address was resolved from, if any, is recorded in a comment on the line of the host.
+// Each host is written with the user that Ansible connects as, and its SSH port if it is not the default one, see config.RemoteUserAndPort
+func populateInventoryFile(filePath string, proxyIpAddresses []string, monitoringIpAddress string, containerConfig *config.ContainerInitConfig,
+	hostnames map[string]string) error {
+	logger.Infoln("All inventory values obtained, now creating the Ansible inventory file")
 	ansibleInventoryFile, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -279,13 +572,8 @@ func populateInventoryFile(filePath string, proxyIpAddresses []string, monitorin
 		return err
 	}
 
-	inventoryAddressLineSuffix, err := getInventoryAddressLineSuffix()
-	if err != nil {
-		return err
-	}
-
 	for _, proxyIpAddress := range proxyIpAddresses {
-		_, err = fmt.Fprintf(w, "%v %v\n", proxyIpAddress, inventoryAddressLineSuffix)
+		_, err = fmt.Fprintln(w, inventoryAddressLine(containerConfig, inventory.ProxiesGroupName, proxyIpAddress, hostnames))
 		if err != nil {
 			return err
 		}
@@ -300,7 +588,7 @@ func populateInventoryFile(filePath string, proxyIpAddresses []string, monitorin
 		if err != nil {
 			return err
 		}
-		_, err = fmt.Fprintf(w, "%v %v\n", monitoringIpAddress, inventoryAddressLineSuffix)
+		_, err = fmt.Fprintln(w, inventoryAddressLine(containerConfig, inventory.MonitoringGroupName, monitoringIpAddress, hostnames))
 		if err != nil {
 			return err
 		}
@@ -311,39 +599,36 @@ func populateInventoryFile(filePath string, proxyIpAddresses []string, monitorin
 		return err
 	}
 
-	fmt.Printf("Ansible Inventory successfully written to file %v \n", filePath)
+	logger.Infof("Ansible Inventory successfully written to file %v \n", filePath)
 
 	return nil
 }
 
-// persistCurrentConfigToFile saves to file the configuration values provided interactively
-func persistCurrentConfigToFile(containerConfig *config.ContainerInitConfig) error {
-	configFile, err := os.Create(DefaultConfigurationFilePath)
+// persistCurrentConfigToFile saves to file the configuration values provided interactively, in the section of their profile.
+// The other profiles of the existing configuration file are kept, as is its format unless another format is specified
+func persistCurrentConfigToFile(containerConfig *config.ContainerInitConfig, format config.FileFormat) error {
+	configFile, err := config.ReadConfigFileIfExists(DefaultConfigurationFilePath)
 	if err != nil {
-		return err
+		return fmt.Errorf("the existing file could not be read, so it was not overwritten: %v", err)
 	}
-	defer closeFile(configFile)
-
-	w := bufio.NewWriter(configFile)
-
-	for propertyName, propertyValue := range containerConfig.Properties {
-		_, err = fmt.Fprintf(w, propertyName+": "+propertyValue+"\n")
-		if err != nil {
-			return err
-		}
+	configFile.SetProfileProperties(containerConfig.Profile, containerConfig.Properties())
+	if format == "" {
+		format = configFile.Format
 	}
-
-	w.Flush()
+	content, err := configFile.Marshal(format)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return config.WriteConfigFile(DefaultConfigurationFilePath, content)
 }
 
 func (o *InteractionOrchestrator) DisplayConfigurationAndPromptForConfirmation() (bool, error) {
 	o.containerConfig.PrintProperties()
-	fmt.Println()
+	logger.Infoln()
+
+	if o.isNonInteractive() {
+		return true, nil
+	}
 
 	ynProceed, err := YesNoPrompt("Do you wish to proceed?", true, true, o.userInputReader, DefaultMaxAttempts)
 	if err != nil {
@@ -357,7 +642,7 @@ func closeFile(f *os.File) {
 	if f != nil {
 		err := f.Close()
 		if err != nil {
-			fmt.Printf("Error closing file %v: %v \n", f.Name(), err)
+			logger.Warnf("Error closing file %v: %v \n", f.Name(), err)
 		}
 	}
 }
@@ -370,15 +655,20 @@ func getUserHomeDirectory() (string, error) {
 	return currentUser.HomeDir + "/", nil
 }
 
-func getInventoryAddressLineSuffix() (string, error){
-	inventoryAddressLineSuffixRoot := "ansible_connection=ssh ansible_user="
-	currentUser, err := getCurrentOSUser()
-	if err != nil {
-		return "", err
+// inventoryAddressLine returns the line of a host of the group in a generated inventory, e.g. 172.18.10.5 ansible_connection=ssh ansible_user=ubuntu,
+// followed by a comment with the hostname the address was resolved from, if any
+func inventoryAddressLine(containerConfig *config.ContainerInitConfig, groupName string, address string, hostnames map[string]string) string {
+	address = config.CanonicalIPAddress(address)
+	hostname := hostnames[address]
+	remoteUser, sshPort := containerConfig.RemoteUserAndPort(groupName, address, hostname)
+	line := fmt.Sprintf("%v ansible_connection=ssh ansible_user=%v", address, remoteUser)
+	if sshPort != "" {
+		line += fmt.Sprintf(" %v=%v", inventory.AnsiblePortVarName, sshPort)
 	}
-
-	return inventoryAddressLineSuffixRoot + currentUser.Username, nil
-
+	if hostname != "" {
+		line += " # " + hostname
+	}
+	return line
 }
 
 func getCurrentOSUser() (*user.User, error) {
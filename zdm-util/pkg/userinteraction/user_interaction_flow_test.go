@@ -29,10 +29,34 @@ func TestCreateContainerConfiguration_UserInteraction_General(t *testing.T) {
 			name: "No configuration file, full user interaction, valid user input",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+			},
+			persistConfigToFile: true,
+		},
+		{
+			name: "No configuration file, full user interaction with additional SSH keys, valid user input",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				AdditionalSshKeys: []config.SshKey{
+					{
+						PathOnHost:   testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_monitoring_ssh_key"),
+						HostPatterns: []string{"172.18.10.5"},
+					},
+					{
+						PathOnHost:   testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+						HostPatterns: []string{"172.19.*", "172.20.*"},
+					},
 				},
 			},
 			userInputValues: []string{
@@ -40,6 +64,57 @@ func TestCreateContainerConfiguration_UserInteraction_General(t *testing.T) {
 				"172.18.*",
 				"y",
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_monitoring_ssh_key",
+				"172.18.10.5",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.19.* 172.20.*",
+				"",
+			},
+			persistConfigToFile: true,
+		},
+		{
+			name: "No configuration file, full user interaction with a jumphost, valid user input",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				JumphostPublicIpAddress:    "203.0.113.10",
+				JumphostPrivateIpAddress:   "172.18.1.10",
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+				"n",
+				"y",
+				"not_an_address",
+				"203.0.113.10",
+				"172.18.1.10",
+			},
+			persistConfigToFile: true,
+		},
+		{
+			name: "No configuration file, full user interaction with a jumphost specified by hostname, valid user input",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+				JumphostPublicIpAddress:    "jumphost.example.com",
+				JumphostPrivateIpAddress:   "jumphost.internal",
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+				"n",
+				"y",
+				"jumphost.example.com",
+				"jumphost.internal",
 			},
 			persistConfigToFile: true,
 		},
@@ -67,11 +142,9 @@ func TestCreateContainerConfiguration_FromExistingFile(t *testing.T) {
 			name: "Complete and valid configuration file does not result in user interaction",
 			configurationFilePath: "../../testResources/testconfigfile_colon",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{},
 			persistConfigToFile: false,
@@ -80,11 +153,9 @@ func TestCreateContainerConfiguration_FromExistingFile(t *testing.T) {
 			name: "Existing but empty configuration file results in full user interaction",
 			configurationFilePath: "../../testResources/testconfigfile_empty",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -98,11 +169,9 @@ func TestCreateContainerConfiguration_FromExistingFile(t *testing.T) {
 			name: "Completely invalid configuration file results in full user interaction",
 			configurationFilePath: "../../testResources/testconfigfile_invalid_ip_prefix",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -116,11 +185,9 @@ func TestCreateContainerConfiguration_FromExistingFile(t *testing.T) {
 			name: "Partly invalid configuration file results in partial user interaction",
 			configurationFilePath: "../../testResources/testconfigfile_colon_invalidpaths",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -150,7 +217,6 @@ func TestCreateContainerConfiguration_UserInteraction_SshKey(t *testing.T) {
 			name: "User unable to specify ssh key path, exhausts attempts",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"/home/invalid_dir/invalid_ssh_key_1",
@@ -167,11 +233,9 @@ func TestCreateContainerConfiguration_UserInteraction_SshKey(t *testing.T) {
 			name: "User able to specify ssh key path on third attempt",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"/home/invalid_dir/invalid_ssh_key_1",
@@ -187,11 +251,9 @@ func TestCreateContainerConfiguration_UserInteraction_SshKey(t *testing.T) {
 			name: "User able to specify ssh key path on fifth attempt",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"/home/invalid_dir/invalid_ssh_key_1",
@@ -214,6 +276,66 @@ func TestCreateContainerConfiguration_UserInteraction_SshKey(t *testing.T) {
 	}
 }
 
+func TestCreateContainerConfiguration_UserInteraction_ExistingInventory(t *testing.T) {
+	tests := []configCreationTest{
+		{
+			name: "Inventory with a single proxy accepted for local testing deployments",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing"),
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing",
+				"y",
+			},
+			persistConfigToFile: true,
+		},
+		{
+			name: "Inventory with a single proxy rejected for production deployments",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing",
+				"n",
+			},
+			isExpectedError:      true,
+			expectedErrorMessage: "missing required configuration",
+		},
+		{
+			name: "File that is not an inventory rejected, valid inventory on second attempt",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"y",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+			},
+			persistConfigToFile: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runContainerConfigurationTest(t, tt)
+		})
+	}
+}
+
 /*
  - Specify valid proxy address prefix at first attempt [implemented in TestCreateContainerConfiguration_UserInteraction_General]
  - Exhaust attempts to specify proxy address prefix
@@ -226,7 +348,6 @@ func TestCreateContainerConfiguration_UserInteraction_ProxyAddressPrefix(t *test
 			name: "User unable to specify proxy address prefix, exhausts attempts",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -244,11 +365,9 @@ func TestCreateContainerConfiguration_UserInteraction_ProxyAddressPrefix(t *test
 			name: "User able to specify proxy address prefix on second attempt",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -263,11 +382,9 @@ func TestCreateContainerConfiguration_UserInteraction_ProxyAddressPrefix(t *test
 			name: "User able to specify proxy address prefix on fifth attempt",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -310,11 +427,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Demo, 1 proxy, monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -330,11 +445,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Demo, 1 proxy, no monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -349,11 +462,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Demo, 2 proxies, monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -370,11 +481,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Demo, 2 proxies, no monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -386,11 +495,91 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			},
 			generateInventoryFile: true,
 		},
+		{
+			name: "Demo, 1 proxy, monitoring server with a different user and SSH port, valid",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
+				RemoteUser:                 "ec2-user",
+				HostOverrides:              []config.HostOverride{{Target: "monitoring", User: "rocky", Port: "2222"}},
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"n",
+				"y",
+				"172.18.12.27\n",
+				"172.18.100.42",
+				"Administrator",
+				"ec2-user",
+				"y",
+				"bastion_host=rocky",
+				"monitoring=rocky:2222\n",
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name: "Demo, 1 proxy specified by hostname and resolved, valid",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "127.0.0.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"127.0.0.*",
+				"n",
+				"y",
+				"localhost\n",
+				"",
+				"y",
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name: "Demo, 1 proxy and monitoring server specified by hostname and not resolved, valid",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"n",
+				"y",
+				"proxy_0.example.internal",
+				"proxy-0.example.internal\n",
+				"monitoring.example.internal",
+				"n",
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name: "Demo, 1 proxy specified by a hostname that cannot be resolved, invalid",
+			configurationFilePath: "",
+			expectedConfig: &config.ContainerInitConfig{
+			},
+			userInputValues: []string{
+				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				"172.18.*",
+				"n",
+				"y",
+				"proxy-0.example.invalid\n",
+				"",
+				"y",
+			},
+			isExpectedError: true,
+			expectedErrorMessage: "missing required configuration",
+		},
 		{
 			name:  "Demo, no proxies, invalid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -406,11 +595,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, 3 proxies, monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -428,11 +615,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, 3 proxies, no monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -449,11 +634,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, 4 proxies, monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -472,11 +655,9 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, 4 proxies, no monitoring server, valid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{
-					config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
-					config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
-					config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
-				},
+				SshKeyPathOnHost:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				ProxyIpAddressPrefix:       "172.18.*",
+				AnsibleInventoryPathOnHost: testutils.ConvertRelativePathToAbsoluteForTests("zdm_ansible_inventory"),
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -494,7 +675,6 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, no proxies, invalid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -510,7 +690,6 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, one proxy, invalid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -526,7 +705,6 @@ func TestCreateContainerConfiguration_UserInteraction_GenerateInventory(t *testi
 			name: "Production, two proxies, invalid",
 			configurationFilePath: "",
 			expectedConfig: &config.ContainerInitConfig{
-				Properties: map[string]string{},
 			},
 			userInputValues: []string{
 				"../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
@@ -576,15 +754,22 @@ func runContainerConfigurationTest(t *testing.T, tt configCreationTest) {
 			t.Fatalf("Unexpected error: %v", err)
 		}
 	} else {
-		if expectedSshKeyPath, ok := tt.expectedConfig.Properties[config.SshKeyPathOnHostPropertyName]; ok {
-			require.Equal(t, expectedSshKeyPath, actualConfig.Properties[config.SshKeyPathOnHostPropertyName])
+		if tt.expectedConfig.SshKeyPathOnHost != "" {
+			require.Equal(t, tt.expectedConfig.SshKeyPathOnHost, actualConfig.SshKeyPathOnHost)
+		}
+		if tt.expectedConfig.ProxyIpAddressPrefix != "" {
+			require.Equal(t, tt.expectedConfig.ProxyIpAddressPrefix, actualConfig.ProxyIpAddressPrefix)
 		}
-		if expectedProxyIpPrefix, ok := tt.expectedConfig.Properties[config.ProxyIpAddressPrefixPropertyName]; ok {
-			require.Equal(t, expectedProxyIpPrefix, actualConfig.Properties[config.ProxyIpAddressPrefixPropertyName])
+		if tt.expectedConfig.AnsibleInventoryPathOnHost != "" {
+			require.Equal(t, tt.expectedConfig.AnsibleInventoryPathOnHost, actualConfig.AnsibleInventoryPathOnHost)
 		}
-		if expectedInventoryPath, ok := tt.expectedConfig.Properties[config.AnsibleInventoryPathOnHostPropertyName]; ok {
-			require.Equal(t, expectedInventoryPath, actualConfig.Properties[config.AnsibleInventoryPathOnHostPropertyName])
+		require.Equal(t, tt.expectedConfig.AdditionalSshKeys, actualConfig.AdditionalSshKeys)
+		require.Equal(t, tt.expectedConfig.JumphostPublicIpAddress, actualConfig.JumphostPublicIpAddress)
+		require.Equal(t, tt.expectedConfig.JumphostPrivateIpAddress, actualConfig.JumphostPrivateIpAddress)
+		if tt.expectedConfig.RemoteUser != "" {
+			require.Equal(t, tt.expectedConfig.RemoteUser, actualConfig.RemoteUser)
 		}
+		require.Equal(t, tt.expectedConfig.HostOverrides, actualConfig.HostOverrides)
 
 		// checking only for existence here. content of each file is checked in a separate set of tests
 		if tt.persistConfigToFile {
@@ -635,3 +820,286 @@ func cleanUpDefaultInventoryFileForTests(t *testing.T) {
 		testutils.CleanUpFileForTests(file, t)
 	}
 }
+
+func TestCreateContainerConfiguration_NonInteractive(t *testing.T) {
+	tests := []struct {
+		name                  string
+		configurationFilePath string
+		settings              *NonInteractiveSettings
+		flagValues            map[string]string
+		envValues             map[string]string
+		expectedProperties    map[string]string
+		expectedSources       map[string]config.PropertySource
+		expectedProblemFields []string
+		generateInventoryFile bool
+		strictConfig          bool
+	}{
+		{
+			name:     "All values from flags",
+			settings: &NonInteractiveSettings{},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
+				config.AnsibleInventoryPathOnHostPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory",
+			},
+			expectedProperties: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory"),
+			},
+			expectedSources: map[string]config.PropertySource{
+				config.SshKeyPathOnHostPropertyName:           config.FlagSource,
+				config.ProxyIpAddressPrefixPropertyName:       config.FlagSource,
+				config.AnsibleInventoryPathOnHostPropertyName: config.FlagSource,
+			},
+		},
+		{
+			name:                  "Flags take precedence over environment variables, which take precedence over the configuration file",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings:              &NonInteractiveSettings{LocalTestingDeployment: true},
+			flagValues: map[string]string{
+				config.ProxyIpAddressPrefixPropertyName: "10.0.*",
+			},
+			envValues: map[string]string{
+				config.ProxyIpAddressPrefixPropertyName:       "10.1.*",
+				config.AnsibleInventoryPathOnHostPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing",
+			},
+			expectedProperties: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				config.ProxyIpAddressPrefixPropertyName:       "10.0.*",
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing"),
+			},
+			expectedSources: map[string]config.PropertySource{
+				config.SshKeyPathOnHostPropertyName:           config.FileSource,
+				config.ProxyIpAddressPrefixPropertyName:       config.FlagSource,
+				config.AnsibleInventoryPathOnHostPropertyName: config.EnvVarSource,
+			},
+		},
+		{
+			name: "Inventory generated from proxy and monitoring addresses",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses:    []string{"172.18.10.1", "172.18.10.2", "172.18.10.3"},
+				MonitoringIpAddress: "172.18.10.4",
+			},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:     "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName: "172.18.*",
+			},
+			expectedProperties: map[string]string{
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName),
+			},
+			expectedSources: map[string]config.PropertySource{
+				config.AnsibleInventoryPathOnHostPropertyName: config.GeneratedSource,
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name:                  "Inventory generated even if the configuration file refers to an existing one",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses:    []string{"172.18.10.1", "172.18.10.2", "172.18.10.3"},
+				MonitoringIpAddress: "172.18.10.4",
+				GenerateInventory:   true,
+			},
+			expectedProperties: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           testutils.ConvertRelativePathToAbsoluteForTests("../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key"),
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName),
+			},
+			expectedSources: map[string]config.PropertySource{
+				config.SshKeyPathOnHostPropertyName:           config.FileSource,
+				config.AnsibleInventoryPathOnHostPropertyName: config.GeneratedSource,
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name:     "Nothing provided, all problems reported at once",
+			settings: &NonInteractiveSettings{},
+			expectedProblemFields: []string{
+				config.SshKeyPathOnHostPropertyName, config.ProxyIpAddressPrefixPropertyName, config.AnsibleInventoryPathOnHostPropertyName,
+			},
+		},
+		{
+			name: "Invalid values and too few proxies for a production deployment",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses: []string{"172.18.10.1", "not_an_address"},
+			},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:     "/home/invalid_dir/invalid_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName: "172.18.*",
+			},
+			// invalid key value, too few proxies, invalid proxy address
+			expectedProblemFields: []string{
+				config.SshKeyPathOnHostPropertyName, ProxyIpAddressesSettingName, ProxyIpAddressesSettingName,
+			},
+		},
+		{
+			name:                  "Invalid flag value does not fall back to the configuration file",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings:              &NonInteractiveSettings{},
+			flagValues: map[string]string{
+				config.ProxyIpAddressPrefixPropertyName: "10.0.0.1",
+			},
+			expectedProblemFields: []string{config.ProxyIpAddressPrefixPropertyName},
+		},
+		{
+			name:                  "Invalid additional SSH key is reported with the other problems",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings:              &NonInteractiveSettings{},
+			flagValues: map[string]string{
+				config.AdditionalSshKeysPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_monitoring_ssh_key",
+			},
+			expectedProblemFields: []string{config.AdditionalSshKeysPropertyName},
+		},
+		{
+			name:                  "Invalid remote user and host override are reported with the other problems",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings:              &NonInteractiveSettings{},
+			flagValues: map[string]string{
+				config.RemoteUserPropertyName:    "Administrator",
+				config.HostOverridesPropertyName: "monitoring=centos:0",
+			},
+			expectedProblemFields: []string{config.RemoteUserPropertyName, config.HostOverridesPropertyName},
+		},
+		{
+			name:                  "Unknown property of the configuration file reported in strict mode",
+			configurationFilePath: "../../testResources/testconfigfile_colon",
+			settings:              &NonInteractiveSettings{},
+			strictConfig:          true,
+			expectedProblemFields: []string{"some_other_property"},
+		},
+		{
+			name: "Proxies specified by hostname and resolved",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses:       []string{"localhost"},
+				LocalTestingDeployment: true,
+				ResolveHostnames:       true,
+			},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:     "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName: "127.0.0.*",
+			},
+			expectedProperties: map[string]string{
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName),
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name: "Invalid hostname reported with the other problems",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses:       []string{"proxy_0.example.internal"},
+				LocalTestingDeployment: true,
+			},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:     "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName: "172.18.*",
+			},
+			expectedProblemFields: []string{ProxyIpAddressesSettingName},
+		},
+		{
+			name: "Single proxy accepted for local testing deployments",
+			settings: &NonInteractiveSettings{
+				ProxyIpAddresses:       []string{"172.18.10.1"},
+				LocalTestingDeployment: true,
+			},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:     "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName: "172.18.*",
+			},
+			expectedProperties: map[string]string{
+				config.AnsibleInventoryPathOnHostPropertyName: testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName),
+			},
+			generateInventoryFile: true,
+		},
+		{
+			name:     "Existing inventory with a single proxy rejected for production deployments",
+			settings: &NonInteractiveSettings{},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
+				config.AnsibleInventoryPathOnHostPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_ansible_inventory_local_testing",
+			},
+			expectedProblemFields: []string{config.AnsibleInventoryPathOnHostPropertyName},
+		},
+		{
+			name:     "Existing inventory without proxies rejected",
+			settings: &NonInteractiveSettings{LocalTestingDeployment: true},
+			flagValues: map[string]string{
+				config.SshKeyPathOnHostPropertyName:           "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+				config.ProxyIpAddressPrefixPropertyName:       "172.18.*",
+				config.AnsibleInventoryPathOnHostPropertyName: "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+			},
+			expectedProblemFields: []string{config.AnsibleInventoryPathOnHostPropertyName},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer cleanUpDefaultConfigFileForTests(t)
+			defer cleanUpDefaultInventoryFileForTests(t)
+			for propertyName, value := range tt.envValues {
+				t.Setenv(config.EnvVarNameForProperty(propertyName), value)
+			}
+
+			interactionOrchestrator := NewNonInteractiveOrchestrator(tt.settings)
+			interactionOrchestrator.SetPropertyLayers(config.NewFlagLayer(tt.flagValues), config.NewEnvVarLayer())
+			interactionOrchestrator.SetStrictConfig(tt.strictConfig)
+			actualConfig, err := interactionOrchestrator.CreateContainerConfiguration(tt.configurationFilePath)
+
+			if len(tt.expectedProblemFields) > 0 {
+				require.NotNil(t, err)
+				missingConfigErr, ok := err.(*MissingConfigurationError)
+				require.True(t, ok, "Unexpected error type: %v", err)
+				actualProblemFields := make([]string, 0)
+				for _, problem := range missingConfigErr.Problems {
+					actualProblemFields = append(actualProblemFields, problem.Field)
+				}
+				require.Equal(t, tt.expectedProblemFields, actualProblemFields, "Problems: %v", missingConfigErr.Problems)
+				return
+			}
+
+			require.Nil(t, err)
+			for propertyName, expectedValue := range tt.expectedProperties {
+				require.Equal(t, expectedValue, actualConfig.Properties()[propertyName])
+			}
+			for propertyName, expectedSource := range tt.expectedSources {
+				require.Equal(t, expectedSource, actualConfig.Sources[propertyName])
+			}
+			if tt.generateInventoryFile {
+				testutils.CheckFileExistsForTests(DefaultAnsibleInventoryFileName, t)
+			}
+		})
+	}
+}
+
+func TestCreateContainerConfiguration_NonInteractive_DryRun(t *testing.T) {
+	defer cleanUpDefaultConfigFileForTests(t)
+	defer cleanUpDefaultInventoryFileForTests(t)
+
+	interactionOrchestrator := NewNonInteractiveOrchestrator(&NonInteractiveSettings{
+		ProxyIpAddresses:    []string{"172.18.10.1", "172.18.10.2", "172.18.10.3"},
+		MonitoringIpAddress: "172.18.10.4",
+	})
+	interactionOrchestrator.SetPropertyLayers(config.NewFlagLayer(map[string]string{
+		config.SshKeyPathOnHostPropertyName:     "../../testResources/dummy_dir/dummy_sub_dir/dummy_ssh_key",
+		config.ProxyIpAddressPrefixPropertyName: "172.18.*",
+	}))
+	interactionOrchestrator.SetDryRun(true)
+	actualConfig, err := interactionOrchestrator.CreateContainerConfiguration("")
+	require.Nil(t, err)
+	require.Equal(t, testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName), actualConfig.AnsibleInventoryPathOnHost)
+
+	_, err = os.Stat(DefaultAnsibleInventoryFileName)
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(DefaultConfigurationFilePath)
+	require.True(t, os.IsNotExist(err))
+	require.Equal(t, []PlannedFileWrite{
+		{
+			Path:        testutils.ConvertRelativePathToAbsoluteForTests(DefaultAnsibleInventoryFileName),
+			Description: "Write the Ansible inventory zdm_ansible_inventory with proxies [172.18.10.1 172.18.10.2 172.18.10.3] and monitoring host 172.18.10.4",
+		},
+		{
+			Path:        testutils.ConvertRelativePathToAbsoluteForTests(DefaultConfigurationFilePath),
+			Description: "Write the configuration to the configuration file ansible_container_init_config",
+		},
+	}, interactionOrchestrator.PlannedFileWrites())
+}
@@ -3,12 +3,17 @@ package userinteraction
 import (
 	"bufio"
 	"fmt"
+	"golang.org/x/term"
+	"os"
 	"strings"
 	"zdm-proxy-automation/zdm-util/pkg/config"
+	"zdm-proxy-automation/zdm-util/pkg/logger"
 )
 
 const (
 	EmptyValueMessage = "<value not provided>"
+	// HiddenValueMessage replaces secret values, e.g. passphrases, in the transcript
+	HiddenValueMessage = "<value hidden>"
 )
 
 // StringPrompt asks for a string value, using the provided prompt message.
@@ -20,50 +25,79 @@ func StringPrompt(promptMessage string, tryAgainMessage string, returnOnEmptyVal
 	trimmedString := ""
 
 	for remainingAttempts := maxAttempts; remainingAttempts > 0; remainingAttempts-- {
-		fmt.Printf("\n" + promptMessage + ": ")
+		logger.Infof("\n%s: ", promptMessage)
 		s, err := userInputReader.ReadString('\n')
 		if err != nil {
-			fmt.Printf("Error reading line %v \n", err)
+			logger.Warnf("Error reading line %v \n", err)
 		}
 		trimmedString = config.FormatString(s)
+		logger.Input(trimmedString)
 		if trimmedString != "" {
 			if validateValue(trimmedString) {
 				return trimmedString
 			} else {
 				// invalid value message is being printed by the validation function
-				fmt.Println()
+				logger.Infoln()
 				if tryAgainMessage != "" && remainingAttempts > 1 {
-					fmt.Println(tryAgainMessage)
+					logger.Infoln(tryAgainMessage)
 				}
 			}
 		} else {
 			// empty string
 			if returnOnEmptyValue {
-				fmt.Println(EmptyValueMessage)
+				logger.Infoln(EmptyValueMessage)
 				return trimmedString
 			}
 
 			if tryAgainMessage != "" && remainingAttempts > 1 {
-				fmt.Println()
-				fmt.Println(tryAgainMessage)
+				logger.Infoln()
+				logger.Infoln(tryAgainMessage)
 			}
-			fmt.Println()
+			logger.Infoln()
 		}
 	}
 
-	fmt.Println(EmptyValueMessage)
+	logger.Infoln(EmptyValueMessage)
 	return ""
 }
 
+// PassphrasePrompt asks for a secret value, which is neither displayed nor recorded in the transcript.
+// If the user input is a terminal, the value is read without echoing it, otherwise it is read from the reader, e.g. when the input is piped
+func PassphrasePrompt(promptMessage string, userInputReader *bufio.Reader) string {
+	logger.Infof("\n%s: ", promptMessage)
+
+	var passphrase string
+	stdinFd := int(os.Stdin.Fd())
+	if userInputReader.Buffered() == 0 && term.IsTerminal(stdinFd) {
+		b, err := term.ReadPassword(stdinFd)
+		if err != nil {
+			logger.Warnf("Error reading passphrase %v \n", err)
+		}
+		// the line break typed by the user is not echoed either
+		logger.Infoln()
+		passphrase = string(b)
+	} else {
+		s, err := userInputReader.ReadString('\n')
+		if err != nil {
+			logger.Warnf("Error reading line %v \n", err)
+		}
+		// passphrases can start or end with spaces, so only the line terminator is removed
+		passphrase = strings.TrimRight(s, "\r\n")
+	}
+	logger.Input(HiddenValueMessage)
+	return passphrase
+}
+
 // StringPromptLoopingForMultipleValues prompts for input repeatedly until it receives an empty input value
 func StringPromptLoopingForMultipleValues(promptMessage string, validateValue func(string) bool, userInputReader *bufio.Reader) []string {
 
 	values := make([]string, 0)
 	var s string
 	for {
-		fmt.Printf("\n" + promptMessage + ": ")
+		logger.Infof("\n%s: ", promptMessage)
 		s, _ = userInputReader.ReadString('\n')
 		trimmedValue := config.FormatString(s)
+		logger.Input(trimmedValue)
 		if trimmedValue != "" {
 			if validateValue(trimmedValue) {
 				values = append(values, trimmedValue)
@@ -90,9 +124,10 @@ func YesNoPrompt(promptMessage string, hasDefault bool, defaultToYes bool, userI
 
 	var s string
 	for remainingAttempts := maxAttempts; remainingAttempts > 0; remainingAttempts-- {
-		fmt.Printf("\n%s (%s) ", promptMessage, choices)
+		logger.Infof("\n%s (%s) ", promptMessage, choices)
 		s, _ = userInputReader.ReadString('\n')
 		s = config.FormatString(s)
+		logger.Input(s)
 		if s == "" {
 			if hasDefault {
 				return defaultToYes, nil